@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// GetWorkerPoolStats returns the queue depth and active workers of the crawl worker pool
+func GetWorkerPoolStats(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"data": service.DefaultCrawlWorkerPool().Stats(),
+	})
+}
+
+// AssignUserRole changes a user's role. The user gets the new role's
+// permissions when they next log in or refresh their session.
+func AssignUserRole(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req models.AssignRoleRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	user, err := service.AssignRole(id, req.Role)
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case errors.Is(err, service.ErrLastAdmin):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logger.Sugar().Errorf("Failed to assign role: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not assign role",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    user,
+		"message": "Role assigned successfully",
+	})
+}
+
+// ResetUserPassword ends all sessions of a user and sets their password, or
+// mails them a reset link if no password is given.
+func ResetUserPassword(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var req service.AdminResetPasswordRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	err = service.AdminResetPassword(id, req)
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case errors.Is(err, service.ErrPasswordPolicy):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrNoEmail):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logger.Sugar().Errorf("Failed to reset password: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not reset password",
+		})
+	}
+
+	message := "Password reset successfully"
+	if req.Password == "" {
+		message = "Password reset link sent"
+	}
+	return c.JSON(fiber.Map{
+		"message": message,
+	})
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/middleware"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// RefreshSession exchanges a refresh token for a new access token and
+// refresh token. The old refresh token stops working.
+func RefreshSession(c *fiber.Ctx) error {
+	var req service.RefreshRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	session, err := service.RefreshSession(req.RefreshToken)
+	if errors.Is(err, service.ErrInvalidRefreshToken) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired refresh token",
+		})
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Failed to refresh session: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh session",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
+		"expires_in":    session.ExpiresIn,
+		"message":       "Session refreshed",
+	})
+}
+
+// Logout revokes the caller's access token and the refresh token issued
+// with it
+func Logout(c *fiber.Ctx) error {
+	err := service.Logout(middleware.UserID(c), middleware.TokenID(c), middleware.TokenExpiry(c))
+	if err != nil {
+		logger.Sugar().Errorf("Failed to log out: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutEverywhere revokes all of the caller's sessions on every device
+func LogoutEverywhere(c *fiber.Ctx) error {
+	err := service.LogoutEverywhere(middleware.UserID(c), middleware.TokenID(c), middleware.TokenExpiry(c))
+	if err != nil {
+		logger.Sugar().Errorf("Failed to log out everywhere: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out of all sessions successfully",
+	})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,24 +13,26 @@ import (
 	"sykell-backend/pkg/logger"
 )
 
-var crawlerService = service.NewCrawlerService()
+// crawlerService returns the shared service. Package variables are
+// initialized before main opens the database connection, so it is resolved
+// on each call rather than stored in one.
+func crawlerService() *service.CrawlerService {
+	return service.DefaultCrawlerService()
+}
 
 // AddURL adds a new URL for crawling
 func AddURL(c *fiber.Ctx) error {
 	var req models.CrawlRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
-	if req.URL == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "URL is required",
-		})
+	scope, ok, err := targetProject(c)
+	if !ok {
+		return err
 	}
 
-	crawlURL, err := crawlerService.AddURL(req.URL)
+	crawlURL, duplicate, err := crawlerService().AddURL(req.URL, scope.UserID, scope.ProjectID, req.CrawlOptions)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to add URL: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -37,9 +40,18 @@ func AddURL(c *fiber.Ctx) error {
 		})
 	}
 
+	if duplicate {
+		return c.JSON(fiber.Map{
+			"data":      crawlURL,
+			"duplicate": true,
+			"message":   "URL matches an existing entry",
+		})
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"data":    crawlURL,
-		"message": "URL added successfully",
+		"data":      crawlURL,
+		"duplicate": false,
+		"message":   "URL added successfully",
 	})
 }
 
@@ -52,7 +64,17 @@ func StartCrawl(c *fiber.Ctx) error {
 		})
 	}
 
-	err = crawlerService.CrawlURL(id)
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	err = crawlerService().CrawlURL(scope, id)
+	if errors.Is(err, service.ErrCrawlURLNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		logger.Sugar().Errorf("Failed to start crawl: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -61,16 +83,78 @@ func StartCrawl(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Crawl started successfully",
+		"message": "Crawl queued successfully",
+	})
+}
+
+// CancelCrawl cancels a queued or running crawl by ID
+func CancelCrawl(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid URL ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	err = crawlerService().CancelCrawl(scope, id)
+	switch {
+	case errors.Is(err, service.ErrCrawlURLNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrCrawlNotActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logger.Sugar().Errorf("Failed to cancel crawl: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel crawl",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Crawl cancelled successfully",
+	})
+}
+
+// CancelCrawls cancels the queued or running crawls of multiple URLs by IDs
+func CancelCrawls(c *fiber.Ctx) error {
+	var req models.BatchRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	cancelled, err := crawlerService().CancelCrawls(scope, req.IDs)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to cancel crawls: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel crawls",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    fiber.Map{"cancelled_ids": cancelled},
+		"message": fmt.Sprintf("Cancelled %d crawls", len(cancelled)),
 	})
 }
 
-// GetCrawlURLs returns paginated list of crawl URLs
+// GetCrawlURLs returns paginated list of crawl URLs. Pages are numbered by
+// page, or follow the cursor parameter, which takes the next_cursor of the
+// previous page and stays fast on deep pages.
 func GetCrawlURLs(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	status := c.Query("status", "")
-	search := c.Query("search", "")
 
 	if page < 1 {
 		page = 1
@@ -79,25 +163,87 @@ func GetCrawlURLs(c *fiber.Ctx) error {
 		limit = 20
 	}
 
-	crawlURLs, total, err := crawlerService.GetCrawlURLs(page, limit, status, search)
+	filter, sort, ok, err := crawlURLListQuery(c)
+	if !ok {
+		return err
+	}
+
+	crawlURLs, total, nextCursor, err := crawlerService().GetCrawlURLs(service.CrawlURLListOptions{
+		Filter: filter,
+		Sort:   sort,
+		Page:   page,
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidListQuery) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		logger.Sugar().Errorf("Failed to get crawl URLs: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch crawl URLs",
 		})
 	}
 
+	var cursor interface{}
+	if nextCursor != "" {
+		cursor = nextCursor
+	}
+
 	return c.JSON(fiber.Map{
 		"data": crawlURLs,
 		"pagination": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-			"pages": (total + limit - 1) / limit,
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"pages":       (total + limit - 1) / limit,
+			"next_cursor": cursor,
 		},
 	})
 }
 
+// crawlURLListQuery reads the project, filter and sort query parameters
+// shared by the URL list and export. If they are invalid it writes the
+// error response and returns false.
+func crawlURLListQuery(c *fiber.Ctx) (models.CrawlURLFilter, []models.SortField, bool, error) {
+	filter, ok, err := crawlURLFilter(c)
+	if !ok {
+		return filter, nil, false, err
+	}
+
+	sort, err := service.ParseCrawlURLSort(c.Query("sort"))
+	if err != nil {
+		return filter, nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return filter, sort, true, nil
+}
+
+// crawlURLFilter reads the project and filter query parameters of the URL
+// list. If they are invalid it writes the error response and returns false.
+func crawlURLFilter(c *fiber.Ctx) (models.CrawlURLFilter, bool, error) {
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return models.CrawlURLFilter{}, false, err
+	}
+
+	filter, err := service.ParseCrawlURLFilter(func(key string) string {
+		return c.Query(key)
+	})
+	if err != nil {
+		return filter, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filter.Scope = scope
+	return filter, true, nil
+}
+
 // GetCrawlResult returns detailed crawl result including broken links
 func GetCrawlResult(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -107,7 +253,12 @@ func GetCrawlResult(c *fiber.Ctx) error {
 		})
 	}
 
-	result, err := crawlerService.GetCrawlResult(id)
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	result, err := crawlerService().GetCrawlResult(scope, id)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to get crawl result: %v", err)
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -122,23 +273,17 @@ func GetCrawlResult(c *fiber.Ctx) error {
 
 // DeleteCrawlURLs deletes multiple crawl URLs by IDs
 func DeleteCrawlURLs(c *fiber.Ctx) error {
-	var req struct {
-		IDs []int `json:"ids"`
-	}
-
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	var req models.BatchRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
-	if len(req.IDs) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "No IDs provided",
-		})
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
 	}
 
-	err := crawlerService.DeleteCrawlURLs(req.IDs)
+	err = crawlerService().DeleteCrawlURLs(scope, req.IDs)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to delete crawl URLs: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -153,39 +298,24 @@ func DeleteCrawlURLs(c *fiber.Ctx) error {
 
 // ReCrawlURLs re-crawls multiple URLs by IDs
 func ReCrawlURLs(c *fiber.Ctx) error {
-	var req struct {
-		IDs []int `json:"ids"`
+	var req models.ReCrawlRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
 	}
 
-	if len(req.IDs) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "No IDs provided",
-		})
-	}
-
-	err := crawlerService.ReCrawlURLs(req.IDs)
+	err = crawlerService().ReCrawlURLs(scope, req.IDs, req.Priority)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to re-crawl URLs: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to re-crawl URLs",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	// Start crawling for each URL
-	for _, id := range req.IDs {
-		go func(crawlID int) {
-			if err := crawlerService.CrawlURL(crawlID); err != nil {
-				logger.Sugar().Errorf("Failed to start crawl for ID %d: %v", crawlID, err)
-			}
-		}(id)
-	}
-
 	return c.JSON(fiber.Map{
 		"message": "Re-crawl started for selected URLs",
 	})
@@ -193,7 +323,12 @@ func ReCrawlURLs(c *fiber.Ctx) error {
 
 // GetCrawlStats returns statistics about crawl URLs
 func GetCrawlStats(c *fiber.Ctx) error {
-	stats, err := crawlerService.GetStats()
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	stats, err := crawlerService().GetStats(scope)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to get crawl stats: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -209,19 +344,21 @@ func GetCrawlStats(c *fiber.Ctx) error {
 // BulkAddURLs adds multiple URLs for crawling
 func BulkAddURLs(c *fiber.Ctx) error {
 	var req models.BulkCrawlRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
-	if len(req.URLs) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "No URLs provided",
-		})
+	// Bulk submissions queue behind interactive ones unless told otherwise
+	if req.Priority == "" {
+		req.Priority = models.PriorityBulk
+	}
+	scope, ok, err := targetProject(c)
+	if !ok {
+		return err
 	}
 
 	var results []interface{}
+	var duplicates []interface{}
 	var errors []string
 
 	for _, url := range req.URLs {
@@ -230,11 +367,18 @@ func BulkAddURLs(c *fiber.Ctx) error {
 			continue
 		}
 
-		crawlURL, err := crawlerService.AddURL(url)
+		crawlURL, duplicate, err := crawlerService().AddURL(url, scope.UserID, scope.ProjectID, req.CrawlOptions)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Failed to add %s: %v", url, err))
 			continue
 		}
+		if duplicate {
+			duplicates = append(duplicates, fiber.Map{
+				"submitted_url": url,
+				"existing":      crawlURL,
+			})
+			continue
+		}
 		results = append(results, crawlURL)
 	}
 
@@ -243,6 +387,10 @@ func BulkAddURLs(c *fiber.Ctx) error {
 		"message": fmt.Sprintf("Added %d URLs successfully", len(results)),
 	}
 
+	if len(duplicates) > 0 {
+		response["duplicates"] = duplicates
+	}
+
 	if len(errors) > 0 {
 		response["errors"] = errors
 	}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"sykell-backend/internal/middleware"
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+)
+
+// sseKeepAlive is how often a comment is sent on an idle stream so that
+// proxies keep the connection open and disconnected clients are noticed.
+// The stream's token and project memberships are checked again at the
+// same interval.
+const sseKeepAlive = 15 * time.Second
+
+// StreamCrawlEvents streams the crawl events of the user's projects as
+// Server-Sent Events. The ids query parameter, a comma-separated list of
+// URL IDs, limits the stream to those URLs. The stream ends when its token
+// expires or is revoked, or the user leaves one of the streamed projects.
+func StreamCrawlEvents(c *fiber.Ctx) error {
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	// Projects joined after the stream opens are picked up on reconnect
+	projectIDs, err := projectService().ProjectIDs(scope)
+	if err != nil {
+		return projectError(c, err, "Failed to fetch projects")
+	}
+
+	var ids []int
+	for _, part := range strings.Split(c.Query("ids"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid URL ID in ids",
+			})
+		}
+		ids = append(ids, id)
+	}
+
+	// The context is not usable once the stream has started
+	jti := middleware.TokenID(c)
+	expiresAt := middleware.TokenExpiry(c)
+
+	events, unsubscribe := service.DefaultCrawlEventBus().Subscribe(ids, projectIDs)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+
+		expiry := time.NewTimer(time.Until(expiresAt))
+		defer expiry.Stop()
+
+		// Tell the client the stream is open before the first event
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			case <-ticker.C:
+				if !streamAuthorized(jti, scope, projectIDs) {
+					return
+				}
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-expiry.C:
+				return
+			}
+
+			// Flush fails once the client has gone away
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+// streamAuthorized reports whether an open event stream may go on: its
+// token has not been revoked and the user still belongs to every project it
+// streams. A failed check ends the stream too; the client reconnects.
+func streamAuthorized(jti string, scope models.ProjectScope, projectIDs []int) bool {
+	revoked, err := service.TokenRevoked(jti)
+	if err != nil || revoked {
+		return false
+	}
+
+	current, err := projectService().ProjectIDs(scope)
+	if err != nil {
+		return false
+	}
+	member := make(map[int]bool, len(current))
+	for _, id := range current {
+		member[id] = true
+	}
+	for _, id := range projectIDs {
+		if !member[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// ExportCrawlURLs streams the crawl URLs matching the filters of the URL
+// list, in its sort order, as a CSV, JSON Lines or XLSX download. The
+// include query parameter, a comma-separated list, adds per-crawl detail
+// such as broken links.
+func ExportCrawlURLs(c *fiber.Ctx) error {
+	var include []string
+	if value := c.Query("include"); value != "" {
+		include = strings.Split(value, ",")
+	}
+
+	filter, sort, ok, err := crawlURLListQuery(c)
+	if !ok {
+		return err
+	}
+
+	export, err := crawlerService().OpenExport(service.ExportOptions{
+		Format:  c.Query("format", "csv"),
+		Filter:  filter,
+		Sort:    sort,
+		Include: include,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidExport) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		logger.Sugar().Errorf("Failed to open export: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export crawl URLs",
+		})
+	}
+
+	filename := fmt.Sprintf("crawl-urls-%s.%s", time.Now().UTC().Format("20060102-150405"), export.Format)
+	c.Set("Content-Type", export.ContentType())
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Rows are written as they are read; once streaming has started the
+	// status can no longer change, so a failure truncates the download
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		if err := export.Write(w); err != nil {
+			logger.Sugar().Errorf("Export failed: %v", err)
+		}
+	}))
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+func importService() *service.CrawlImportService {
+	return service.DefaultCrawlImportService()
+}
+
+// ImportURLs accepts a CSV or newline-delimited file of URLs as the file
+// field of a multipart form and adds its URLs in the background. The
+// response holds the import job, whose report can be polled.
+func ImportURLs(c *fiber.Ctx) error {
+	scope, ok, err := targetProject(c)
+	if !ok {
+		return err
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A file is required",
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read uploaded file",
+		})
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read uploaded file",
+		})
+	}
+
+	job, err := importService().CreateImport(scope.UserID, scope.ProjectID, service.ImportOptions{
+		Filename: fileHeader.Filename,
+		Format:   c.FormValue("format"),
+		Priority: c.FormValue("priority"),
+		Profile:  c.FormValue("profile"),
+	}, content)
+	if err != nil {
+		return importError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"data":    job,
+		"message": "Import started",
+	})
+}
+
+// GetImport returns an import job with its progress and result counts
+func GetImport(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	job, err := importService().GetImport(scope, id)
+	if err != nil {
+		return importError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"data": job,
+	})
+}
+
+// GetImportRows returns the per-row report of an import job
+func GetImportRows(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	rows, total, err := importService().GetImportRows(scope, id, c.Query("result", ""), page, limit)
+	if err != nil {
+		return importError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"data": rows,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+func importError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrImportNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidImport):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	logger.Sugar().Errorf("Import request failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process import request",
+	})
+}
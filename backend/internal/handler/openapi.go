@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/openapi"
+)
+
+// GetOpenAPIDocument returns the OpenAPI 3 document describing the API
+func GetOpenAPIDocument(c *fiber.Ctx) error {
+	return c.JSON(openapi.Document())
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/middleware"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// GetPasswordPolicy returns the rules new passwords have to meet
+func GetPasswordPolicy(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"data": service.CurrentPasswordPolicy(),
+	})
+}
+
+// ChangePassword replaces the caller's password. All of the caller's
+// sessions end, and a new one is returned in their place.
+func ChangePassword(c *fiber.Ctx) error {
+	var req service.ChangePasswordRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	session, err := service.ChangePassword(middleware.UserID(c), middleware.TokenID(c), middleware.TokenExpiry(c), req)
+	switch {
+	case errors.Is(err, service.ErrWrongPassword), errors.Is(err, service.ErrPasswordPolicy):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrUserNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	case err != nil:
+		logger.Sugar().Errorf("Failed to change password: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not change password",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"token":         session.Token,
+		"refresh_token": session.RefreshToken,
+		"expires_in":    session.ExpiresIn,
+		"message":       "Password changed successfully",
+	})
+}
+
+// ForgotPassword mails a password reset token to a registered email
+// address. The response is the same whether or not the address is known.
+func ForgotPassword(c *fiber.Ctx) error {
+	var req service.ForgotPasswordRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	if err := service.ForgotPassword(req.Email, c.IP()); err != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If the email address is registered, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with a token from a reset mail
+func ResetPassword(c *fiber.Ctx) error {
+	var req service.ResetPasswordRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	err := service.ResetPassword(req)
+	switch {
+	case errors.Is(err, service.ErrInvalidResetToken), errors.Is(err, service.ErrPasswordPolicy):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		logger.Sugar().Errorf("Failed to reset password: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not reset password",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
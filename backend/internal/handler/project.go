@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/middleware"
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+func projectService() *service.ProjectService {
+	return service.DefaultProjectService()
+}
+
+// projectScope reads the projects a request may use: the one named by the
+// project_id query parameter, or else every project of the authenticated
+// user. If the user is not a member of the named project it writes the
+// error response and returns false.
+func projectScope(c *fiber.Ctx) (models.ProjectScope, bool, error) {
+	scope := models.ProjectScope{UserID: middleware.UserID(c)}
+	if scope.UserID == 0 {
+		return scope, false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Unauthorized",
+		})
+	}
+
+	if value := c.Query("project_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return scope, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid project ID",
+			})
+		}
+		scope.ProjectID = id
+	}
+
+	if err := projectService().CheckScope(scope); err != nil {
+		return scope, false, projectError(c, err, "Failed to check project membership")
+	}
+
+	return scope, true, nil
+}
+
+// targetProject returns the project that URLs, schedules, imports and
+// webhooks created by a request belong to: the one named by the project_id
+// query parameter, or else the user's default project.
+func targetProject(c *fiber.Ctx) (models.ProjectScope, bool, error) {
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return scope, false, err
+	}
+
+	scope.ProjectID, err = projectService().ResolveProject(scope)
+	if err != nil {
+		return scope, false, projectError(c, err, "Failed to resolve project")
+	}
+
+	return scope, true, nil
+}
+
+// projectError writes the response for an error of the project service,
+// logging unexpected ones with message.
+func projectError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrProjectNotFound), errors.Is(err, service.ErrProjectMemberNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrProjectForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrLastProjectOwner):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	logger.Sugar().Errorf("%s: %v", message, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
+// CreateProject creates a project owned by the authenticated user
+func CreateProject(c *fiber.Ctx) error {
+	var req models.CreateProjectRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	project, err := projectService().CreateProject(middleware.UserID(c), req)
+	if err != nil {
+		return projectError(c, err, "Failed to create project")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data":    project,
+		"message": "Project created successfully",
+	})
+}
+
+// GetProjects returns the projects the authenticated user is a member of
+func GetProjects(c *fiber.Ctx) error {
+	projects, err := projectService().GetProjects(middleware.UserID(c))
+	if err != nil {
+		return projectError(c, err, "Failed to fetch projects")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": projects,
+	})
+}
+
+// GetProject returns a project by ID
+func GetProject(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	project, err := projectService().GetProject(middleware.UserID(c), id)
+	if err != nil {
+		return projectError(c, err, "Failed to fetch project")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": project,
+	})
+}
+
+// DeleteProject deletes a project and all of its URLs
+func DeleteProject(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	if err := projectService().DeleteProject(middleware.UserID(c), id); err != nil {
+		return projectError(c, err, "Failed to delete project")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Project deleted successfully",
+	})
+}
+
+// GetProjectMembers returns the members of a project
+func GetProjectMembers(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	members, err := projectService().GetMembers(middleware.UserID(c), id)
+	if err != nil {
+		return projectError(c, err, "Failed to fetch project members")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": members,
+	})
+}
+
+// AddProjectMember adds a user to a project or changes their role
+func AddProjectMember(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	var req models.AddProjectMemberRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	members, err := projectService().SetMember(middleware.UserID(c), id, req)
+	if err != nil {
+		return projectError(c, err, "Failed to add project member")
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    members,
+		"message": "Project member saved successfully",
+	})
+}
+
+// RemoveProjectMember removes a user from a project
+func RemoveProjectMember(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID",
+		})
+	}
+
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := projectService().RemoveMember(middleware.UserID(c), id, userID); err != nil {
+		return projectError(c, err, "Failed to remove project member")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Project member removed successfully",
+	})
+}
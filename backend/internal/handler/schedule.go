@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// CreateSchedule creates a recurring crawl for a set of URLs
+func CreateSchedule(c *fiber.Ctx) error {
+	var req models.CreateScheduleRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	scope, ok, err := targetProject(c)
+	if !ok {
+		return err
+	}
+
+	schedule, err := service.DefaultCrawlScheduleService().CreateSchedule(scope.ProjectID, req)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create schedule: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data":    schedule,
+		"message": "Schedule created successfully",
+	})
+}
+
+// GetSchedules returns the crawl schedules of the user's projects
+func GetSchedules(c *fiber.Ctx) error {
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	schedules, err := service.DefaultCrawlScheduleService().GetSchedules(scope)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get schedules: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch schedules",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": schedules,
+	})
+}
+
+// PauseSchedule stops a schedule from firing
+func PauseSchedule(c *fiber.Ctx) error {
+	return updateSchedule(c, service.DefaultCrawlScheduleService().PauseSchedule, "Schedule paused")
+}
+
+// ResumeSchedule reactivates a paused schedule
+func ResumeSchedule(c *fiber.Ctx) error {
+	return updateSchedule(c, service.DefaultCrawlScheduleService().ResumeSchedule, "Schedule resumed")
+}
+
+func updateSchedule(c *fiber.Ctx, update func(models.ProjectScope, int) (*models.CrawlSchedule, error), message string) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid schedule ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	schedule, err := update(scope, id)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    schedule,
+		"message": message,
+	})
+}
+
+// DeleteSchedule deletes a schedule. The scheduled URLs are kept.
+func DeleteSchedule(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid schedule ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	if err := service.DefaultCrawlScheduleService().DeleteSchedule(scope, id); err != nil {
+		return scheduleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Schedule deleted successfully",
+	})
+}
+
+func scheduleError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrScheduleNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	logger.Sugar().Errorf("Failed to update schedule: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// SearchPages runs a full-text search over the title, meta description and
+// text of crawled pages. The q query parameter holds the search terms; the
+// filters of the URL list narrow the results.
+func SearchPages(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter, ok, err := crawlURLFilter(c)
+	if !ok {
+		return err
+	}
+
+	results, total, err := crawlerService().SearchPages(c.Query("q"), filter, page, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidListQuery) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		logger.Sugar().Errorf("Failed to search pages: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search pages",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": results,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
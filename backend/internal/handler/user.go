@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -52,13 +53,16 @@ func GetUser(c *fiber.Ctx) error {
 
 func CreateUser(c *fiber.Ctx) error {
 	var req service.CreateUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid payload",
-		})
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
 	user, err := service.CreateUser(req)
+	if errors.Is(err, service.ErrPasswordPolicy) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		logger.Sugar().Errorf("CreateUser error: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -80,10 +84,8 @@ func UpdateUser(c *fiber.Ctx) error {
 	}
 
 	var req service.UpdateUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid payload",
-		})
+	if ok, err := parseBody(c, &req); !ok {
+		return err
 	}
 
 	user, err := service.UpdateUser(id, req)
@@ -115,6 +117,11 @@ func DeleteUser(c *fiber.Ctx) error {
 	}
 
 	err = service.DeleteUser(id)
+	if errors.Is(err, service.ErrLastAdmin) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		logger.Sugar().Errorf("DeleteUser error: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -129,13 +136,11 @@ func DeleteUser(c *fiber.Ctx) error {
 
 func Login(c *fiber.Ctx) error {
 	var creds service.Credentials
-	if err := c.BodyParser(&creds); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid login payload",
-		})
+	if ok, err := parseBody(c, &creds); !ok {
+		return err
 	}
 
-	token, err := service.Authenticate(creds)
+	session, err := service.Authenticate(creds)
 	if err != nil {
 		logger.Sugar().Errorf("Authentication error: %v", err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -144,7 +149,9 @@ func Login(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"token": token,
+		"token": session.Token,
+		"refresh_token": session.RefreshToken,
+		"expires_in": session.ExpiresIn,
 		"message": "Login successful",
 	})
 }
\ No newline at end of file
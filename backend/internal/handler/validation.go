@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/validation"
+)
+
+// parseBody parses the JSON request body into req and checks its validate
+// tags. If either fails it writes the 400 response, with one message per
+// invalid field, and returns false.
+func parseBody(c *fiber.Ctx, req interface{}) (bool, error) {
+	if err := c.BodyParser(req); err != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if fields := validation.Struct(req); fields != nil {
+		return false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Validation failed",
+			"fields": fields,
+		})
+	}
+
+	return true, nil
+}
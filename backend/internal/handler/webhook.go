@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
+)
+
+// CreateWebhook subscribes a URL to crawl events. The response is the only
+// time the signing secret is returned.
+func CreateWebhook(c *fiber.Ctx) error {
+	var req models.CreateWebhookRequest
+	if ok, err := parseBody(c, &req); !ok {
+		return err
+	}
+
+	scope, ok, err := targetProject(c)
+	if !ok {
+		return err
+	}
+
+	subscription, err := service.DefaultWebhookService().CreateSubscription(scope.ProjectID, req)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create webhook: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"data":    subscription,
+		"message": "Webhook created successfully",
+	})
+}
+
+// GetWebhooks returns the webhook subscriptions of the user's projects
+func GetWebhooks(c *fiber.Ctx) error {
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	subscriptions, err := service.DefaultWebhookService().GetSubscriptions(scope)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get webhooks: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch webhooks",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": subscriptions,
+	})
+}
+
+// DeleteWebhook deletes a subscription and its delivery log
+func DeleteWebhook(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	if err := service.DefaultWebhookService().DeleteSubscription(scope, id); err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// GetWebhookDeliveries returns a subscription's delivery log, newest first
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid webhook ID",
+		})
+	}
+
+	scope, ok, err := projectScope(c)
+	if !ok {
+		return err
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, total, err := service.DefaultWebhookService().GetDeliveries(scope, id, page, limit)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"data": deliveries,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+			"pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrWebhookNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	logger.Sugar().Errorf("Webhook request failed: %v", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to process webhook request",
+	})
+}
@@ -2,24 +2,150 @@ package middleware
 
 import (
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/golang-jwt/jwt/v4"
+
+	"sykell-backend/internal/service"
+	"sykell-backend/pkg/logger"
 )
 
 func JWTMiddleware() fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:     []byte(jwtSecret()),
+		SuccessHandler: checkRevoked,
+		ErrorHandler:   jwtError,
+	})
+}
+
+// JWTStreamMiddleware is JWTMiddleware for streaming endpoints. Browsers
+// cannot set headers on an EventSource, so the token may also be passed in
+// the access_token query parameter.
+func JWTStreamMiddleware() fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:     []byte(jwtSecret()),
+		TokenLookup:    "header:Authorization,query:access_token",
+		SuccessHandler: checkRevoked,
+		ErrorHandler:   jwtError,
+	})
+}
+
+func jwtSecret() string {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "your-secret-key" // Default secret for development
 	}
-	return jwtware.New(jwtware.Config{
-		SigningKey:   []byte(secret),
-		ErrorHandler: jwtError,
-	})
+	return secret
+}
+
+// checkRevoked refuses tokens that were revoked by logging out. Tokens
+// issued before they carried a jti cannot be revoked and are refused too.
+func checkRevoked(c *fiber.Ctx) error {
+	jti := TokenID(c)
+	if jti == "" {
+		return jwtError(c, nil)
+	}
+
+	revoked, err := service.TokenRevoked(jti)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to check token revocation: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify token",
+		})
+	}
+	if revoked {
+		return jwtError(c, nil)
+	}
+
+	return c.Next()
 }
 
 func jwtError(c *fiber.Ctx, err error) error {
 	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 		"error": "Unauthorized",
 	})
-}
\ No newline at end of file
+}
+
+// UserID returns the user_id claim of the token JWTMiddleware verified, or 0
+// if there is none.
+func UserID(c *fiber.Ctx) int {
+	claims := tokenClaims(c)
+	// JSON numbers decode as float64
+	id, _ := claims["user_id"].(float64)
+	return int(id)
+}
+
+// Role returns the role claim of the token JWTMiddleware verified, or an
+// empty string if there is none.
+func Role(c *fiber.Ctx) string {
+	role, _ := tokenClaims(c)["role"].(string)
+	return role
+}
+
+// TokenID returns the jti claim of the token JWTMiddleware verified, or an
+// empty string if there is none.
+func TokenID(c *fiber.Ctx) string {
+	jti, _ := tokenClaims(c)["jti"].(string)
+	return jti
+}
+
+// TokenExpiry returns when the token JWTMiddleware verified expires.
+func TokenExpiry(c *fiber.Ctx) time.Time {
+	exp, _ := tokenClaims(c)["exp"].(float64)
+	return time.Unix(int64(exp), 0)
+}
+
+func tokenClaims(c *fiber.Ctx) jwt.MapClaims {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return nil
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	return claims
+}
+
+// RequireRole lets a request through only if its token carries one of the
+// given roles. It runs after JWTMiddleware. Tokens issued before roles
+// existed carry none and are refused until the user logs in again.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if hasRole(c, roles) {
+			return c.Next()
+		}
+		return forbidden(c)
+	}
+}
+
+// RequireSelfOrRole is RequireRole for routes about a single user, which
+// that user may also call. param names the route parameter holding the
+// user's ID.
+func RequireSelfOrRole(param string, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if id, err := strconv.Atoi(c.Params(param)); err == nil && id != 0 && id == UserID(c) {
+			return c.Next()
+		}
+		if hasRole(c, roles) {
+			return c.Next()
+		}
+		return forbidden(c)
+	}
+}
+
+func hasRole(c *fiber.Ctx, roles []string) bool {
+	role := Role(c)
+	for _, allowed := range roles {
+		if role != "" && role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "Insufficient permissions",
+	})
+}
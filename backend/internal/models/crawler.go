@@ -4,10 +4,33 @@ import (
 	"time"
 )
 
+// CrawlOptions holds the per-crawl settings a client may supply when adding a URL.
+type CrawlOptions struct {
+	// LinkScope decides which links count as internal: the exact host,
+	// the registrable domain (eTLD+1), or a custom list of domains
+	LinkScope string `json:"link_scope,omitempty" db:"link_scope" validate:"omitempty,oneof=host domain custom"`
+	// SameSiteDomains lists the domains treated as internal when LinkScope is custom
+	SameSiteDomains []string `json:"same_site_domains,omitempty" db:"same_site_domains" validate:"required_if=LinkScope custom"`
+	// LinkCheckMode decides which of the page's unique links are requested:
+	// all of them, the first LinkCheckLimit, internal links only, or a
+	// random sample of LinkCheckLimit
+	LinkCheckMode  string `json:"link_check_mode,omitempty" db:"link_check_mode" validate:"omitempty,oneof=all limit internal sample"`
+	LinkCheckLimit int    `json:"link_check_limit,omitempty" db:"link_check_limit" validate:"min=0"`
+	// Priority orders the queue: interactive crawls are claimed before bulk
+	// imports, which are claimed before scheduled re-crawls
+	Priority string `json:"priority,omitempty" db:"priority" validate:"omitempty,oneof=interactive bulk scheduled"`
+	// Tags are free-form labels for grouping URLs, such as a campaign or
+	// client name
+	Tags []string `json:"tags,omitempty" db:"tags" validate:"dive,max=50,excludes=0x2C"`
+}
+
 type CrawlURL struct {
 	ID                     int        `json:"id" db:"id"`
 	URL                    string     `json:"url" db:"url"`
+	NormalizedURL          string     `json:"normalized_url" db:"normalized_url"`
 	Status                 string     `json:"status" db:"status"`
+	OwnerID                *int       `json:"owner_id" db:"owner_id"`
+	ProjectID              int        `json:"project_id" db:"project_id"`
 	Title                  string     `json:"title" db:"title"`
 	HTMLVersion            string     `json:"html_version" db:"html_version"`
 	H1Count                int        `json:"h1_count" db:"h1_count"`
@@ -19,11 +42,31 @@ type CrawlURL struct {
 	InternalLinksCount     int        `json:"internal_links_count" db:"internal_links_count"`
 	ExternalLinksCount     int        `json:"external_links_count" db:"external_links_count"`
 	InaccessibleLinksCount int        `json:"inaccessible_links_count" db:"inaccessible_links_count"`
+	CheckedLinksCount      int        `json:"checked_links_count" db:"checked_links_count"`
+	CachedLinksCount       int        `json:"cached_links_count" db:"cached_links_count"`
+	UncheckedLinksCount    int        `json:"unchecked_links_count" db:"unchecked_links_count"`
+	MailtoLinksCount       int        `json:"mailto_links_count" db:"mailto_links_count"`
+	TelLinksCount          int        `json:"tel_links_count" db:"tel_links_count"`
+	JavascriptLinksCount   int        `json:"javascript_links_count" db:"javascript_links_count"`
+	DataLinksCount         int        `json:"data_links_count" db:"data_links_count"`
+	OtherSchemeLinksCount  int        `json:"other_scheme_links_count" db:"other_scheme_links_count"`
 	HasLoginForm           bool       `json:"has_login_form" db:"has_login_form"`
 	ErrorMessage           string     `json:"error_message" db:"error_message"`
 	LastCrawledAt          *time.Time `json:"last_crawled_at" db:"last_crawled_at"`
+	Attempts               int        `json:"attempts" db:"attempts"`
+	NextAttemptAt          *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	ClaimedBy              string     `json:"claimed_by,omitempty" db:"claimed_by"`
+	LeaseExpiresAt         *time.Time `json:"lease_expires_at,omitempty" db:"lease_expires_at"`
+	HeartbeatAt            *time.Time `json:"heartbeat_at,omitempty" db:"heartbeat_at"`
 	CreatedAt              time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt              time.Time  `json:"updated_at" db:"updated_at"`
+	// Progress is set while the URL is running
+	Progress *CrawlProgress `json:"progress,omitempty"`
+	// QueuePosition is the 1-based place of a queued URL in claim order. It
+	// is only set in listings, and only for URLs waiting to be claimed.
+	QueuePosition *int `json:"queue_position,omitempty"`
+
+	CrawlOptions
 }
 
 type BrokenLink struct {
@@ -35,17 +78,172 @@ type BrokenLink struct {
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 }
 
+// LinkIssue records a problem with a link that is not an HTTP failure,
+// such as a malformed mailto address or a javascript: pseudo-link.
+type LinkIssue struct {
+	ID         int       `json:"id" db:"id"`
+	CrawlURLID int       `json:"crawl_url_id" db:"crawl_url_id"`
+	URL        string    `json:"url" db:"url"`
+	IssueType  string    `json:"issue_type" db:"issue_type"`
+	Message    string    `json:"message" db:"message"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// LinkCheck is the outcome of checking one link during a crawl. FromCache
+// is set when the status was taken from the shared link-status cache, in
+// which case CheckedAt is the time of the original request.
+type LinkCheck struct {
+	ID           int       `json:"id" db:"id"`
+	CrawlURLID   int       `json:"crawl_url_id" db:"crawl_url_id"`
+	URL          string    `json:"url" db:"url"`
+	StatusCode   int       `json:"status_code" db:"status_code"`
+	ErrorMessage string    `json:"error_message" db:"error_message"`
+	FromCache    bool      `json:"from_cache" db:"from_cache"`
+	CheckedAt    time.Time `json:"checked_at" db:"checked_at"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// LinkResults are the link checks, link issues and broken links found by
+// one crawl of a page. They replace those of the page's previous crawl.
+type LinkResults struct {
+	Checks      []LinkCheck
+	Issues      []LinkIssue
+	BrokenLinks []BrokenLink
+}
+
+// CrawlAttempt records one attempt at crawling a URL.
+type CrawlAttempt struct {
+	ID            int        `json:"id" db:"id"`
+	CrawlURLID    int        `json:"crawl_url_id" db:"crawl_url_id"`
+	Attempt       int        `json:"attempt" db:"attempt"`
+	Outcome       string     `json:"outcome" db:"outcome"`
+	ErrorClass    string     `json:"error_class,omitempty" db:"error_class"`
+	StatusCode    int        `json:"status_code,omitempty" db:"status_code"`
+	ErrorMessage  string     `json:"error_message,omitempty" db:"error_message"`
+	StartedAt     time.Time  `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+}
+
+// CrawlProgress reports how far a running crawl has got. LinksChecked and
+// LinksTotal are only set in the checking_links phase.
+type CrawlProgress struct {
+	CrawlURLID   int       `json:"crawl_url_id"`
+	URL          string    `json:"url,omitempty"`
+	Phase        string    `json:"phase"`
+	LinksChecked int       `json:"links_checked"`
+	LinksTotal   int       `json:"links_total"`
+	Percent      int       `json:"percent"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CrawlEvent announces a change in the state of a crawl.
+type CrawlEvent struct {
+	Type         string         `json:"type"`
+	CrawlURLID   int            `json:"crawl_url_id"`
+	ProjectID    int            `json:"project_id"`
+	URL          string         `json:"url,omitempty"`
+	Status       string         `json:"status"`
+	ErrorMessage string         `json:"error_message,omitempty"`
+	Progress     *CrawlProgress `json:"progress,omitempty"`
+	Timestamp    time.Time      `json:"timestamp"`
+}
+
 type CrawlResult struct {
-	CrawlURL    CrawlURL     `json:"crawl_url"`
-	BrokenLinks []BrokenLink `json:"broken_links"`
+	CrawlURL       CrawlURL       `json:"crawl_url"`
+	BrokenLinks    []BrokenLink   `json:"broken_links"`
+	LinkIssues     []LinkIssue    `json:"link_issues"`
+	LinkChecks     []LinkCheck    `json:"link_checks"`
+	AttemptHistory []CrawlAttempt `json:"attempt_history"`
+}
+
+// CrawlExportRow is one URL in an export, together with the per-crawl
+// detail that was requested. Detail that was not requested is left out.
+type CrawlExportRow struct {
+	CrawlURL
+	BrokenLinks    []BrokenLink   `json:"broken_links,omitempty"`
+	LinkIssues     []LinkIssue    `json:"link_issues,omitempty"`
+	LinkChecks     []LinkCheck    `json:"link_checks,omitempty"`
+	AttemptHistory []CrawlAttempt `json:"attempt_history,omitempty"`
 }
 
 type CrawlRequest struct {
 	URL string `json:"url" validate:"required,url"`
+	CrawlOptions
 }
 
 type BulkCrawlRequest struct {
-	URLs []string `json:"urls" validate:"required,dive,url"`
+	URLs []string `json:"urls" validate:"required,min=1,dive,url"`
+	CrawlOptions
+}
+
+// BatchRequest selects the crawl URLs an action applies to.
+type BatchRequest struct {
+	IDs []int `json:"ids" validate:"required,min=1"`
+}
+
+type ReCrawlRequest struct {
+	IDs      []int  `json:"ids" validate:"required,min=1"`
+	Priority string `json:"priority" validate:"omitempty,oneof=interactive bulk scheduled"`
+}
+
+// CrawlURLFilter selects the crawl URLs of a listing or export. Zero
+// fields do not filter, except Scope, which every listing is limited to.
+type CrawlURLFilter struct {
+	Scope    ProjectScope
+	Statuses []string
+	// Search matches part of the URL or title
+	Search       string
+	HTMLVersion  string
+	HasLoginForm *bool
+	Tag          string
+	Ranges       []MetricRange
+	// The From bounds are inclusive and the To bounds exclusive
+	CrawledFrom *time.Time
+	CrawledTo   *time.Time
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// MetricRange limits a metric column, such as h1_count, to a range. Either
+// bound may be left open.
+type MetricRange struct {
+	Column string
+	Min    *int
+	Max    *int
+}
+
+// SortField orders a listing by one column.
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// CrawlURLQuery selects one page of a crawl URL listing. Pages are either
+// numbered, using Offset, or follow the row whose sort key is After.
+type CrawlURLQuery struct {
+	Filter CrawlURLFilter
+	Sort   []SortField
+	Limit  int
+	Offset int
+	After  []interface{}
+}
+
+// CrawlURLMetricColumns are the numeric columns that can be filtered by
+// range and sorted on.
+var CrawlURLMetricColumns = []string{
+	"h1_count", "h2_count", "h3_count", "h4_count", "h5_count", "h6_count",
+	"internal_links_count", "external_links_count", "inaccessible_links_count",
+	"checked_links_count", "cached_links_count", "unchecked_links_count",
+	"mailto_links_count", "tel_links_count", "javascript_links_count",
+	"data_links_count", "other_scheme_links_count", "attempts",
+}
+
+// CrawlURLSortColumns are the columns a listing can be sorted on, besides
+// the metric columns.
+var CrawlURLSortColumns = []string{
+	"id", "url", "title", "status", "html_version", "has_login_form",
+	"last_crawled_at", "created_at", "updated_at",
 }
 
 type CrawlStats struct {
@@ -54,6 +252,10 @@ type CrawlStats struct {
 	RunningURLs   int `json:"running_urls"`
 	CompletedURLs int `json:"completed_urls"`
 	ErrorURLs     int `json:"error_urls"`
+	FailedURLs    int `json:"failed_urls"`
+	CancelledURLs int `json:"cancelled_urls"`
+	// InProgress lists the progress of every running crawl
+	InProgress []CrawlProgress `json:"in_progress"`
 }
 
 // Status constants
@@ -62,4 +264,125 @@ const (
 	StatusRunning   = "running"
 	StatusCompleted = "completed"
 	StatusError     = "error"
+	// StatusFailed marks a URL whose retries have been exhausted
+	StatusFailed = "failed"
+	// StatusCancelled marks a URL whose crawl was cancelled by a user
+	StatusCancelled = "cancelled"
+)
+
+// Crawl attempt outcomes
+const (
+	AttemptRunning     = "running"
+	AttemptSucceeded   = "succeeded"
+	AttemptRetrying    = "retrying"
+	AttemptFailed      = "failed"
+	AttemptError       = "error"
+	AttemptInterrupted = "interrupted"
+	AttemptCancelled   = "cancelled"
+)
+
+// Crawl error classes
+const (
+	ErrorClassNetwork     = "network"
+	ErrorClassServer      = "server_error"
+	ErrorClassRateLimited = "rate_limited"
+	ErrorClassPermanent   = "permanent"
+)
+
+// Link issue types
+const (
+	LinkIssueInvalidMailto  = "invalid_mailto"
+	LinkIssueJavascriptLink = "javascript_link"
+)
+
+// Link scopes
+const (
+	LinkScopeHost   = "host"
+	LinkScopeDomain = "domain"
+	LinkScopeCustom = "custom"
+)
+
+// Link check modes
+const (
+	LinkCheckAll      = "all"
+	LinkCheckLimit    = "limit"
+	LinkCheckInternal = "internal"
+	LinkCheckSample   = "sample"
+)
+
+// Crawl event types
+const (
+	EventCrawlQueued    = "crawl.queued"
+	EventCrawlStarted   = "crawl.started"
+	EventCrawlProgress  = "crawl.progress"
+	EventCrawlCompleted = "crawl.completed"
+	EventCrawlFailed    = "crawl.failed"
+	EventCrawlCancelled = "crawl.cancelled"
+)
+
+// Crawl progress phases
+const (
+	ProgressFetching      = "fetching"
+	ProgressParsing       = "parsing"
+	ProgressCheckingLinks = "checking_links"
+)
+
+// Crawl priorities, highest first
+const (
+	PriorityInteractive = "interactive"
+	PriorityBulk        = "bulk"
+	PriorityScheduled   = "scheduled"
+)
+
+// Export formats
+const (
+	ExportCSV   = "csv"
+	ExportJSONL = "jsonl"
+	ExportXLSX  = "xlsx"
+)
+
+// Per-crawl detail that can be included in exports
+const (
+	ExportBrokenLinks    = "broken_links"
+	ExportLinkIssues     = "link_issues"
+	ExportLinkChecks     = "link_checks"
+	ExportAttemptHistory = "attempt_history"
+)
+
+// WorkerPoolStats describes the crawl worker pool of this process.
+type WorkerPoolStats struct {
+	WorkerID      string `json:"worker_id"`
+	Workers       int    `json:"workers"`
+	ActiveWorkers int    `json:"active_workers"`
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+}
+
+// CrawlSchedule requeues a group of URLs on a cron schedule. A schedule for
+// a single URL has one entry in URLIDs.
+type CrawlSchedule struct {
+	ID             int        `json:"id" db:"id"`
+	ProjectID      int        `json:"project_id" db:"project_id"`
+	Name           string     `json:"name" db:"name"`
+	CronExpression string     `json:"cron_expression" db:"cron_expression"`
+	Timezone       string     `json:"timezone" db:"timezone"`
+	Status         string     `json:"status" db:"status"`
+	URLIDs         []int      `json:"url_ids"`
+	LastRunAt      *time.Time `json:"last_run_at" db:"last_run_at"`
+	NextRunAt      *time.Time `json:"next_run_at" db:"next_run_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateScheduleRequest struct {
+	Name           string `json:"name" validate:"max=255"`
+	CronExpression string `json:"cron_expression" validate:"required"`
+	Timezone       string `json:"timezone" validate:"omitempty,timezone"`
+	URLIDs         []int  `json:"url_ids" validate:"required,min=1"`
+}
+
+// Schedule statuses
+const (
+	ScheduleActive = "active"
+	SchedulePaused = "paused"
 )
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// ImportJob is an uploaded file of URLs being added in the background.
+// Every row of the file is reported in its ImportRows.
+type ImportJob struct {
+	ID        int    `json:"id" db:"id"`
+	OwnerID   *int   `json:"owner_id" db:"owner_id"`
+	ProjectID int    `json:"project_id" db:"project_id"`
+	Filename  string `json:"filename" db:"filename"`
+	Format    string `json:"format" db:"format"`
+	Status    string `json:"status" db:"status"`
+	// Priority and Profile apply to rows that do not set their own
+	Priority      string     `json:"priority" db:"priority"`
+	Profile       string     `json:"profile,omitempty" db:"profile"`
+	TotalRows     int        `json:"total_rows" db:"total_rows"`
+	ProcessedRows int        `json:"processed_rows"`
+	AcceptedRows  int        `json:"accepted_rows" db:"accepted_rows"`
+	DuplicateRows int        `json:"duplicate_rows" db:"duplicate_rows"`
+	RejectedRows  int        `json:"rejected_rows" db:"rejected_rows"`
+	ErrorMessage  string     `json:"error_message,omitempty" db:"error_message"`
+	StartedAt     *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ImportRow reports what became of one row of an imported file.
+type ImportRow struct {
+	ID          int       `json:"id" db:"id"`
+	ImportJobID int       `json:"import_job_id" db:"import_job_id"`
+	LineNumber  int       `json:"line_number" db:"line_number"`
+	URL         string    `json:"url" db:"url"`
+	Result      string    `json:"result" db:"result"`
+	Reason      string    `json:"reason,omitempty" db:"reason"`
+	CrawlURLID  *int      `json:"crawl_url_id" db:"crawl_url_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Import job statuses
+const (
+	ImportPending    = "pending"
+	ImportProcessing = "processing"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+)
+
+// Import file formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatText = "text"
+)
+
+// Import row results
+const (
+	ImportRowAccepted  = "accepted"
+	ImportRowDuplicate = "duplicate"
+	ImportRowRejected  = "rejected"
+)
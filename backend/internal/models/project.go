@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// Project is a workspace that owns crawl URLs and everything built on them:
+// schedules, imports and webhook subscriptions. Only its members can see or
+// change them.
+type Project struct {
+	ID   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// Role is the requesting user's role in the project
+	Role      string    `json:"role,omitempty"`
+	CreatedBy *int      `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ProjectMember is a user's membership of a project.
+type ProjectMember struct {
+	ProjectID int       `json:"project_id" db:"project_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateProjectRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type AddProjectMemberRequest struct {
+	UserID int    `json:"user_id" validate:"required,min=1"`
+	Role   string `json:"role" validate:"omitempty,oneof=owner member"`
+}
+
+// ProjectScope limits a request to the projects UserID is a member of, and
+// to ProjectID alone if it is set. A scope without a user matches nothing.
+type ProjectScope struct {
+	UserID    int
+	ProjectID int
+}
+
+// Project roles. Owners manage the project and its members; members use
+// its crawler.
+const (
+	ProjectRoleOwner  = "owner"
+	ProjectRoleMember = "member"
+)
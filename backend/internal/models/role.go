@@ -0,0 +1,17 @@
+package models
+
+// User roles. Admins manage users and roles, editors add and change crawl
+// data, and viewers only read it. A user's role is carried in the role
+// claim of their token.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Roles lists the user roles, most privileged first.
+var Roles = []string{RoleAdmin, RoleEditor, RoleViewer}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin editor viewer"`
+}
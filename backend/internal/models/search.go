@@ -0,0 +1,28 @@
+package models
+
+// PageContent is the searchable text of a crawled page.
+type PageContent struct {
+	CrawlURLID      int    `json:"crawl_url_id" db:"crawl_url_id"`
+	Title           string `json:"title" db:"page_title"`
+	MetaDescription string `json:"meta_description" db:"meta_description"`
+	BodyText        string `json:"body_text" db:"body_text"`
+}
+
+// PageSearchResult is a crawled page matching a full-text search, ordered
+// by Score, the relevance MySQL assigned to the match.
+type PageSearchResult struct {
+	CrawlURL        CrawlURL       `json:"crawl_url"`
+	Score           float64        `json:"score"`
+	MetaDescription string         `json:"meta_description"`
+	Highlights      PageHighlights `json:"highlights"`
+	// BodyText is only read to cut the body highlight from
+	BodyText string `json:"-"`
+}
+
+// PageHighlights are HTML-escaped excerpts of a matching page with the
+// search terms wrapped in <mark> tags. Fields without a match are empty.
+type PageHighlights struct {
+	Title           string `json:"title,omitempty"`
+	MetaDescription string `json:"meta_description,omitempty"`
+	Body            string `json:"body,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookSubscription receives a signed POST for each crawl event of the
+// subscribed types. Secret is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID         int       `json:"id" db:"id"`
+	ProjectID  int       `json:"project_id" db:"project_id"`
+	URL        string    `json:"url" db:"url"`
+	EventTypes []string  `json:"event_types" db:"event_types"`
+	Secret     string    `json:"secret,omitempty" db:"secret"`
+	Active     bool      `json:"active" db:"active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" validate:"required,http_url"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=crawl.completed crawl.failed"`
+	// Secret signs the payloads; one is generated if it is left empty
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one event queued for, or delivered to, a subscription.
+// Pending deliveries form the outbox; all deliveries form its delivery log.
+type WebhookDelivery struct {
+	ID             int        `json:"id" db:"id"`
+	SubscriptionID int        `json:"subscription_id" db:"subscription_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Payload        string     `json:"payload" db:"payload"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode int        `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string     `json:"last_error,omitempty" db:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookPayload is the JSON body POSTed to subscribers.
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	CrawlURL  CrawlURL  `json:"crawl_url"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
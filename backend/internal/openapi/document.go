@@ -0,0 +1,705 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document. Request
+// and response schemas are derived from the models the handlers use, so
+// the document follows changes to their JSON and validate tags.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/service"
+)
+
+// operation is one route of the API.
+type operation struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	// public operations do not require a bearer token, and roles lists the
+	// user roles allowed to call the others if not all are
+	public bool
+	roles  []string
+	params []Schema
+	// body is the JSON request body, form a multipart/form-data one
+	body interface{}
+	form Schema
+	// status and result describe the success response. content defaults
+	// to application/json.
+	status  int
+	result  Schema
+	content map[string]Schema
+	// errors lists the error statuses besides 400, 401 and 500
+	errors []int
+}
+
+const basePath = "/api"
+
+var (
+	document     Schema
+	documentOnce sync.Once
+	paramPattern = regexp.MustCompile(`:(\w+)`)
+)
+
+// Document returns the OpenAPI document of the API.
+func Document() Schema {
+	documentOnce.Do(func() {
+		document = build(operations(newRegistry()))
+	})
+	return document
+}
+
+// Documented reports whether the document describes a route as registered
+// with Fiber, such as "GET /api/crawler/urls/:id".
+func Documented(method, path string) bool {
+	path = strings.TrimPrefix(path, basePath)
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	path = paramPattern.ReplaceAllString(path, "{$1}")
+
+	paths := Document()["paths"].(Schema)
+	item, ok := paths[path].(Schema)
+	if !ok {
+		return false
+	}
+	_, ok = item[strings.ToLower(method)]
+	return ok
+}
+
+func operations(r *registry) ([]operation, *registry) {
+	idParam := Schema{"name": "id", "in": "path", "required": true, "schema": Schema{"type": "integer"}}
+	pageParams := []Schema{
+		queryParam("page", "Page number, starting at 1", Schema{"type": "integer", "minimum": 1, "default": 1}),
+		queryParam("limit", "Items per page", Schema{"type": "integer", "minimum": 1, "maximum": 100, "default": 20}),
+	}
+	listParams := crawlURLListParams()
+	sessionResult := object(Schema{
+		"token":         Schema{"type": "string", "description": "Access token, sent as the bearer token"},
+		"refresh_token": Schema{"type": "string"},
+		"expires_in":    Schema{"type": "integer", "description": "Seconds until the access token expires"},
+		"message":       Schema{"type": "string"},
+	})
+
+	ops := []operation{
+		{
+			method: http.MethodGet, path: "/openapi.json", tag: "Meta", public: true,
+			summary: "Get this OpenAPI document",
+			result:  Schema{"type": "object"},
+		},
+
+		// Authentication and users
+		{
+			method: http.MethodPost, path: "/login", tag: "Auth", public: true,
+			summary: "Log in and receive an access token and a refresh token",
+			body:    service.Credentials{},
+			result:  sessionResult,
+		},
+		{
+			method: http.MethodPost, path: "/refresh", tag: "Auth", public: true,
+			summary: "Exchange a refresh token for a new access token and refresh token. Each refresh token works once.",
+			body:    service.RefreshRequest{},
+			result:  sessionResult,
+			errors:  []int{http.StatusUnauthorized},
+		},
+		{
+			method: http.MethodPost, path: "/logout", tag: "Auth",
+			summary: "Revoke the caller's access token and the refresh token issued with it",
+			result:  message(),
+		},
+		{
+			method: http.MethodPost, path: "/logout/all", tag: "Auth",
+			summary: "Revoke every session of the caller",
+			result:  message(),
+		},
+		{
+			method: http.MethodGet, path: "/password/policy", tag: "Auth", public: true,
+			summary: "Get the rules new passwords have to meet",
+			result:  data(r.of(service.PasswordPolicy{})),
+		},
+		{
+			method: http.MethodPost, path: "/password/change", tag: "Auth",
+			summary: "Change the caller's password. All of the caller's sessions end and a new one is returned.",
+			body:    service.ChangePasswordRequest{},
+			result:  sessionResult,
+		},
+		{
+			method: http.MethodPost, path: "/password/forgot", tag: "Auth", public: true,
+			summary: "Mail a password reset token to a registered email address. The response does not reveal whether it is registered.",
+			body:    service.ForgotPasswordRequest{},
+			status:  http.StatusAccepted,
+			result:  message(),
+		},
+		{
+			method: http.MethodPost, path: "/password/reset", tag: "Auth", public: true,
+			summary: "Set a new password with a reset token. All of the user's sessions end.",
+			body:    service.ResetPasswordRequest{},
+			result:  message(),
+		},
+		{
+			method: http.MethodPost, path: "/users", tag: "Users", public: true,
+			summary: "Register a user",
+			body:    service.CreateUserRequest{},
+			status:  http.StatusCreated,
+			result:  data(r.of(service.User{})),
+		},
+		{
+			method: http.MethodGet, path: "/users", tag: "Users",
+			summary: "List users",
+			result: object(Schema{
+				"data":  r.of([]service.User{}),
+				"count": Schema{"type": "integer"},
+			}),
+		},
+		{
+			method: http.MethodGet, path: "/users/{id}", tag: "Users",
+			summary: "Get a user",
+			params:  []Schema{idParam},
+			result:  data(r.of(service.User{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPut, path: "/users/{id}", tag: "Users",
+			summary: "Update a user. Users may update themselves; updating others needs the admin role.",
+			params:  []Schema{idParam},
+			body:    service.UpdateUserRequest{},
+			result:  data(r.of(service.User{})),
+			errors:  []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			method: http.MethodDelete, path: "/users/{id}", tag: "Users",
+			summary: "Delete a user",
+			params:  []Schema{idParam},
+			roles:   []string{models.RoleAdmin},
+			result:  message(),
+			errors:  []int{http.StatusConflict},
+		},
+
+		// Projects
+		{
+			method: http.MethodPost, path: "/projects", tag: "Projects",
+			summary: "Create a project owned by the caller",
+			body:    models.CreateProjectRequest{},
+			status:  http.StatusCreated,
+			result:  data(r.of(models.Project{})),
+		},
+		{
+			method: http.MethodGet, path: "/projects", tag: "Projects",
+			summary: "List the caller's projects",
+			result:  data(r.of([]models.Project{})),
+		},
+		{
+			method: http.MethodGet, path: "/projects/{id}", tag: "Projects",
+			summary: "Get a project",
+			params:  []Schema{idParam},
+			result:  data(r.of(models.Project{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodDelete, path: "/projects/{id}", tag: "Projects",
+			summary: "Delete a project with its URLs, schedules, imports and webhooks. Owners only.",
+			params:  []Schema{idParam},
+			result:  message(),
+			errors:  []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/projects/{id}/members", tag: "Projects",
+			summary: "List a project's members",
+			params:  []Schema{idParam},
+			result:  data(r.of([]models.ProjectMember{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/projects/{id}/members", tag: "Projects",
+			summary: "Add a user to a project or change their role. Owners only.",
+			params:  []Schema{idParam},
+			body:    models.AddProjectMemberRequest{},
+			result:  data(r.of([]models.ProjectMember{})),
+			errors:  []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			method: http.MethodDelete, path: "/projects/{id}/members/{userId}", tag: "Projects",
+			summary: "Remove a member from a project. Owners may remove anyone; members may leave.",
+			params: []Schema{
+				idParam,
+				{"name": "userId", "in": "path", "required": true, "schema": Schema{"type": "integer"}},
+			},
+			result: message(),
+			errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+		},
+
+		// Crawl URLs
+		{
+			method: http.MethodPost, path: "/crawler/urls", tag: "Crawler",
+			summary: "Add a URL for crawling. A URL matching an existing entry returns that entry with status 200.",
+			body:    models.CrawlRequest{},
+			status:  http.StatusCreated,
+			result: object(Schema{
+				"data":      r.of(models.CrawlURL{}),
+				"duplicate": Schema{"type": "boolean"},
+				"message":   Schema{"type": "string"},
+			}),
+		},
+		{
+			method: http.MethodPost, path: "/crawler/urls/bulk", tag: "Crawler",
+			summary: "Add multiple URLs for crawling",
+			body:    models.BulkCrawlRequest{},
+			status:  http.StatusCreated,
+			result: object(Schema{
+				"data": r.of([]models.CrawlURL{}),
+				"duplicates": Schema{"type": "array", "items": object(Schema{
+					"submitted_url": Schema{"type": "string"},
+					"existing":      r.of(models.CrawlURL{}),
+				})},
+				"errors":  Schema{"type": "array", "items": Schema{"type": "string"}},
+				"message": Schema{"type": "string"},
+			}),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/urls", tag: "Crawler",
+			summary: "List crawl URLs",
+			params: append(append(pageParams,
+				queryParam("cursor", "The next_cursor of the previous page, to page by keyset instead of by page number", Schema{"type": "string"}),
+			), listParams...),
+			result: cursorPage(r.of([]models.CrawlURL{})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/urls/export", tag: "Crawler",
+			summary: "Export the crawl URLs matching the list filters",
+			params: append([]Schema{
+				queryParam("format", "File format", Schema{"type": "string", "default": models.ExportCSV, "enum": []string{
+					models.ExportCSV, models.ExportJSONL, models.ExportXLSX,
+				}}),
+				queryParam("include", "Comma-separated per-crawl detail to add: "+strings.Join([]string{
+					models.ExportBrokenLinks, models.ExportLinkIssues, models.ExportLinkChecks, models.ExportAttemptHistory,
+				}, ", "), Schema{"type": "string"}),
+			}, listParams...),
+			content: map[string]Schema{
+				"text/csv":             {"type": "string"},
+				"application/x-ndjson": {"type": "string"},
+				"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {"type": "string", "format": "binary"},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/crawler/urls/{id}", tag: "Crawler",
+			summary: "Get a crawl result with its broken links, link issues and attempt history",
+			params:  []Schema{idParam},
+			result:  data(r.of(models.CrawlResult{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/crawler/urls/{id}/crawl", tag: "Crawler",
+			summary: "Queue a URL for crawling",
+			params:  []Schema{idParam},
+			result:  message(),
+		},
+		{
+			method: http.MethodDelete, path: "/crawler/urls", tag: "Crawler",
+			summary: "Delete multiple URLs",
+			body:    models.BatchRequest{},
+			result:  message(),
+		},
+		{
+			method: http.MethodPost, path: "/crawler/urls/recrawl", tag: "Crawler",
+			summary: "Re-crawl multiple URLs",
+			body:    models.ReCrawlRequest{},
+			result:  message(),
+		},
+		{
+			method: http.MethodPost, path: "/crawler/urls/{id}/cancel", tag: "Crawler",
+			summary: "Cancel a queued or running crawl",
+			params:  []Schema{idParam},
+			result:  message(),
+			errors:  []int{http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			method: http.MethodPost, path: "/crawler/urls/cancel", tag: "Crawler",
+			summary: "Cancel the queued or running crawls of multiple URLs",
+			body:    models.BatchRequest{},
+			result: data(object(Schema{
+				"cancelled_ids": Schema{"type": "array", "items": Schema{"type": "integer"}},
+			})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/stats", tag: "Crawler",
+			summary: "Get crawl statistics",
+			result:  data(r.of(models.CrawlStats{})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/search", tag: "Crawler",
+			summary: "Search the title, meta description and text of crawled pages, most relevant first",
+			params: append(append([]Schema{
+				{"name": "q", "in": "query", "required": true, "description": "Search terms", "schema": Schema{"type": "string"}},
+			}, pageParams...), listParams[1:]...),
+			result: page(r.of([]models.PageSearchResult{})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/events", tag: "Crawler", public: true,
+			summary: "Stream crawl events as Server-Sent Events. EventSource clients pass their token in access_token.",
+			params: []Schema{
+				queryParam("access_token", "Bearer token, for clients that cannot set headers", Schema{"type": "string"}),
+				queryParam("ids", "Comma-separated URL IDs to limit the stream to", Schema{"type": "string"}),
+			},
+			content: map[string]Schema{
+				"text/event-stream": r.of(models.CrawlEvent{}),
+			},
+			errors: []int{http.StatusUnauthorized},
+		},
+
+		// Imports
+		{
+			method: http.MethodPost, path: "/crawler/imports", tag: "Imports",
+			summary: "Upload a CSV or text file of URLs to add in the background",
+			form: object(Schema{
+				"file":     Schema{"type": "string", "format": "binary"},
+				"format":   Schema{"type": "string", "enum": []string{models.ImportFormatCSV, models.ImportFormatText}},
+				"priority": Schema{"type": "string", "enum": []string{models.PriorityInteractive, models.PriorityBulk, models.PriorityScheduled}},
+				"profile":  Schema{"type": "string"},
+			}, "file"),
+			status: http.StatusAccepted,
+			result: data(r.of(models.ImportJob{})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/imports/{id}", tag: "Imports",
+			summary: "Get an import job's progress",
+			params:  []Schema{idParam},
+			result:  data(r.of(models.ImportJob{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/crawler/imports/{id}/rows", tag: "Imports",
+			summary: "Get an import job's per-row report",
+			params: []Schema{
+				idParam,
+				pageParams[0],
+				queryParam("limit", "Items per page", Schema{"type": "integer", "minimum": 1, "maximum": 500, "default": 50}),
+				queryParam("result", "Only rows with this result", Schema{"type": "string", "enum": []string{
+					models.ImportRowAccepted, models.ImportRowDuplicate, models.ImportRowRejected,
+				}}),
+			},
+			result: page(r.of([]models.ImportRow{})),
+			errors: []int{http.StatusNotFound},
+		},
+
+		// Schedules
+		{
+			method: http.MethodPost, path: "/crawler/schedules", tag: "Schedules",
+			summary: "Create a recurring crawl",
+			body:    models.CreateScheduleRequest{},
+			status:  http.StatusCreated,
+			result:  data(r.of(models.CrawlSchedule{})),
+		},
+		{
+			method: http.MethodGet, path: "/crawler/schedules", tag: "Schedules",
+			summary: "List schedules",
+			result:  data(r.of([]models.CrawlSchedule{})),
+		},
+		{
+			method: http.MethodPost, path: "/crawler/schedules/{id}/pause", tag: "Schedules",
+			summary: "Pause a schedule",
+			params:  []Schema{idParam},
+			result:  data(r.of(models.CrawlSchedule{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodPost, path: "/crawler/schedules/{id}/resume", tag: "Schedules",
+			summary: "Resume a paused schedule",
+			params:  []Schema{idParam},
+			result:  data(r.of(models.CrawlSchedule{})),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodDelete, path: "/crawler/schedules/{id}", tag: "Schedules",
+			summary: "Delete a schedule",
+			params:  []Schema{idParam},
+			result:  message(),
+			errors:  []int{http.StatusNotFound},
+		},
+
+		// Webhooks
+		{
+			method: http.MethodPost, path: "/webhooks", tag: "Webhooks",
+			summary: "Subscribe to crawl events. The response is the only time the signing secret is returned.",
+			body:    models.CreateWebhookRequest{},
+			status:  http.StatusCreated,
+			result:  data(r.of(models.WebhookSubscription{})),
+		},
+		{
+			method: http.MethodGet, path: "/webhooks", tag: "Webhooks",
+			summary: "List webhook subscriptions",
+			result:  data(r.of([]models.WebhookSubscription{})),
+		},
+		{
+			method: http.MethodDelete, path: "/webhooks/{id}", tag: "Webhooks",
+			summary: "Delete a subscription and its delivery log",
+			params:  []Schema{idParam},
+			result:  message(),
+			errors:  []int{http.StatusNotFound},
+		},
+		{
+			method: http.MethodGet, path: "/webhooks/{id}/deliveries", tag: "Webhooks",
+			summary: "Get a subscription's delivery log, newest first",
+			params:  append([]Schema{idParam}, pageParams...),
+			result:  page(r.of([]models.WebhookDelivery{})),
+			errors:  []int{http.StatusNotFound},
+		},
+
+		// Admin
+		{
+			method: http.MethodGet, path: "/admin/crawler/pool", tag: "Admin",
+			summary: "Get the worker pool's queue depth and active workers",
+			result:  data(r.of(models.WorkerPoolStats{})),
+		},
+		{
+			method: http.MethodPut, path: "/admin/users/{id}/role", tag: "Admin",
+			summary: "Assign a user's role, which takes effect when they next log in or refresh their session",
+			params:  []Schema{idParam},
+			body:    models.AssignRoleRequest{},
+			result:  data(r.of(service.User{})),
+			errors:  []int{http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			method: http.MethodPost, path: "/admin/users/{id}/password", tag: "Admin",
+			summary: "End all sessions of a user and set their password, or without a password clear it and mail them a reset link",
+			params:  []Schema{idParam},
+			body:    service.AdminResetPasswordRequest{},
+			result:  message(),
+			errors:  []int{http.StatusNotFound, http.StatusConflict},
+		},
+	}
+
+	// Crawler and webhook routes act on the caller's projects, optionally
+	// narrowed to one
+	projectParam := queryParam("project_id", "Only this project, which new URLs, imports, schedules and webhooks are added to. "+
+		"Defaults to all of the caller's projects, and to the oldest of them for additions.", Schema{"type": "integer"})
+	for i, op := range ops {
+		if strings.HasPrefix(op.path, "/crawler/") || strings.HasPrefix(op.path, "/webhooks") {
+			ops[i].params = append([]Schema{projectParam}, op.params...)
+			if !containsStatus(op.errors, http.StatusNotFound) {
+				ops[i].errors = append(op.errors, http.StatusNotFound)
+			}
+		}
+	}
+
+	// Viewers only read crawl data, and only admins reach the admin routes
+	for i, op := range ops {
+		switch {
+		case strings.HasPrefix(op.path, "/admin/"):
+			ops[i].roles = []string{models.RoleAdmin}
+		case op.method != http.MethodGet && (strings.HasPrefix(op.path, "/crawler/") ||
+			strings.HasPrefix(op.path, "/webhooks") || strings.HasPrefix(op.path, "/projects")):
+			ops[i].roles = []string{models.RoleAdmin, models.RoleEditor}
+		}
+	}
+
+	return ops, r
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func build(ops []operation, r *registry) Schema {
+	paths := Schema{}
+	for _, op := range ops {
+		item, ok := paths[op.path].(Schema)
+		if !ok {
+			item = Schema{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = buildOperation(op, r)
+	}
+
+	r.schemas["Error"] = object(Schema{"error": Schema{"type": "string"}}, "error")
+	r.schemas["ValidationError"] = object(Schema{
+		"error": Schema{"type": "string"},
+		"fields": Schema{
+			"type":                 "object",
+			"description":          "A message for each invalid field, keyed by its JSON path such as urls[2]",
+			"additionalProperties": Schema{"type": "string"},
+		},
+	}, "error")
+
+	return Schema{
+		"openapi": "3.0.3",
+		"info": Schema{
+			"title":       "Sykell Web Crawler API",
+			"description": "Queue URLs for crawling and read back their page analysis.",
+			"version":     "1.0.0",
+		},
+		"servers": []Schema{{"url": basePath}},
+		"paths":   paths,
+		"components": Schema{
+			"schemas": r.schemas,
+			"securitySchemes": Schema{
+				"bearerAuth": Schema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []Schema{{"bearerAuth": []string{}}},
+	}
+}
+
+func buildOperation(op operation, r *registry) Schema {
+	result := Schema{
+		"tags":        []string{op.tag},
+		"summary":     op.summary,
+		"operationId": operationID(op),
+	}
+	if op.public {
+		result["security"] = []Schema{}
+	}
+	if len(op.params) > 0 {
+		result["parameters"] = op.params
+	}
+
+	badRequest := errorResponse("Invalid request", "Error")
+	switch {
+	case op.body != nil:
+		result["requestBody"] = Schema{
+			"required": true,
+			"content":  Schema{"application/json": Schema{"schema": r.of(op.body)}},
+		}
+		badRequest = errorResponse("Invalid request body or fields", "ValidationError")
+	case op.form != nil:
+		result["requestBody"] = Schema{
+			"required": true,
+			"content":  Schema{"multipart/form-data": Schema{"schema": op.form}},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	content := Schema{}
+	for contentType, schema := range op.content {
+		content[contentType] = Schema{"schema": schema}
+	}
+	if op.result != nil {
+		content["application/json"] = Schema{"schema": op.result}
+	}
+
+	responses := Schema{
+		strconv.Itoa(status): Schema{"description": http.StatusText(status), "content": content},
+		"400":                badRequest,
+		"500":                errorResponse(http.StatusText(http.StatusInternalServerError), "Error"),
+	}
+	if !op.public {
+		responses["401"] = errorResponse("Missing or invalid bearer token", "Error")
+	}
+	if len(op.roles) > 0 {
+		result["description"] = "Requires the " + strings.Join(op.roles, " or ") + " role."
+		responses["403"] = errorResponse(http.StatusText(http.StatusForbidden), "Error")
+	}
+	for _, code := range op.errors {
+		responses[strconv.Itoa(code)] = errorResponse(http.StatusText(code), "Error")
+	}
+	result["responses"] = responses
+
+	return result
+}
+
+// operationID names an operation after its method and path, such as
+// getCrawlerUrlsId, for generated clients.
+func operationID(op operation) string {
+	id := strings.ToLower(op.method)
+	for _, part := range strings.FieldsFunc(op.path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '_'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func object(properties Schema, required ...string) Schema {
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func data(schema Schema) Schema {
+	return object(Schema{"data": schema, "message": Schema{"type": "string"}}, "data")
+}
+
+func page(schema Schema) Schema {
+	return object(Schema{
+		"data": schema,
+		"pagination": object(Schema{
+			"page":  Schema{"type": "integer"},
+			"limit": Schema{"type": "integer"},
+			"total": Schema{"type": "integer"},
+			"pages": Schema{"type": "integer"},
+		}, "page", "limit", "total", "pages"),
+	}, "data", "pagination")
+}
+
+// cursorPage is a page of the URL list, which can also be paged by cursor.
+func cursorPage(schema Schema) Schema {
+	result := page(schema)
+	pagination := result["properties"].(Schema)["pagination"].(Schema)
+	pagination["properties"].(Schema)["next_cursor"] = Schema{
+		"type":        "string",
+		"nullable":    true,
+		"description": "Pass as cursor to read the next page; null on the last page",
+	}
+	return result
+}
+
+// crawlURLListParams are the filter and sort parameters shared by the URL
+// list and export.
+func crawlURLListParams() []Schema {
+	sortColumns := append(append([]string{}, models.CrawlURLSortColumns...), models.CrawlURLMetricColumns...)
+	params := []Schema{
+		queryParam("sort", "Comma-separated columns to sort on, each prefixed with - to sort descending. Defaults to -created_at. Columns: "+
+			strings.Join(sortColumns, ", "), Schema{"type": "string"}),
+		queryParam("status", "Only URLs with one of these comma-separated statuses: "+strings.Join([]string{
+			models.StatusQueued, models.StatusRunning, models.StatusCompleted,
+			models.StatusError, models.StatusFailed, models.StatusCancelled,
+		}, ", "), Schema{"type": "string"}),
+		queryParam("search", "Only URLs or titles containing this text", Schema{"type": "string"}),
+		queryParam("html_version", "Only URLs with this HTML version", Schema{"type": "string"}),
+		queryParam("has_login_form", "Only URLs with or without a login form", Schema{"type": "boolean"}),
+		queryParam("tag", "Only URLs with this tag", Schema{"type": "string"}),
+		queryParam("crawled_from", "Only URLs last crawled at or after this RFC 3339 time or date", Schema{"type": "string"}),
+		queryParam("crawled_to", "Only URLs last crawled before this time, or on or before this date", Schema{"type": "string"}),
+		queryParam("created_from", "Only URLs added at or after this RFC 3339 time or date", Schema{"type": "string"}),
+		queryParam("created_to", "Only URLs added before this time, or on or before this date", Schema{"type": "string"}),
+	}
+
+	for _, column := range models.CrawlURLMetricColumns {
+		params = append(params,
+			queryParam(column, "Only URLs with exactly this "+column, Schema{"type": "integer"}),
+			queryParam(column+"_min", "Only URLs with at least this "+column, Schema{"type": "integer"}),
+			queryParam(column+"_max", "Only URLs with at most this "+column, Schema{"type": "integer"}),
+		)
+	}
+	return params
+}
+
+func message() Schema {
+	return object(Schema{"message": Schema{"type": "string"}}, "message")
+}
+
+func errorResponse(description, schema string) Schema {
+	return Schema{
+		"description": description,
+		"content": Schema{"application/json": Schema{
+			"schema": Schema{"$ref": "#/components/schemas/" + schema},
+		}},
+	}
+}
+
+func queryParam(name, description string, schema Schema) Schema {
+	return Schema{"name": name, "in": "query", "description": description, "schema": schema}
+}
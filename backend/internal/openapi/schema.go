@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object.
+type Schema map[string]interface{}
+
+// registry builds schemas from Go types. Named structs are stored once under
+// components/schemas and referenced by name, so the document describes the
+// JSON encoding of the models the handlers actually return.
+type registry struct {
+	schemas map[string]Schema
+}
+
+func newRegistry() *registry {
+	return &registry{schemas: map[string]Schema{}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// of returns the schema of the type of v.
+func (r *registry) of(v interface{}) Schema {
+	return r.schema(reflect.TypeOf(v))
+}
+
+func (r *registry) schema(t reflect.Type) Schema {
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := r.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return Schema{"allOf": []Schema{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.object(t)
+		}
+		if _, exists := r.schemas[t.Name()]; !exists {
+			// Reserve the name first so that recursive types terminate
+			r.schemas[t.Name()] = Schema{}
+			r.schemas[t.Name()] = r.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": r.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": r.schema(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	}
+
+	return Schema{"type": "string"}
+}
+
+// object describes a struct by its JSON fields. Embedded structs without a
+// JSON name are flattened into it, as encoding/json does.
+func (r *registry) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	r.addFields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (r *registry) addFields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			r.addFields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := r.schema(field.Type)
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		for j, rule := range rules {
+			if rule == "dive" {
+				if items, ok := schema["items"].(Schema); ok {
+					applyRules(items, field.Type.Elem().Kind(), rules[j+1:])
+				}
+				rules = rules[:j]
+				break
+			}
+		}
+		applyRules(schema, field.Type.Kind(), rules)
+
+		for _, rule := range rules {
+			if rule == "required" {
+				*required = append(*required, name)
+			}
+		}
+		properties[name] = schema
+	}
+}
+
+// applyRules carries the validate tags a request is checked against over to
+// its schema.
+func applyRules(schema Schema, kind reflect.Kind, rules []string) {
+	if _, isRef := schema["$ref"]; isRef {
+		return
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "url", "http_url":
+			schema["format"] = "uri"
+		case "email":
+			schema["format"] = "email"
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			schema[boundKeyword(name, kind)] = n
+		}
+	}
+}
+
+func boundKeyword(rule string, kind reflect.Kind) string {
+	var keyword string
+	switch kind {
+	case reflect.String:
+		keyword = "Length"
+	case reflect.Slice, reflect.Array:
+		keyword = "Items"
+	case reflect.Map:
+		keyword = "Properties"
+	default:
+		if rule == "min" {
+			return "minimum"
+		}
+		return "maximum"
+	}
+	return rule + keyword
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+)
+
+// Kinds of sort key values, which decide how a cursor's values are parsed.
+const (
+	sortKeyInt = iota
+	sortKeyString
+	sortKeyTime
+)
+
+// crawlURLListColumn is a crawl_urls column that listings can be sorted and
+// filtered on. Nullable columns are coalesced so that sorting and keyset
+// comparisons agree on where NULLs go.
+type crawlURLListColumn struct {
+	expr  string
+	kind  int
+	value func(u *models.CrawlURL) interface{}
+}
+
+// nullTime stands in for a NULL last_crawled_at in sort keys.
+var nullTime = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
+
+var crawlURLListColumns = map[string]crawlURLListColumn{
+	"id":           {"id", sortKeyInt, func(u *models.CrawlURL) interface{} { return u.ID }},
+	"url":          {"url", sortKeyString, func(u *models.CrawlURL) interface{} { return u.URL }},
+	"title":        {"COALESCE(title, '')", sortKeyString, func(u *models.CrawlURL) interface{} { return u.Title }},
+	"html_version": {"COALESCE(html_version, '')", sortKeyString, func(u *models.CrawlURL) interface{} { return u.HTMLVersion }},
+	// status is an ENUM, which sorts by its declaration order but compares
+	// as text, so it is sorted as text too
+	"status": {"CAST(status AS CHAR)", sortKeyString, func(u *models.CrawlURL) interface{} { return u.Status }},
+	"has_login_form": {"has_login_form", sortKeyInt, func(u *models.CrawlURL) interface{} {
+		if u.HasLoginForm {
+			return 1
+		}
+		return 0
+	}},
+	"last_crawled_at": {"COALESCE(last_crawled_at, TIMESTAMP('1970-01-01'))", sortKeyTime, func(u *models.CrawlURL) interface{} {
+		if u.LastCrawledAt == nil {
+			return nullTime
+		}
+		return *u.LastCrawledAt
+	}},
+	"created_at": {"created_at", sortKeyTime, func(u *models.CrawlURL) interface{} { return u.CreatedAt }},
+	"updated_at": {"updated_at", sortKeyTime, func(u *models.CrawlURL) interface{} { return u.UpdatedAt }},
+}
+
+func init() {
+	for _, column := range models.CrawlURLMetricColumns {
+		column := column
+		crawlURLListColumns[column] = crawlURLListColumn{column, sortKeyInt, func(u *models.CrawlURL) interface{} {
+			return crawlURLMetric(u, column)
+		}}
+	}
+}
+
+func crawlURLMetric(u *models.CrawlURL, column string) int {
+	switch column {
+	case "h1_count":
+		return u.H1Count
+	case "h2_count":
+		return u.H2Count
+	case "h3_count":
+		return u.H3Count
+	case "h4_count":
+		return u.H4Count
+	case "h5_count":
+		return u.H5Count
+	case "h6_count":
+		return u.H6Count
+	case "internal_links_count":
+		return u.InternalLinksCount
+	case "external_links_count":
+		return u.ExternalLinksCount
+	case "inaccessible_links_count":
+		return u.InaccessibleLinksCount
+	case "checked_links_count":
+		return u.CheckedLinksCount
+	case "cached_links_count":
+		return u.CachedLinksCount
+	case "unchecked_links_count":
+		return u.UncheckedLinksCount
+	case "mailto_links_count":
+		return u.MailtoLinksCount
+	case "tel_links_count":
+		return u.TelLinksCount
+	case "javascript_links_count":
+		return u.JavascriptLinksCount
+	case "data_links_count":
+		return u.DataLinksCount
+	case "other_scheme_links_count":
+		return u.OtherSchemeLinksCount
+	case "attempts":
+		return u.Attempts
+	}
+	return 0
+}
+
+// crawlURLFilter builds the WHERE clause shared by the URL list, exports
+// and page search. It always limits the URLs to filter.Scope. Columns the
+// service has not validated are ignored.
+func crawlURLFilter(filter models.CrawlURLFilter) (string, []interface{}) {
+	scope, args := projectCondition("project_id", filter.Scope)
+	whereClause := []string{scope}
+
+	if len(filter.Statuses) > 0 {
+		placeholders := strings.Repeat("?,", len(filter.Statuses)-1) + "?"
+		whereClause = append(whereClause, fmt.Sprintf("status IN (%s)", placeholders))
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	if filter.Search != "" {
+		whereClause = append(whereClause, "(url LIKE ? OR title LIKE ?)")
+		searchPattern := "%" + filter.Search + "%"
+		args = append(args, searchPattern, searchPattern)
+	}
+
+	if filter.HTMLVersion != "" {
+		whereClause = append(whereClause, "html_version = ?")
+		args = append(args, filter.HTMLVersion)
+	}
+
+	if filter.HasLoginForm != nil {
+		whereClause = append(whereClause, "has_login_form = ?")
+		args = append(args, *filter.HasLoginForm)
+	}
+
+	if filter.Tag != "" {
+		whereClause = append(whereClause, "FIND_IN_SET(?, tags) > 0")
+		args = append(args, filter.Tag)
+	}
+
+	for _, metric := range filter.Ranges {
+		column, ok := crawlURLListColumns[metric.Column]
+		if !ok || column.kind != sortKeyInt {
+			continue
+		}
+		if metric.Min != nil {
+			whereClause = append(whereClause, column.expr+" >= ?")
+			args = append(args, *metric.Min)
+		}
+		if metric.Max != nil {
+			whereClause = append(whereClause, column.expr+" <= ?")
+			args = append(args, *metric.Max)
+		}
+	}
+
+	for _, bound := range []struct {
+		condition string
+		value     *time.Time
+	}{
+		{"last_crawled_at >= ?", filter.CrawledFrom},
+		{"last_crawled_at < ?", filter.CrawledTo},
+		{"created_at >= ?", filter.CreatedFrom},
+		{"created_at < ?", filter.CreatedTo},
+	} {
+		if bound.value != nil {
+			whereClause = append(whereClause, bound.condition)
+			args = append(args, *bound.value)
+		}
+	}
+
+	return "WHERE " + strings.Join(whereClause, " AND "), args
+}
+
+// crawlURLOrder builds the ORDER BY clause of a listing.
+func crawlURLOrder(sort []models.SortField) string {
+	var order []string
+	for _, field := range sort {
+		column, ok := crawlURLListColumns[field.Column]
+		if !ok {
+			continue
+		}
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
+		}
+		order = append(order, column.expr+" "+direction)
+	}
+
+	if len(order) == 0 {
+		return "ORDER BY created_at DESC, id DESC"
+	}
+	return "ORDER BY " + strings.Join(order, ", ")
+}
+
+// crawlURLKeyset builds the condition selecting the rows that sort after the
+// row whose sort key is after. For a sort on (a, b) that is
+// a > ? OR (a = ? AND b > ?), with < for descending columns.
+func crawlURLKeyset(sort []models.SortField, after []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for i, field := range sort {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, crawlURLListColumns[sort[j].Column].expr+" = ?")
+			args = append(args, after[j])
+		}
+
+		operator := ">"
+		if field.Desc {
+			operator = "<"
+		}
+		parts = append(parts, crawlURLListColumns[field.Column].expr+" "+operator+" ?")
+		args = append(args, after[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// CrawlURLSortKey returns the values of the sort columns of u, encoded as
+// text for a pagination cursor.
+func CrawlURLSortKey(u *models.CrawlURL, sort []models.SortField) []string {
+	key := make([]string, 0, len(sort))
+	for _, field := range sort {
+		switch value := crawlURLListColumns[field.Column].value(u).(type) {
+		case int:
+			key = append(key, strconv.Itoa(value))
+		case time.Time:
+			key = append(key, value.Format(time.RFC3339))
+		case string:
+			key = append(key, value)
+		}
+	}
+	return key
+}
+
+// ParseCrawlURLSortKey reverses CrawlURLSortKey, returning the values to
+// compare the sort columns with.
+func ParseCrawlURLSortKey(sort []models.SortField, key []string) ([]interface{}, error) {
+	if len(key) != len(sort) {
+		return nil, fmt.Errorf("sort key has %d values, expected %d", len(key), len(sort))
+	}
+
+	values := make([]interface{}, len(key))
+	for i, field := range sort {
+		column, ok := crawlURLListColumns[field.Column]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort column %q", field.Column)
+		}
+
+		switch column.kind {
+		case sortKeyInt:
+			n, err := strconv.Atoi(key[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", field.Column, key[i])
+			}
+			values[i] = n
+		case sortKeyTime:
+			t, err := time.Parse(time.RFC3339, key[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", field.Column, key[i])
+			}
+			values[i] = t
+		default:
+			values[i] = key[i]
+		}
+	}
+	return values, nil
+}
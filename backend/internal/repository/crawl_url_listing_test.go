@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sykell-backend/internal/models"
+)
+
+func TestCrawlURLKeyset(t *testing.T) {
+	tests := []struct {
+		name      string
+		sort      []models.SortField
+		after     []interface{}
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "single column",
+			sort:      []models.SortField{{Column: "id"}},
+			after:     []interface{}{42},
+			wantQuery: "((id > ?))",
+			wantArgs:  []interface{}{42},
+		},
+		{
+			name:      "descending",
+			sort:      []models.SortField{{Column: "created_at", Desc: true}, {Column: "id", Desc: true}},
+			after:     []interface{}{"t", 42},
+			wantQuery: "((created_at < ?) OR (created_at = ? AND id < ?))",
+			wantArgs:  []interface{}{"t", "t", 42},
+		},
+		{
+			name:      "mixed directions",
+			sort:      []models.SortField{{Column: "h1_count", Desc: true}, {Column: "title"}, {Column: "id"}},
+			after:     []interface{}{3, "Example", 42},
+			wantQuery: "((h1_count < ?) OR (h1_count = ? AND COALESCE(title, '') > ?) OR (h1_count = ? AND COALESCE(title, '') = ? AND id > ?))",
+			wantArgs:  []interface{}{3, 3, "Example", 3, "Example", 42},
+		},
+		{
+			name:      "expression columns",
+			sort:      []models.SortField{{Column: "status"}, {Column: "last_crawled_at"}},
+			after:     []interface{}{"queued", "t"},
+			wantQuery: "((CAST(status AS CHAR) > ?) OR (CAST(status AS CHAR) = ? AND COALESCE(last_crawled_at, TIMESTAMP('1970-01-01')) > ?))",
+			wantArgs:  []interface{}{"queued", "queued", "t"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := crawlURLKeyset(tt.sort, tt.after)
+			if query != tt.wantQuery {
+				t.Errorf("crawlURLKeyset() query = %s, want %s", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("crawlURLKeyset() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCrawlURLSortKey(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	crawlURL := &models.CrawlURL{
+		ID:                 42,
+		Title:              "Example",
+		InternalLinksCount: 7,
+		CreatedAt:          createdAt,
+	}
+
+	tests := []struct {
+		name string
+		sort []models.SortField
+		want []interface{}
+	}{
+		{"time and id", []models.SortField{{Column: "created_at", Desc: true}, {Column: "id", Desc: true}}, []interface{}{createdAt, 42}},
+		{"metric and text", []models.SortField{{Column: "internal_links_count"}, {Column: "title"}, {Column: "id"}}, []interface{}{7, "Example", 42}},
+		{"false boolean", []models.SortField{{Column: "has_login_form"}, {Column: "id"}}, []interface{}{0, 42}},
+		{"missing crawl time", []models.SortField{{Column: "last_crawled_at"}, {Column: "id"}}, []interface{}{nullTime, 42}},
+		{"missing text", []models.SortField{{Column: "html_version"}, {Column: "id"}}, []interface{}{"", 42}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := CrawlURLSortKey(crawlURL, tt.sort)
+			got, err := ParseCrawlURLSortKey(tt.sort, key)
+			if err != nil {
+				t.Fatalf("ParseCrawlURLSortKey(%v) error = %v", key, err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseCrawlURLSortKey(%v) = %v, want %v", key, got, tt.want)
+			}
+			for i := range got {
+				if wantTime, ok := tt.want[i].(time.Time); ok {
+					if gotTime, ok := got[i].(time.Time); !ok || !gotTime.Equal(wantTime) {
+						t.Errorf("value %d = %v, want %v", i, got[i], wantTime)
+					}
+				} else if got[i] != tt.want[i] {
+					t.Errorf("value %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseCrawlURLSortKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []models.SortField
+		key  []string
+	}{
+		{"too few values", []models.SortField{{Column: "title"}, {Column: "id"}}, []string{"a"}},
+		{"too many values", []models.SortField{{Column: "id"}}, []string{"1", "2"}},
+		{"unknown column", []models.SortField{{Column: "password"}}, []string{"x"}},
+		{"invalid number", []models.SortField{{Column: "id"}}, []string{"1.5"}},
+		{"invalid time", []models.SortField{{Column: "updated_at"}}, []string{"2024-03-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCrawlURLSortKey(tt.sort, tt.key); err == nil {
+				t.Errorf("ParseCrawlURLSortKey(%v) error = nil, want an error", tt.key)
+			}
+		})
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 
 	"sykell-backend/internal/models"
 	"sykell-backend/pkg/database"
 	"sykell-backend/pkg/logger"
 )
 
+// mysqlErrDuplicateEntry is ER_DUP_ENTRY, returned when a UNIQUE index is violated.
+const mysqlErrDuplicateEntry = 1062
+
 type CrawlerRepository struct {
 	db *sql.DB
 }
@@ -20,60 +26,62 @@ func NewCrawlerRepository() *CrawlerRepository {
 	}
 }
 
-func (r *CrawlerRepository) CreateCrawlURL(url string) (*models.CrawlURL, error) {
-	query := `
-		INSERT INTO crawl_urls (url, status) 
-		VALUES (?, ?)
-		ON DUPLICATE KEY UPDATE 
-			status = VALUES(status),
-			updated_at = CURRENT_TIMESTAMP
-	`
-
-	result, err := r.db.Exec(query, url, models.StatusQueued)
-	if err != nil {
-		logger.Sugar().Errorf("Failed to create crawl URL: %v", err)
-		return nil, err
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
-		return nil, err
-	}
+// crawlURLColumns lists the crawl_urls columns in the order scanCrawlURL expects.
+const crawlURLColumns = `id, url, normalized_url, status, link_scope, same_site_domains,
+	link_check_mode, link_check_limit, priority, tags, owner_id, project_id, title, html_version,
+	h1_count, h2_count, h3_count, h4_count, h5_count, h6_count,
+	internal_links_count, external_links_count, inaccessible_links_count,
+	checked_links_count, cached_links_count, unchecked_links_count, mailto_links_count, tel_links_count, javascript_links_count,
+	data_links_count, other_scheme_links_count,
+	has_login_form, error_message, last_crawled_at, attempts, next_attempt_at,
+	claimed_by, lease_expires_at, heartbeat_at,
+	progress_phase, progress_done, progress_total, progress_updated_at, created_at, updated_at`
 
-	return r.GetCrawlURLByID(int(id))
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func (r *CrawlerRepository) GetCrawlURLByID(id int) (*models.CrawlURL, error) {
-	query := `
-		SELECT id, url, status, title, html_version, 
-			   h1_count, h2_count, h3_count, h4_count, h5_count, h6_count,
-			   internal_links_count, external_links_count, inaccessible_links_count,
-			   has_login_form, error_message, last_crawled_at, created_at, updated_at
-		FROM crawl_urls WHERE id = ?
-	`
-
+func scanCrawlURL(row rowScanner) (*models.CrawlURL, error) {
 	var crawlURL models.CrawlURL
-	var title, htmlVersion, errorMessage sql.NullString
-	var lastCrawledAt sql.NullTime
+	var normalizedURL, sameSiteDomains, title, htmlVersion, errorMessage, claimedBy, progressPhase sql.NullString
+	var tags string
+	var lastCrawledAt, nextAttemptAt, leaseExpiresAt, heartbeatAt, progressUpdatedAt sql.NullTime
+	var progressDone, progressTotal int
+	var ownerID, projectID sql.NullInt64
 
-	err := r.db.QueryRow(query, id).Scan(
-		&crawlURL.ID, &crawlURL.URL, &crawlURL.Status, &title, &htmlVersion,
+	err := row.Scan(
+		&crawlURL.ID, &crawlURL.URL, &normalizedURL, &crawlURL.Status,
+		&crawlURL.LinkScope, &sameSiteDomains,
+		&crawlURL.LinkCheckMode, &crawlURL.LinkCheckLimit, &crawlURL.Priority, &tags, &ownerID, &projectID,
+		&title, &htmlVersion,
 		&crawlURL.H1Count, &crawlURL.H2Count, &crawlURL.H3Count, &crawlURL.H4Count,
 		&crawlURL.H5Count, &crawlURL.H6Count, &crawlURL.InternalLinksCount,
 		&crawlURL.ExternalLinksCount, &crawlURL.InaccessibleLinksCount,
-		&crawlURL.HasLoginForm, &errorMessage, &lastCrawledAt,
+		&crawlURL.CheckedLinksCount, &crawlURL.CachedLinksCount, &crawlURL.UncheckedLinksCount,
+		&crawlURL.MailtoLinksCount, &crawlURL.TelLinksCount, &crawlURL.JavascriptLinksCount,
+		&crawlURL.DataLinksCount, &crawlURL.OtherSchemeLinksCount,
+		&crawlURL.HasLoginForm, &errorMessage, &lastCrawledAt, &crawlURL.Attempts, &nextAttemptAt,
+		&claimedBy, &leaseExpiresAt, &heartbeatAt,
+		&progressPhase, &progressDone, &progressTotal, &progressUpdatedAt,
 		&crawlURL.CreatedAt, &crawlURL.UpdatedAt,
 	)
-
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		logger.Sugar().Errorf("Failed to get crawl URL by ID: %v", err)
 		return nil, err
 	}
 
+	if normalizedURL.Valid {
+		crawlURL.NormalizedURL = normalizedURL.String
+	}
+	if sameSiteDomains.Valid {
+		crawlURL.SameSiteDomains = splitList(sameSiteDomains.String)
+	}
+	crawlURL.Tags = splitList(tags)
+	if ownerID.Valid {
+		id := int(ownerID.Int64)
+		crawlURL.OwnerID = &id
+	}
+	crawlURL.ProjectID = int(projectID.Int64)
 	if title.Valid {
 		crawlURL.Title = title.String
 	}
@@ -86,30 +94,235 @@ func (r *CrawlerRepository) GetCrawlURLByID(id int) (*models.CrawlURL, error) {
 	if lastCrawledAt.Valid {
 		crawlURL.LastCrawledAt = &lastCrawledAt.Time
 	}
+	if nextAttemptAt.Valid {
+		crawlURL.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if claimedBy.Valid {
+		crawlURL.ClaimedBy = claimedBy.String
+	}
+	if leaseExpiresAt.Valid {
+		crawlURL.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	if heartbeatAt.Valid {
+		crawlURL.HeartbeatAt = &heartbeatAt.Time
+	}
+	// Progress columns keep the last crawl's values once it has finished
+	if crawlURL.Status == models.StatusRunning && progressPhase.Valid {
+		crawlURL.Progress = &models.CrawlProgress{
+			CrawlURLID:   crawlURL.ID,
+			URL:          crawlURL.URL,
+			Phase:        progressPhase.String,
+			LinksChecked: progressDone,
+			LinksTotal:   progressTotal,
+		}
+		if progressUpdatedAt.Valid {
+			crawlURL.Progress.UpdatedAt = progressUpdatedAt.Time
+		}
+	}
 
 	return &crawlURL, nil
 }
 
-func (r *CrawlerRepository) GetCrawlURLs(limit, offset int, status, search string) ([]models.CrawlURL, int, error) {
-	var whereClause []string
-	var args []interface{}
+// splitList decodes a comma-separated column value.
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
 
-	if status != "" {
-		whereClause = append(whereClause, "status = ?")
-		args = append(args, status)
+// CreateCrawlURL queues a new URL in a project on behalf of ownerID, or of
+// nobody if ownerID is 0. If a row with the same normalized URL already
+// exists in the project it is returned unchanged and duplicate is true.
+func (r *CrawlerRepository) CreateCrawlURL(url, normalizedURL string, ownerID, projectID int, opts models.CrawlOptions) (crawlURL *models.CrawlURL, duplicate bool, err error) {
+	existing, err := r.GetCrawlURLByNormalizedURL(projectID, normalizedURL)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, true, nil
 	}
 
-	if search != "" {
-		whereClause = append(whereClause, "(url LIKE ? OR title LIKE ?)")
-		searchPattern := "%" + search + "%"
-		args = append(args, searchPattern, searchPattern)
+	id, err := insertCrawlURL(r.db, url, normalizedURL, ownerID, projectID, opts)
+	if err != nil {
+		// Another request inserted the same URL since the lookup above
+		if isDuplicateEntry(err) {
+			existing, lookupErr := r.GetCrawlURLByNormalizedURL(projectID, normalizedURL)
+			if lookupErr != nil {
+				return nil, false, lookupErr
+			}
+			if existing != nil {
+				return existing, true, nil
+			}
+			logger.Sugar().Errorf("Failed to create crawl URL: %v", err)
+		}
+		return nil, false, err
 	}
 
-	whereSQL := ""
-	if len(whereClause) > 0 {
-		whereSQL = "WHERE " + strings.Join(whereClause, " AND ")
+	crawlURL, err = r.GetCrawlURLByID(id)
+	return crawlURL, false, err
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertCrawlURL inserts a queued crawl URL and returns its ID. Duplicate
+// URLs are left to the caller, which can tell them apart with
+// isDuplicateEntry.
+func insertCrawlURL(db execer, url, normalizedURL string, ownerID, projectID int, opts models.CrawlOptions) (int, error) {
+	query := `
+		INSERT INTO crawl_urls (url, normalized_url, normalized_url_hash, status, priority, owner_id, project_id,
+			link_scope, same_site_domains, link_check_mode, link_check_limit, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	var owner sql.NullInt64
+	if ownerID > 0 {
+		owner = sql.NullInt64{Int64: int64(ownerID), Valid: true}
 	}
 
+	result, err := db.Exec(query, url, normalizedURL, urlHash(normalizedURL), models.StatusQueued,
+		opts.Priority, owner, projectID, opts.LinkScope, strings.Join(opts.SameSiteDomains, ","),
+		opts.LinkCheckMode, opts.LinkCheckLimit, strings.Join(opts.Tags, ","))
+	if err != nil {
+		if !isDuplicateEntry(err) {
+			logger.Sugar().Errorf("Failed to create crawl URL: %v", err)
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == mysqlErrDuplicateEntry
+}
+
+func (r *CrawlerRepository) GetCrawlURLByNormalizedURL(projectID int, normalizedURL string) (*models.CrawlURL, error) {
+	query := fmt.Sprintf("SELECT %s FROM crawl_urls WHERE project_id = ? AND normalized_url_hash = ?", crawlURLColumns)
+
+	crawlURL, err := scanCrawlURL(r.db.QueryRow(query, projectID, urlHash(normalizedURL)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get crawl URL by normalized URL: %v", err)
+		return nil, err
+	}
+
+	return crawlURL, nil
+}
+
+// GetCrawlURLsWithoutNormalizedURL returns up to limit URLs, oldest first,
+// that were added before URLs were canonicalized.
+func (r *CrawlerRepository) GetCrawlURLsWithoutNormalizedURL(limit int) ([]models.CrawlURL, error) {
+	query := fmt.Sprintf("SELECT %s FROM crawl_urls WHERE normalized_url_hash IS NULL ORDER BY id LIMIT ?", crawlURLColumns)
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get URLs without a normalized URL: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crawlURLs []models.CrawlURL
+	for rows.Next() {
+		crawlURL, err := scanCrawlURL(rows)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan crawl URL: %v", err)
+			return nil, err
+		}
+		crawlURLs = append(crawlURLs, *crawlURL)
+	}
+
+	return crawlURLs, rows.Err()
+}
+
+// SetNormalizedURL stores the canonical form of a URL added before URLs
+// were canonicalized. It returns false without changing the row if another
+// URL in the project already has that form.
+func (r *CrawlerRepository) SetNormalizedURL(id int, normalizedURL string) (bool, error) {
+	_, err := r.db.Exec(`
+		UPDATE crawl_urls SET normalized_url = ?, normalized_url_hash = ? WHERE id = ?
+	`, normalizedURL, urlHash(normalizedURL), id)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlErrDuplicateEntry {
+			return false, nil
+		}
+		logger.Sugar().Errorf("Failed to set normalized URL: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// FlagDuplicateCrawlURL stores the canonical form of a URL that turned out
+// to duplicate another URL in its project, and adds tag to it so that it
+// can be found and removed. The row is hashed under a key no submitted URL
+// can produce, so it does not block the original.
+func (r *CrawlerRepository) FlagDuplicateCrawlURL(id int, normalizedURL, tag string) error {
+	_, err := r.db.Exec(`
+		UPDATE crawl_urls SET
+			normalized_url = ?, normalized_url_hash = ?,
+			tags = IF(tags = '', ?, CONCAT(tags, ',', ?))
+		WHERE id = ?
+	`, normalizedURL, urlHash(fmt.Sprintf("%s#duplicate-%d", normalizedURL, id)), tag, tag, id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to flag duplicate crawl URL: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *CrawlerRepository) GetCrawlURLByID(id int) (*models.CrawlURL, error) {
+	query := fmt.Sprintf("SELECT %s FROM crawl_urls WHERE id = ?", crawlURLColumns)
+
+	crawlURL, err := scanCrawlURL(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get crawl URL by ID: %v", err)
+		return nil, err
+	}
+
+	return crawlURL, nil
+}
+
+// GetCrawlURL returns the URL with the given ID if it is in scope, or nil
+// otherwise.
+func (r *CrawlerRepository) GetCrawlURL(scope models.ProjectScope, id int) (*models.CrawlURL, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM crawl_urls WHERE id = ? AND %s", crawlURLColumns, condition)
+
+	crawlURL, err := scanCrawlURL(r.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get crawl URL by ID: %v", err)
+		return nil, err
+	}
+
+	return crawlURL, nil
+}
+
+// GetCrawlURLs returns a page of the URLs matching query's filter, and the
+// number of matching URLs. Pages follow query.After if it is set, and are
+// numbered by query.Offset otherwise.
+func (r *CrawlerRepository) GetCrawlURLs(query models.CrawlURLQuery) ([]models.CrawlURL, int, error) {
+	whereSQL, args := crawlURLFilter(query.Filter)
+
 	// Count total records
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM crawl_urls %s", whereSQL)
 	var total int
@@ -119,19 +332,26 @@ func (r *CrawlerRepository) GetCrawlURLs(limit, offset int, status, search strin
 		return nil, 0, err
 	}
 
+	// Keyset pages skip the rows up to the cursor through the index instead
+	// of reading and discarding them like OFFSET does
+	offset := query.Offset
+	if len(query.After) > 0 {
+		keyset, keyArgs := crawlURLKeyset(query.Sort, query.After)
+		whereSQL += " AND " + keyset
+		args = append(args, keyArgs...)
+		offset = 0
+	}
+
 	// Get paginated records
-	query := fmt.Sprintf(`
-		SELECT id, url, status, title, html_version, 
-			   h1_count, h2_count, h3_count, h4_count, h5_count, h6_count,
-			   internal_links_count, external_links_count, inaccessible_links_count,
-			   has_login_form, error_message, last_crawled_at, created_at, updated_at
+	pageQuery := fmt.Sprintf(`
+		SELECT %s
 		FROM crawl_urls %s
-		ORDER BY created_at DESC
+		%s
 		LIMIT ? OFFSET ?
-	`, whereSQL)
+	`, crawlURLColumns, whereSQL, crawlURLOrder(query.Sort))
 
-	args = append(args, limit, offset)
-	rows, err := r.db.Query(query, args...)
+	args = append(args, query.Limit, offset)
+	rows, err := r.db.Query(pageQuery, args...)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to get crawl URLs: %v", err)
 		return nil, 0, err
@@ -140,81 +360,766 @@ func (r *CrawlerRepository) GetCrawlURLs(limit, offset int, status, search strin
 
 	var crawlURLs []models.CrawlURL
 	for rows.Next() {
-		var crawlURL models.CrawlURL
-		var title, htmlVersion, errorMessage sql.NullString
-		var lastCrawledAt sql.NullTime
-
-		err := rows.Scan(
-			&crawlURL.ID, &crawlURL.URL, &crawlURL.Status, &title, &htmlVersion,
-			&crawlURL.H1Count, &crawlURL.H2Count, &crawlURL.H3Count, &crawlURL.H4Count,
-			&crawlURL.H5Count, &crawlURL.H6Count, &crawlURL.InternalLinksCount,
-			&crawlURL.ExternalLinksCount, &crawlURL.InaccessibleLinksCount,
-			&crawlURL.HasLoginForm, &errorMessage, &lastCrawledAt,
-			&crawlURL.CreatedAt, &crawlURL.UpdatedAt,
-		)
-
+		crawlURL, err := scanCrawlURL(rows)
 		if err != nil {
 			logger.Sugar().Errorf("Failed to scan crawl URL: %v", err)
 			return nil, 0, err
 		}
 
-		if title.Valid {
-			crawlURL.Title = title.String
-		}
-		if htmlVersion.Valid {
-			crawlURL.HTMLVersion = htmlVersion.String
-		}
-		if errorMessage.Valid {
-			crawlURL.ErrorMessage = errorMessage.String
+		crawlURLs = append(crawlURLs, *crawlURL)
+	}
+	rows.Close()
+
+	if err := r.setQueuePositions(crawlURLs); err != nil {
+		return nil, 0, err
+	}
+
+	return crawlURLs, total, nil
+}
+
+// CrawlURLCursor iterates over crawl URLs as they are read from the
+// database, so that large result sets are never held in memory.
+type CrawlURLCursor struct {
+	rows *sql.Rows
+}
+
+// Next returns the next URL, or nil once the cursor is exhausted.
+func (c *CrawlURLCursor) Next() (*models.CrawlURL, error) {
+	if !c.rows.Next() {
+		if err := c.rows.Err(); err != nil {
+			logger.Sugar().Errorf("Failed to iterate crawl URLs: %v", err)
+			return nil, err
 		}
-		if lastCrawledAt.Valid {
-			crawlURL.LastCrawledAt = &lastCrawledAt.Time
+		return nil, nil
+	}
+
+	crawlURL, err := scanCrawlURL(c.rows)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to scan crawl URL: %v", err)
+		return nil, err
+	}
+	return crawlURL, nil
+}
+
+func (c *CrawlURLCursor) Close() error {
+	return c.rows.Close()
+}
+
+// OpenCrawlURLCursor returns a cursor over the URLs matching the same
+// filters as GetCrawlURLs, in the same order. The cursor holds a database
+// connection until it is closed.
+func (r *CrawlerRepository) OpenCrawlURLCursor(filter models.CrawlURLFilter, sort []models.SortField) (*CrawlURLCursor, error) {
+	whereSQL, args := crawlURLFilter(filter)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM crawl_urls %s
+		%s
+	`, crawlURLColumns, whereSQL, crawlURLOrder(sort))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to query crawl URLs: %v", err)
+		return nil, err
+	}
+
+	return &CrawlURLCursor{rows: rows}, nil
+}
+
+// queueOrderQuery ranks the URLs waiting to be claimed, in the order
+// ClaimQueuedCrawlURLs takes them. Higher priorities come first. Within a
+// priority, owners take turns: every owner's oldest URL is claimed before
+// anyone's second oldest, so one large import cannot hold up other users.
+// It expects the queued status and the current time twice as arguments.
+const queueOrderQuery = `
+	SELECT id, ROW_NUMBER() OVER (ORDER BY priority_rank, owner_rank, created_at, id) AS queue_position
+	FROM (
+		SELECT id, created_at, priority + 0 AS priority_rank,
+			ROW_NUMBER() OVER (PARTITION BY priority, owner_id ORDER BY created_at, id) AS owner_rank
+		FROM crawl_urls
+		WHERE status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+			AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+	) AS waiting`
+
+// setQueuePositions fills in QueuePosition for the queued URLs in crawlURLs.
+func (r *CrawlerRepository) setQueuePositions(crawlURLs []models.CrawlURL) error {
+	now := time.Now()
+	args := []interface{}{models.StatusQueued, now, now}
+	index := make(map[int]int)
+	for i, crawlURL := range crawlURLs {
+		if crawlURL.Status == models.StatusQueued {
+			index[crawlURL.ID] = i
+			args = append(args, crawlURL.ID)
 		}
+	}
+	if len(index) == 0 {
+		return nil
+	}
+
+	placeholders := strings.Repeat("?,", len(index)-1) + "?"
+	query := fmt.Sprintf("SELECT id, queue_position FROM (%s) AS queue WHERE id IN (%s)", queueOrderQuery, placeholders)
 
-		crawlURLs = append(crawlURLs, crawlURL)
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get queue positions: %v", err)
+		return err
 	}
+	defer rows.Close()
 
-	return crawlURLs, total, nil
+	for rows.Next() {
+		var id, position int
+		if err := rows.Scan(&id, &position); err != nil {
+			logger.Sugar().Errorf("Failed to scan queue position: %v", err)
+			return err
+		}
+		crawlURLs[index[id]].QueuePosition = &position
+	}
+
+	return nil
 }
 
-func (r *CrawlerRepository) UpdateCrawlURL(crawlURL *models.CrawlURL) error {
+// UpdateCrawlResult stores the outcome of a crawl, but only if the URL is
+// still running under the claim it was loaded with. Unless links is nil, the
+// page's link results are replaced in the same transaction, so readers never
+// see a mix of two crawls and an interrupted crawl leaves none behind. It
+// reports false if the crawl was cancelled, or its lease was lost and the
+// URL claimed again, while it ran; the result is then dropped.
+func (r *CrawlerRepository) UpdateCrawlResult(crawlURL *models.CrawlURL, links *models.LinkResults) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin crawl result transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
 	query := `
 		UPDATE crawl_urls SET 
-			status = ?, title = ?, html_version = ?,
+			status = ?, priority = ?, title = ?, html_version = ?,
 			h1_count = ?, h2_count = ?, h3_count = ?, h4_count = ?, h5_count = ?, h6_count = ?,
 			internal_links_count = ?, external_links_count = ?, inaccessible_links_count = ?,
-			has_login_form = ?, error_message = ?, last_crawled_at = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
+			checked_links_count = ?, cached_links_count = ?, unchecked_links_count = ?,
+			mailto_links_count = ?, tel_links_count = ?, javascript_links_count = ?,
+			data_links_count = ?, other_scheme_links_count = ?,
+			has_login_form = ?, error_message = ?, last_crawled_at = ?, attempts = ?, next_attempt_at = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND claimed_by = ?
 	`
 
-	_, err := r.db.Exec(query,
-		crawlURL.Status, crawlURL.Title, crawlURL.HTMLVersion,
+	result, err := tx.Exec(query,
+		crawlURL.Status, crawlURL.Priority, crawlURL.Title, crawlURL.HTMLVersion,
 		crawlURL.H1Count, crawlURL.H2Count, crawlURL.H3Count, crawlURL.H4Count,
 		crawlURL.H5Count, crawlURL.H6Count, crawlURL.InternalLinksCount,
 		crawlURL.ExternalLinksCount, crawlURL.InaccessibleLinksCount,
-		crawlURL.HasLoginForm, crawlURL.ErrorMessage, crawlURL.LastCrawledAt,
-		crawlURL.ID,
+		crawlURL.CheckedLinksCount, crawlURL.CachedLinksCount, crawlURL.UncheckedLinksCount,
+		crawlURL.MailtoLinksCount, crawlURL.TelLinksCount, crawlURL.JavascriptLinksCount,
+		crawlURL.DataLinksCount, crawlURL.OtherSchemeLinksCount,
+		crawlURL.HasLoginForm, crawlURL.ErrorMessage, crawlURL.LastCrawledAt, crawlURL.Attempts,
+		crawlURL.NextAttemptAt, crawlURL.ID, models.StatusRunning, crawlURL.ClaimedBy,
 	)
-
 	if err != nil {
 		logger.Sugar().Errorf("Failed to update crawl URL: %v", err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get affected rows: %v", err)
+		return false, err
+	}
+	if affected != 1 {
+		return false, nil
+	}
+
+	if links != nil {
+		if err := replaceLinkResults(tx, crawlURL.ID, links); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit crawl result: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// replaceLinkResults swaps the link results of a page for those of its
+// latest crawl.
+func replaceLinkResults(tx *sql.Tx, crawlURLID int, links *models.LinkResults) error {
+	for _, table := range []string{"link_checks", "link_issues", "broken_links"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE crawl_url_id = ?", crawlURLID); err != nil {
+			logger.Sugar().Errorf("Failed to clear %s: %v", table, err)
+			return err
+		}
+	}
+
+	for _, linkCheck := range links.Checks {
+		_, err := tx.Exec(`
+			INSERT INTO link_checks (crawl_url_id, url, status_code, error_message, from_cache, checked_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, crawlURLID, linkCheck.URL, linkCheck.StatusCode,
+			linkCheck.ErrorMessage, linkCheck.FromCache, linkCheck.CheckedAt)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to create link check: %v", err)
+			return err
+		}
+	}
+
+	for _, linkIssue := range links.Issues {
+		_, err := tx.Exec(`
+			INSERT INTO link_issues (crawl_url_id, url, issue_type, message)
+			VALUES (?, ?, ?, ?)
+		`, crawlURLID, linkIssue.URL, linkIssue.IssueType, linkIssue.Message)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to create link issue: %v", err)
+			return err
+		}
+	}
+
+	for _, brokenLink := range links.BrokenLinks {
+		_, err := tx.Exec(`
+			INSERT INTO broken_links (crawl_url_id, url, status_code, error_message)
+			VALUES (?, ?, ?, ?)
+		`, crawlURLID, brokenLink.URL, brokenLink.StatusCode, brokenLink.ErrorMessage)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to create broken link: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RequeueCrawlURL puts a URL that is not running back in the queue at the
+// given priority with a fresh retry budget. It reports false if the URL
+// does not exist or started running since it was read.
+func (r *CrawlerRepository) RequeueCrawlURL(id int, priority string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin requeue transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM crawl_urls WHERE id = ? FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		logger.Sugar().Errorf("Failed to lock crawl URL: %v", err)
+		return false, err
+	}
+	if status == models.StatusRunning {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE crawl_urls SET
+			status = ?, priority = ?, error_message = '', attempts = 0, next_attempt_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, models.StatusQueued, priority, id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to requeue crawl URL: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit requeue: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ClaimQueuedCrawlURLs reserves up to limit queued URLs for workerID until
+// the lease expires, taking them in queue order (see queueOrderQuery). Rows
+// are locked with SKIP LOCKED, so concurrent callers in other processes
+// never receive the same row. Claimed rows stay queued until
+// StartClaimedCrawl moves them to running.
+func (r *CrawlerRepository) ClaimQueuedCrawlURLs(workerID string, limit int, lease time.Duration) ([]int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin claim transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Rank without locking first; window functions cannot be combined with
+	// FOR UPDATE. Extra candidates make up for rows another process locks
+	// in the meantime.
+	now := time.Now()
+	candidates, err := queryIDs(tx, queueOrderQuery+" ORDER BY queue_position LIMIT ?",
+		models.StatusQueued, now, now, limit*2)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select queued URLs: %v", err)
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{models.StatusQueued, now}
+	for _, id := range candidates {
+		args = append(args, id)
+	}
+	lockQuery := fmt.Sprintf(`
+		SELECT id FROM crawl_urls
+		WHERE status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+			AND id IN (%s)
+		FOR UPDATE SKIP LOCKED
+	`, strings.Repeat("?,", len(candidates)-1)+"?")
+	locked, err := queryIDs(tx, lockQuery, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to lock queued URLs: %v", err)
+		return nil, err
+	}
+
+	isLocked := make(map[int]bool, len(locked))
+	for _, id := range locked {
+		isLocked[id] = true
+	}
+	var ids []int
+	for _, id := range candidates {
+		if isLocked[id] && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(ids)-1) + "?"
+	args = []interface{}{workerID, now.Add(lease)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf("UPDATE crawl_urls SET claimed_by = ?, lease_expires_at = ? WHERE id IN (%s)", placeholders)
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Sugar().Errorf("Failed to claim queued URLs: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit claim: %v", err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// queryIDs runs a query returning a single integer column.
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// StartClaimedCrawl atomically moves a queued URL to running for workerID.
+// It succeeds only if the row is unclaimed, already claimed by workerID, or
+// its previous lease has expired, and reports whether the claim was won.
+func (r *CrawlerRepository) StartClaimedCrawl(id int, workerID string, lease time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE crawl_urls SET
+			status = ?, claimed_by = ?, lease_expires_at = ?, heartbeat_at = ?,
+			last_crawled_at = ?, attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+			AND (claimed_by IS NULL OR claimed_by = ? OR lease_expires_at IS NULL OR lease_expires_at < ?)
+			AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+	`, models.StatusRunning, workerID, now.Add(lease), now, now,
+		id, models.StatusQueued, workerID, now, now)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to start claimed crawl: %v", err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RenewLease records a heartbeat for a running crawl and extends its lease.
+// It reports false if the crawl is no longer running under workerID, for
+// example because it was cancelled or recovered by another process.
+func (r *CrawlerRepository) RenewLease(id int, workerID string, lease time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE crawl_urls SET lease_expires_at = ?, heartbeat_at = ?
+		WHERE id = ? AND claimed_by = ? AND status = ?
+	`, now.Add(lease), now, id, workerID, models.StatusRunning)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to renew lease: %v", err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// CancelCrawlURLs moves the given URLs in scope to cancelled if they are
+// queued or running and returns the IDs that were cancelled. A running
+// crawl notices the change at its next heartbeat, or at once if the caller
+// also cancels it in the worker pool running it.
+func (r *CrawlerRepository) CancelCrawlURLs(scope models.ProjectScope, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin cancel transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.Repeat("?,", len(ids)-1) + "?"
+	condition, args := projectCondition("project_id", scope)
+	args = append(args, models.StatusQueued, models.StatusRunning)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf("SELECT id FROM crawl_urls WHERE %s AND status IN (?, ?) AND id IN (%s) FOR UPDATE", condition, placeholders)
+	cancelled, err := queryIDs(tx, query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select URLs to cancel: %v", err)
+		return nil, err
+	}
+	if len(cancelled) == 0 {
+		return nil, nil
+	}
+
+	args = []interface{}{models.StatusCancelled, "Cancelled by user"}
+	for _, id := range cancelled {
+		args = append(args, id)
+	}
+	query = fmt.Sprintf(`
+		UPDATE crawl_urls SET status = ?, error_message = ?, next_attempt_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (%s)
+	`, strings.Repeat("?,", len(cancelled)-1)+"?")
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Sugar().Errorf("Failed to cancel crawl URLs: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit cancel: %v", err)
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+// ReleaseClaim clears workerID's claim on a URL once its crawl has finished.
+func (r *CrawlerRepository) ReleaseClaim(id int, workerID string) error {
+	_, err := r.db.Exec(`
+		UPDATE crawl_urls SET claimed_by = NULL, lease_expires_at = NULL
+		WHERE id = ? AND claimed_by = ?
+	`, id, workerID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to release claim: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateCrawlProgress persists the progress of a running crawl.
+func (r *CrawlerRepository) UpdateCrawlProgress(progress *models.CrawlProgress) error {
+	_, err := r.db.Exec(`
+		UPDATE crawl_urls SET progress_phase = ?, progress_done = ?, progress_total = ?, progress_updated_at = ?
+		WHERE id = ?
+	`, progress.Phase, progress.LinksChecked, progress.LinksTotal, progress.UpdatedAt, progress.CrawlURLID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to update crawl progress: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRunningCrawlProgress returns the persisted progress of every running
+// crawl in scope.
+func (r *CrawlerRepository) GetRunningCrawlProgress(scope models.ProjectScope) ([]models.CrawlProgress, error) {
+	condition, args := projectCondition("project_id", scope)
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT id, url, progress_phase, progress_done, progress_total, progress_updated_at
+		FROM crawl_urls
+		WHERE %s AND status = ? AND progress_phase IS NOT NULL
+		ORDER BY id
+	`, condition), append(args, models.StatusRunning)...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get crawl progress: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []models.CrawlProgress{}
+	for rows.Next() {
+		var p models.CrawlProgress
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&p.CrawlURLID, &p.URL, &p.Phase, &p.LinksChecked, &p.LinksTotal, &updatedAt); err != nil {
+			logger.Sugar().Errorf("Failed to scan crawl progress: %v", err)
+			return nil, err
+		}
+		if updatedAt.Valid {
+			p.UpdatedAt = updatedAt.Time
+		}
+		progress = append(progress, p)
+	}
+
+	return progress, nil
+}
+
+// RequeueCrawl puts a crawl that workerID interrupted while shutting down
+// back in the queue. The interrupted attempt does not count towards the
+// URL's retry limit.
+func (r *CrawlerRepository) RequeueCrawl(id int, workerID string) error {
+	_, err := r.db.Exec(`
+		UPDATE crawl_urls SET
+			status = ?, attempts = GREATEST(attempts - 1, 0), next_attempt_at = NULL,
+			claimed_by = NULL, lease_expires_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND claimed_by = ? AND status = ?
+	`, models.StatusQueued, id, workerID, models.StatusRunning)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to requeue crawl URL: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RecoverStaleCrawls finds running crawls whose worker has stopped sending
+// heartbeats: the lease has expired, or, for rows without a lease, the last
+// heartbeat or crawl start is older than staleAfter. Rows claimed by
+// deadWorkerID are treated as stale regardless of their lease; pass the
+// process's own worker ID at startup to reclaim crawls a previous run of it
+// left behind. Stale crawls with fewer than maxAttempts attempts are
+// requeued, the rest are marked as failed. The attempts they interrupted are
+// closed in the attempt history. It returns the IDs of the requeued and the
+// failed crawls.
+func (r *CrawlerRepository) RecoverStaleCrawls(staleAfter time.Duration, maxAttempts int, deadWorkerID string) (requeued, failed []int, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin stale crawl recovery transaction: %v", err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	staleQuery := `
+		SELECT id FROM crawl_urls
+		WHERE status = ? AND (
+			lease_expires_at < ?
+			OR (lease_expires_at IS NULL AND COALESCE(heartbeat_at, last_crawled_at, updated_at) < ?)
+			OR claimed_by = ?
+		) AND attempts %s ?
+		FOR UPDATE
+	`
+	staleArgs := []interface{}{models.StatusRunning, now, now.Add(-staleAfter), deadWorkerID, maxAttempts}
+
+	failed, err = queryIDs(tx, fmt.Sprintf(staleQuery, ">="), staleArgs...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select stale crawls to fail: %v", err)
+		return nil, nil, err
+	}
+	requeued, err = queryIDs(tx, fmt.Sprintf(staleQuery, "<"), staleArgs...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select stale crawls to requeue: %v", err)
+		return nil, nil, err
+	}
+	if len(failed) == 0 && len(requeued) == 0 {
+		return nil, nil, nil
+	}
+
+	if len(failed) > 0 {
+		args := []interface{}{models.StatusFailed, fmt.Sprintf("Crawl abandoned after %d interrupted attempts", maxAttempts)}
+		for _, id := range failed {
+			args = append(args, id)
+		}
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE crawl_urls SET
+				status = ?, error_message = ?, claimed_by = NULL, lease_expires_at = NULL
+			WHERE id IN (%s)
+		`, strings.Repeat("?,", len(failed)-1)+"?"), args...)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to fail stale crawls: %v", err)
+			return nil, nil, err
+		}
+	}
+
+	if len(requeued) > 0 {
+		args := []interface{}{models.StatusQueued}
+		for _, id := range requeued {
+			args = append(args, id)
+		}
+		_, err = tx.Exec(fmt.Sprintf(`
+			UPDATE crawl_urls SET
+				status = ?, claimed_by = NULL, lease_expires_at = NULL
+			WHERE id IN (%s)
+		`, strings.Repeat("?,", len(requeued)-1)+"?"), args...)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to requeue stale crawls: %v", err)
+			return nil, nil, err
+		}
+	}
+
+	recovered := append(append([]int{}, failed...), requeued...)
+	args := []interface{}{models.AttemptInterrupted, now, models.AttemptRunning}
+	for _, id := range recovered {
+		args = append(args, id)
+	}
+	_, err = tx.Exec(fmt.Sprintf(`
+		UPDATE crawl_attempts SET outcome = ?, finished_at = ?
+		WHERE outcome = ? AND crawl_url_id IN (%s)
+	`, strings.Repeat("?,", len(recovered)-1)+"?"), args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to close interrupted crawl attempts: %v", err)
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit stale crawl recovery: %v", err)
+		return nil, nil, err
+	}
+
+	return requeued, failed, nil
+}
+
+// CreateCrawlAttempt records the start of an attempt and sets its ID.
+func (r *CrawlerRepository) CreateCrawlAttempt(attempt *models.CrawlAttempt) error {
+	query := `
+		INSERT INTO crawl_attempts (crawl_url_id, attempt, outcome, started_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query, attempt.CrawlURLID, attempt.Attempt, attempt.Outcome, attempt.StartedAt)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create crawl attempt: %v", err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return err
+	}
+	attempt.ID = int(id)
+
+	return nil
+}
+
+// FinishCrawlAttempt records the outcome of an attempt.
+func (r *CrawlerRepository) FinishCrawlAttempt(attempt *models.CrawlAttempt) error {
+	query := `
+		UPDATE crawl_attempts SET
+			outcome = ?, error_class = ?, status_code = ?, error_message = ?,
+			finished_at = ?, next_attempt_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query,
+		attempt.Outcome, attempt.ErrorClass, attempt.StatusCode, attempt.ErrorMessage,
+		attempt.FinishedAt, attempt.NextAttemptAt, attempt.ID,
+	)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to finish crawl attempt: %v", err)
 		return err
 	}
 
 	return nil
 }
 
-func (r *CrawlerRepository) DeleteCrawlURLs(ids []int) error {
+func (r *CrawlerRepository) GetCrawlAttempts(crawlURLID int) ([]models.CrawlAttempt, error) {
+	query := `
+		SELECT id, crawl_url_id, attempt, outcome, error_class, status_code, error_message,
+			started_at, finished_at, next_attempt_at
+		FROM crawl_attempts
+		WHERE crawl_url_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, crawlURLID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get crawl attempts: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.CrawlAttempt
+	for rows.Next() {
+		var attempt models.CrawlAttempt
+		var errorClass, errorMessage sql.NullString
+		var statusCode sql.NullInt64
+		var finishedAt, nextAttemptAt sql.NullTime
+
+		err := rows.Scan(
+			&attempt.ID, &attempt.CrawlURLID, &attempt.Attempt, &attempt.Outcome,
+			&errorClass, &statusCode, &errorMessage,
+			&attempt.StartedAt, &finishedAt, &nextAttemptAt,
+		)
+
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan crawl attempt: %v", err)
+			return nil, err
+		}
+
+		if errorClass.Valid {
+			attempt.ErrorClass = errorClass.String
+		}
+		if statusCode.Valid {
+			attempt.StatusCode = int(statusCode.Int64)
+		}
+		if errorMessage.Valid {
+			attempt.ErrorMessage = errorMessage.String
+		}
+		if finishedAt.Valid {
+			attempt.FinishedAt = &finishedAt.Time
+		}
+		if nextAttemptAt.Valid {
+			attempt.NextAttemptAt = &nextAttemptAt.Time
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, nil
+}
+
+// DeleteCrawlURLs deletes the given URLs that are in scope.
+func (r *CrawlerRepository) DeleteCrawlURLs(scope models.ProjectScope, ids []int) error {
 	if len(ids) == 0 {
 		return nil
 	}
 
 	placeholders := strings.Repeat("?,", len(ids)-1) + "?"
-	query := fmt.Sprintf("DELETE FROM crawl_urls WHERE id IN (%s)", placeholders)
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("DELETE FROM crawl_urls WHERE %s AND id IN (%s)", condition, placeholders)
 
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		args[i] = id
+	for _, id := range ids {
+		args = append(args, id)
 	}
 
 	_, err := r.db.Exec(query, args...)
@@ -270,40 +1175,115 @@ func (r *CrawlerRepository) GetBrokenLinks(crawlURLID int) ([]models.BrokenLink,
 	return brokenLinks, nil
 }
 
-func (r *CrawlerRepository) CreateBrokenLink(brokenLink *models.BrokenLink) error {
+func (r *CrawlerRepository) GetLinkIssues(crawlURLID int) ([]models.LinkIssue, error) {
 	query := `
-		INSERT INTO broken_links (crawl_url_id, url, status_code, error_message)
-		VALUES (?, ?, ?, ?)
+		SELECT id, crawl_url_id, url, issue_type, message, created_at
+		FROM link_issues
+		WHERE crawl_url_id = ?
+		ORDER BY created_at DESC
 	`
 
-	_, err := r.db.Exec(query,
-		brokenLink.CrawlURLID, brokenLink.URL,
-		brokenLink.StatusCode, brokenLink.ErrorMessage,
-	)
-
+	rows, err := r.db.Query(query, crawlURLID)
 	if err != nil {
-		logger.Sugar().Errorf("Failed to create broken link: %v", err)
-		return err
+		logger.Sugar().Errorf("Failed to get link issues: %v", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	var linkIssues []models.LinkIssue
+	for rows.Next() {
+		var linkIssue models.LinkIssue
+		var message sql.NullString
+
+		err := rows.Scan(
+			&linkIssue.ID, &linkIssue.CrawlURLID, &linkIssue.URL,
+			&linkIssue.IssueType, &message, &linkIssue.CreatedAt,
+		)
+
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan link issue: %v", err)
+			return nil, err
+		}
+
+		if message.Valid {
+			linkIssue.Message = message.String
+		}
+
+		linkIssues = append(linkIssues, linkIssue)
+	}
+
+	return linkIssues, nil
 }
 
-func (r *CrawlerRepository) GetCrawlStats() (*models.CrawlStats, error) {
+func (r *CrawlerRepository) GetLinkChecks(crawlURLID int) ([]models.LinkCheck, error) {
 	query := `
+		SELECT id, crawl_url_id, url, status_code, error_message, from_cache, checked_at, created_at
+		FROM link_checks
+		WHERE crawl_url_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, crawlURLID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get link checks: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var linkChecks []models.LinkCheck
+	for rows.Next() {
+		var linkCheck models.LinkCheck
+		var statusCode sql.NullInt64
+		var errorMessage sql.NullString
+		var checkedAt sql.NullTime
+
+		err := rows.Scan(
+			&linkCheck.ID, &linkCheck.CrawlURLID, &linkCheck.URL,
+			&statusCode, &errorMessage, &linkCheck.FromCache,
+			&checkedAt, &linkCheck.CreatedAt,
+		)
+
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan link check: %v", err)
+			return nil, err
+		}
+
+		if statusCode.Valid {
+			linkCheck.StatusCode = int(statusCode.Int64)
+		}
+		if errorMessage.Valid {
+			linkCheck.ErrorMessage = errorMessage.String
+		}
+		if checkedAt.Valid {
+			linkCheck.CheckedAt = checkedAt.Time
+		}
+
+		linkChecks = append(linkChecks, linkCheck)
+	}
+
+	return linkChecks, nil
+}
+
+// GetCrawlStats counts the URLs in scope by status.
+func (r *CrawlerRepository) GetCrawlStats(scope models.ProjectScope) (*models.CrawlStats, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf(`
 		SELECT 
 			COUNT(*) as total,
-			SUM(CASE WHEN status = 'queued' THEN 1 ELSE 0 END) as queued,
-			SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END) as running,
-			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) as completed,
-			SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error
+			COALESCE(SUM(CASE WHEN status = 'queued' THEN 1 ELSE 0 END), 0) as queued,
+			COALESCE(SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END), 0) as running,
+			COALESCE(SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END), 0) as completed,
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0) as error,
+			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed,
+			COALESCE(SUM(CASE WHEN status = 'cancelled' THEN 1 ELSE 0 END), 0) as cancelled
 		FROM crawl_urls
-	`
+		WHERE %s
+	`, condition)
 
 	var stats models.CrawlStats
-	err := r.db.QueryRow(query).Scan(
+	err := r.db.QueryRow(query, args...).Scan(
 		&stats.TotalURLs, &stats.QueuedURLs, &stats.RunningURLs,
-		&stats.CompletedURLs, &stats.ErrorURLs,
+		&stats.CompletedURLs, &stats.ErrorURLs, &stats.FailedURLs, &stats.CancelledURLs,
 	)
 
 	if err != nil {
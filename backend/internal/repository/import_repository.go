@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+type ImportRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository() *ImportRepository {
+	return &ImportRepository{
+		db: database.DB,
+	}
+}
+
+const importJobColumns = `id, owner_id, project_id, filename, format, status, priority, profile,
+	total_rows, accepted_rows, duplicate_rows, rejected_rows, error_message,
+	started_at, finished_at, created_at, updated_at`
+
+func scanImportJob(row rowScanner) (*models.ImportJob, error) {
+	var job models.ImportJob
+	var ownerID, projectID sql.NullInt64
+	var errorMessage sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(
+		&job.ID, &ownerID, &projectID, &job.Filename, &job.Format, &job.Status, &job.Priority, &job.Profile,
+		&job.TotalRows, &job.AcceptedRows, &job.DuplicateRows, &job.RejectedRows, &errorMessage,
+		&startedAt, &finishedAt, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID.Valid {
+		id := int(ownerID.Int64)
+		job.OwnerID = &id
+	}
+	job.ProjectID = int(projectID.Int64)
+	if errorMessage.Valid {
+		job.ErrorMessage = errorMessage.String
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.ProcessedRows = job.AcceptedRows + job.DuplicateRows + job.RejectedRows
+
+	return &job, nil
+}
+
+// Create stores a pending import job together with the uploaded file.
+func (r *ImportRepository) Create(job *models.ImportJob, content string) (*models.ImportJob, error) {
+	var owner sql.NullInt64
+	if job.OwnerID != nil {
+		owner = sql.NullInt64{Int64: int64(*job.OwnerID), Valid: true}
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO import_jobs (owner_id, project_id, filename, format, status, priority, profile, content, total_rows)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, owner, job.ProjectID, job.Filename, job.Format, models.ImportPending, job.Priority, job.Profile, content, job.TotalRows)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create import job: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return nil, err
+	}
+
+	return r.GetByID(int(id))
+}
+
+func (r *ImportRepository) GetByID(id int) (*models.ImportJob, error) {
+	query := fmt.Sprintf("SELECT %s FROM import_jobs WHERE id = ?", importJobColumns)
+
+	job, err := scanImportJob(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get import job by ID: %v", err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Get returns the job with the given ID if it is in scope, or nil
+// otherwise.
+func (r *ImportRepository) Get(scope models.ProjectScope, id int) (*models.ImportJob, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM import_jobs WHERE id = ? AND %s", importJobColumns, condition)
+
+	job, err := scanImportJob(r.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get import job by ID: %v", err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetClaimableIDs returns the jobs that are waiting to be processed or
+// whose processing instance has stopped renewing its lease.
+func (r *ImportRepository) GetClaimableIDs(limit int) ([]int, error) {
+	rows, err := r.db.Query(`
+		SELECT id FROM import_jobs
+		WHERE status = ? OR (status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?))
+		ORDER BY id
+		LIMIT ?
+	`, models.ImportPending, models.ImportProcessing, time.Now(), limit)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get claimable import jobs: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			logger.Sugar().Errorf("Failed to scan import job ID: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Claim marks a claimable job as processing by workerID. It returns false
+// if the job is not claimable, for instance because another instance
+// claimed it first.
+func (r *ImportRepository) Claim(id int, workerID string, lease time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE import_jobs
+		SET status = ?, claimed_by = ?, lease_expires_at = ?, started_at = COALESCE(started_at, ?)
+		WHERE id = ? AND (status = ? OR (status = ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)))
+	`, models.ImportProcessing, workerID, now.Add(lease), now,
+		id, models.ImportPending, models.ImportProcessing, now)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to claim import job: %v", err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// GetContent returns the uploaded file of a job.
+func (r *ImportRepository) GetContent(id int) (string, error) {
+	var content sql.NullString
+	err := r.db.QueryRow("SELECT content FROM import_jobs WHERE id = ?", id).Scan(&content)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get import job content: %v", err)
+		return "", err
+	}
+	return content.String, nil
+}
+
+// GetLastLine returns the highest line number reported for a job, from
+// which an interrupted job resumes.
+func (r *ImportRepository) GetLastLine(id int) (int, error) {
+	var line sql.NullInt64
+	err := r.db.QueryRow("SELECT MAX(line_number) FROM import_job_rows WHERE import_job_id = ?", id).Scan(&line)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last imported line: %v", err)
+		return 0, err
+	}
+	return int(line.Int64), nil
+}
+
+// AddRows records the results of a batch of rows, updates the job's
+// counts and renews workerID's lease on it. It returns false if the job is
+// no longer claimed by workerID.
+func (r *ImportRepository) AddRows(jobID int, rows []models.ImportRow, workerID string, lease time.Duration) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin import rows transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	claimed, err := lockClaimedJob(tx, jobID, workerID)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if err := insertImportRows(tx, jobID, rows); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE import_jobs SET
+			accepted_rows = (SELECT COUNT(*) FROM import_job_rows WHERE import_job_id = ? AND result = ?),
+			duplicate_rows = (SELECT COUNT(*) FROM import_job_rows WHERE import_job_id = ? AND result = ?),
+			rejected_rows = (SELECT COUNT(*) FROM import_job_rows WHERE import_job_id = ? AND result = ?),
+			lease_expires_at = ?
+		WHERE id = ?
+	`, jobID, models.ImportRowAccepted, jobID, models.ImportRowDuplicate, jobID, models.ImportRowRejected,
+		time.Now().Add(lease), jobID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to update import job counts: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit import rows: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddURL queues the URL of an imported row unless the job's project
+// already has it. A queued URL is recorded as the accepted row in the same
+// transaction, together with the pending rows before it, so that a resumed
+// job never finds a URL it added itself without a row for it and never
+// skips an unrecorded row. A duplicate is left to the caller to record with
+// AddRows. It returns the ID of the new or existing URL, and claimed is
+// false if the job is no longer claimed by workerID.
+func (r *ImportRepository) AddURL(jobID int, workerID string, pending []models.ImportRow, row models.ImportRow,
+	url, normalizedURL string, ownerID, projectID int, opts models.CrawlOptions) (crawlURLID int, duplicate, claimed bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin import URL transaction: %v", err)
+		return 0, false, false, err
+	}
+	defer tx.Rollback()
+
+	claimed, err = lockClaimedJob(tx, jobID, workerID)
+	if err != nil || !claimed {
+		return 0, false, false, err
+	}
+
+	existingID := func() (int, error) {
+		var id int
+		err := tx.QueryRow("SELECT id FROM crawl_urls WHERE project_id = ? AND normalized_url_hash = ?",
+			projectID, urlHash(normalizedURL)).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			logger.Sugar().Errorf("Failed to get crawl URL by normalized URL: %v", err)
+			return 0, err
+		}
+		return id, nil
+	}
+
+	if crawlURLID, err = existingID(); err != nil || crawlURLID != 0 {
+		return crawlURLID, crawlURLID != 0, true, err
+	}
+
+	crawlURLID, err = insertCrawlURL(tx, url, normalizedURL, ownerID, projectID, opts)
+	if err != nil {
+		if !isDuplicateEntry(err) {
+			return 0, false, true, err
+		}
+		// Another request inserted the same URL since the lookup above
+		insertErr := err
+		if crawlURLID, err = existingID(); err != nil || crawlURLID != 0 {
+			return crawlURLID, crawlURLID != 0, true, err
+		}
+		logger.Sugar().Errorf("Failed to create crawl URL: %v", insertErr)
+		return 0, false, true, insertErr
+	}
+
+	row.Result = models.ImportRowAccepted
+	row.CrawlURLID = &crawlURLID
+	rows := append(append([]models.ImportRow{}, pending...), row)
+	if err := insertImportRows(tx, jobID, rows); err != nil {
+		return 0, false, true, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit import URL: %v", err)
+		return 0, false, true, err
+	}
+
+	return crawlURLID, false, true, nil
+}
+
+// lockClaimedJob locks a job so that its claim cannot change until the
+// transaction ends, and reports whether workerID holds the claim.
+func lockClaimedJob(tx *sql.Tx, jobID int, workerID string) (bool, error) {
+	var claimed bool
+	err := tx.QueryRow("SELECT COUNT(*) > 0 FROM import_jobs WHERE id = ? AND status = ? AND claimed_by = ? FOR UPDATE",
+		jobID, models.ImportProcessing, workerID).Scan(&claimed)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to lock import job: %v", err)
+		return false, err
+	}
+	return claimed, nil
+}
+
+func insertImportRows(tx *sql.Tx, jobID int, rows []models.ImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var values []string
+	var args []interface{}
+	for _, row := range rows {
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+
+		var reason sql.NullString
+		if row.Reason != "" {
+			reason = sql.NullString{String: row.Reason, Valid: true}
+		}
+		var crawlURLID sql.NullInt64
+		if row.CrawlURLID != nil {
+			crawlURLID = sql.NullInt64{Int64: int64(*row.CrawlURLID), Valid: true}
+		}
+		args = append(args, jobID, row.LineNumber, row.URL, row.Result, reason, crawlURLID)
+	}
+
+	// A resumed job may repeat rows an earlier claim already recorded
+	query := fmt.Sprintf(`
+		INSERT IGNORE INTO import_job_rows (import_job_id, line_number, url, result, reason, crawl_url_id)
+		VALUES %s
+	`, strings.Join(values, ", "))
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Sugar().Errorf("Failed to insert import rows: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Finish moves a job claimed by workerID to a final status and drops the
+// uploaded file, which is no longer needed.
+func (r *ImportRepository) Finish(id int, workerID, status, errorMessage string) error {
+	var message sql.NullString
+	if errorMessage != "" {
+		message = sql.NullString{String: errorMessage, Valid: true}
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE import_jobs
+		SET status = ?, error_message = ?, content = NULL, claimed_by = NULL, lease_expires_at = NULL, finished_at = ?
+		WHERE id = ? AND claimed_by = ?
+	`, status, message, time.Now(), id, workerID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to finish import job: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Release hands a job claimed by workerID back to the queue, so that
+// another instance can resume it without waiting for the lease to expire.
+func (r *ImportRepository) Release(id int, workerID string) error {
+	_, err := r.db.Exec(`
+		UPDATE import_jobs SET status = ?, claimed_by = NULL, lease_expires_at = NULL
+		WHERE id = ? AND status = ? AND claimed_by = ?
+	`, models.ImportPending, id, models.ImportProcessing, workerID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to release import job: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetRows returns a page of a job's row report in file order, optionally
+// limited to one result.
+func (r *ImportRepository) GetRows(jobID int, result string, limit, offset int) ([]models.ImportRow, int, error) {
+	whereSQL := "WHERE import_job_id = ?"
+	args := []interface{}{jobID}
+	if result != "" {
+		whereSQL += " AND result = ?"
+		args = append(args, result)
+	}
+
+	var total int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM import_job_rows "+whereSQL, args...).Scan(&total)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to count import rows: %v", err)
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, import_job_id, line_number, url, result, reason, crawl_url_id, created_at
+		FROM import_job_rows %s
+		ORDER BY line_number
+		LIMIT ? OFFSET ?
+	`, whereSQL)
+
+	args = append(args, limit, offset)
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get import rows: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	importRows := []models.ImportRow{}
+	for rows.Next() {
+		var row models.ImportRow
+		var reason sql.NullString
+		var crawlURLID sql.NullInt64
+
+		err := rows.Scan(&row.ID, &row.ImportJobID, &row.LineNumber, &row.URL, &row.Result,
+			&reason, &crawlURLID, &row.CreatedAt)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan import row: %v", err)
+			return nil, 0, err
+		}
+
+		if reason.Valid {
+			row.Reason = reason.String
+		}
+		if crawlURLID.Valid {
+			id := int(crawlURLID.Int64)
+			row.CrawlURLID = &id
+		}
+
+		importRows = append(importRows, row)
+	}
+
+	return importRows, total, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+// LinkStatus is a cached link-check outcome shared across crawls.
+type LinkStatus struct {
+	URL          string
+	StatusCode   int
+	ErrorMessage string
+	CheckedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type LinkStatusRepository struct {
+	db *sql.DB
+}
+
+func NewLinkStatusRepository() *LinkStatusRepository {
+	return &LinkStatusRepository{
+		db: database.DB,
+	}
+}
+
+// urlHash keys the cache table; URLs are too long for a MySQL index.
+func urlHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the unexpired cached status for url, or nil if there is none.
+func (r *LinkStatusRepository) Get(url string) (*LinkStatus, error) {
+	query := `
+		SELECT url, status_code, error_message, checked_at, expires_at
+		FROM link_status_cache
+		WHERE url_hash = ? AND expires_at > ?
+	`
+
+	var status LinkStatus
+	var statusCode sql.NullInt64
+	var errorMessage sql.NullString
+
+	err := r.db.QueryRow(query, urlHash(url), time.Now()).Scan(
+		&status.URL, &statusCode, &errorMessage, &status.CheckedAt, &status.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get cached link status: %v", err)
+		return nil, err
+	}
+
+	if statusCode.Valid {
+		status.StatusCode = int(statusCode.Int64)
+	}
+	if errorMessage.Valid {
+		status.ErrorMessage = errorMessage.String
+	}
+
+	return &status, nil
+}
+
+func (r *LinkStatusRepository) Set(status *LinkStatus) error {
+	query := `
+		INSERT INTO link_status_cache (url_hash, url, status_code, error_message, checked_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			status_code = VALUES(status_code),
+			error_message = VALUES(error_message),
+			checked_at = VALUES(checked_at),
+			expires_at = VALUES(expires_at)
+	`
+
+	_, err := r.db.Exec(query,
+		urlHash(status.URL), status.URL, status.StatusCode,
+		status.ErrorMessage, status.CheckedAt, status.ExpiresAt,
+	)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to store cached link status: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *LinkStatusRepository) DeleteExpired() error {
+	_, err := r.db.Exec("DELETE FROM link_status_cache WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		logger.Sugar().Errorf("Failed to delete expired link statuses: %v", err)
+		return err
+	}
+
+	return nil
+}
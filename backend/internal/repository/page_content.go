@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/logger"
+)
+
+// SavePageContent stores the searchable text of a crawled page, replacing
+// the text of its previous crawl.
+func (r *CrawlerRepository) SavePageContent(content *models.PageContent) error {
+	_, err := r.db.Exec(`
+		INSERT INTO crawl_url_contents (crawl_url_id, page_title, meta_description, body_text)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			page_title = VALUES(page_title),
+			meta_description = VALUES(meta_description),
+			body_text = VALUES(body_text)
+	`, content.CrawlURLID, content.Title, content.MetaDescription, content.BodyText)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to save page content: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// pageMatch scores a page against the search terms. Title matches count
+// three times: once in the combined index and twice more in the title one.
+const pageMatch = `MATCH(c.page_title, c.meta_description, c.body_text) AGAINST (? IN NATURAL LANGUAGE MODE)`
+
+const pageScore = pageMatch + ` + 2 * MATCH(c.page_title) AGAINST (? IN NATURAL LANGUAGE MODE)`
+
+// extraScanner scans a row whose leading columns are read by another scan
+// function, followed by dest.
+type extraScanner struct {
+	row  rowScanner
+	dest []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.dest...)...)
+}
+
+// SearchPages returns a page of the crawled pages whose text matches terms,
+// most relevant first, limited to the URLs matching filter. It also returns
+// the number of matching pages.
+func (r *CrawlerRepository) SearchPages(terms string, filter models.CrawlURLFilter, limit, offset int) ([]models.PageSearchResult, int, error) {
+	whereSQL, filterArgs := crawlURLFilter(filter)
+	whereSQL += " AND " + pageMatch
+	args := append(filterArgs, terms)
+
+	var total int
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM crawl_urls JOIN crawl_url_contents c ON c.crawl_url_id = crawl_urls.id
+		%s
+	`, whereSQL)
+	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		logger.Sugar().Errorf("Failed to count page search results: %v", err)
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, c.meta_description, c.body_text, %s AS score
+		FROM crawl_urls JOIN crawl_url_contents c ON c.crawl_url_id = crawl_urls.id
+		%s
+		ORDER BY score DESC, crawl_urls.id DESC
+		LIMIT ? OFFSET ?
+	`, crawlURLColumns, pageScore, whereSQL)
+
+	queryArgs := append([]interface{}{terms, terms}, args...)
+	queryArgs = append(queryArgs, limit, offset)
+	rows, err := r.db.Query(query, queryArgs...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to search pages: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.PageSearchResult{}
+	for rows.Next() {
+		var result models.PageSearchResult
+		var metaDescription, bodyText sql.NullString
+
+		crawlURL, err := scanCrawlURL(extraScanner{rows, []interface{}{&metaDescription, &bodyText, &result.Score}})
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan page search result: %v", err)
+			return nil, 0, err
+		}
+
+		result.CrawlURL = *crawlURL
+		result.MetaDescription = metaDescription.String
+		result.BodyText = bodyText.String
+		results = append(results, result)
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+// PasswordResetToken is a stored password reset token. Only the SHA-256
+// hash of the token itself is kept.
+type PasswordResetToken struct {
+	ID        int
+	UserID    int
+	ExpiresAt time.Time
+}
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository() *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db: database.DB,
+	}
+}
+
+func (r *PasswordResetRepository) Create(userID int, hash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)
+	`, userID, hash, expiresAt)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create password reset token: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Use marks the unused, unexpired reset token with the given hash as used
+// and returns it, or returns nil if there is no such token.
+func (r *PasswordResetRepository) Use(hash string) (*PasswordResetToken, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin password reset transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var token PasswordResetToken
+	err = tx.QueryRow(`
+		SELECT id, user_id, expires_at FROM password_reset_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+		FOR UPDATE
+	`, hash, now).Scan(&token.ID, &token.UserID, &token.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get password reset token: %v", err)
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE password_reset_tokens SET used_at = ? WHERE id = ?", now, token.ID); err != nil {
+		logger.Sugar().Errorf("Failed to use password reset token: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit password reset token: %v", err)
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteForUser removes a user's reset tokens, so that links sent before
+// the password changed stop working.
+func (r *PasswordResetRepository) DeleteForUser(userID int) error {
+	_, err := r.db.Exec("DELETE FROM password_reset_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to delete password reset tokens: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *PasswordResetRepository) DeleteExpired() error {
+	_, err := r.db.Exec("DELETE FROM password_reset_tokens WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		logger.Sugar().Errorf("Failed to delete expired password reset tokens: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+type ProjectRepository struct {
+	db *sql.DB
+}
+
+func NewProjectRepository() *ProjectRepository {
+	return &ProjectRepository{
+		db: database.DB,
+	}
+}
+
+// projectCondition restricts column, a project_id column, to the projects
+// in scope. Every query serving a user's request includes it, so rows of
+// other projects are never read or changed.
+func projectCondition(column string, scope models.ProjectScope) (string, []interface{}) {
+	condition := column + " IN (SELECT project_id FROM project_members WHERE user_id = ?)"
+	args := []interface{}{scope.UserID}
+	if scope.ProjectID > 0 {
+		condition += " AND " + column + " = ?"
+		args = append(args, scope.ProjectID)
+	}
+	return condition, args
+}
+
+// projectColumns lists the columns scanProject expects, for a query joining
+// projects p with the requesting user's membership m.
+const projectColumns = `p.id, p.name, m.role, p.created_by, p.created_at, p.updated_at`
+
+func scanProject(row rowScanner) (*models.Project, error) {
+	var project models.Project
+	var createdBy sql.NullInt64
+
+	err := row.Scan(&project.ID, &project.Name, &project.Role, &createdBy, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		project.CreatedBy = &id
+	}
+
+	return &project, nil
+}
+
+// Create stores a new project with ownerID as its first owner.
+func (r *ProjectRepository) Create(name string, ownerID int) (*models.Project, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin project transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO projects (name, created_by) VALUES (?, ?)", name, ownerID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create project: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, ?)
+	`, id, ownerID, models.ProjectRoleOwner)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to add project owner: %v", err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit project: %v", err)
+		return nil, err
+	}
+
+	return r.GetForMember(ownerID, int(id))
+}
+
+// GetForMember returns the project with the given ID if userID is one of
+// its members, or nil otherwise.
+func (r *ProjectRepository) GetForMember(userID, id int) (*models.Project, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM projects p JOIN project_members m ON m.project_id = p.id
+		WHERE m.user_id = ? AND p.id = ?
+	`, projectColumns)
+
+	project, err := scanProject(r.db.QueryRow(query, userID, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get project by ID: %v", err)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// GetAllForMember returns the projects userID is a member of, oldest first.
+func (r *ProjectRepository) GetAllForMember(userID int) ([]models.Project, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM projects p JOIN project_members m ON m.project_id = p.id
+		WHERE m.user_id = ?
+		ORDER BY p.id
+	`, projectColumns)
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get projects: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan project: %v", err)
+			return nil, err
+		}
+		projects = append(projects, *project)
+	}
+
+	return projects, nil
+}
+
+// Delete removes a project together with its URLs, schedules, imports and
+// webhook subscriptions.
+func (r *ProjectRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin project deletion: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"crawl_schedules", "import_jobs", "webhook_subscriptions", "crawl_urls"} {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE project_id = ?", table), id); err != nil {
+			logger.Sugar().Errorf("Failed to delete %s of project %d: %v", table, id, err)
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = ?", id); err != nil {
+		logger.Sugar().Errorf("Failed to delete project: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit project deletion: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetMembers returns the members of a project, owners first.
+func (r *ProjectRepository) GetMembers(projectID int) ([]models.ProjectMember, error) {
+	rows, err := r.db.Query(`
+		SELECT m.project_id, m.user_id, u.name, u.email, m.role, m.created_at
+		FROM project_members m JOIN users u ON u.id = m.user_id
+		WHERE m.project_id = ?
+		ORDER BY m.role = ? DESC, u.name, u.id
+	`, projectID, models.ProjectRoleOwner)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get project members: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ProjectMember{}
+	for rows.Next() {
+		var member models.ProjectMember
+		var email sql.NullString
+
+		err := rows.Scan(&member.ProjectID, &member.UserID, &member.Name, &email, &member.Role, &member.CreatedAt)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan project member: %v", err)
+			return nil, err
+		}
+
+		if email.Valid {
+			member.Email = email.String
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// SetMember adds userID to a project with the given role, or changes the
+// role of an existing member.
+func (r *ProjectRepository) SetMember(projectID, userID int, role string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = VALUES(role)
+	`, projectID, userID, role)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to set project member: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *ProjectRepository) RemoveMember(projectID, userID int) error {
+	_, err := r.db.Exec("DELETE FROM project_members WHERE project_id = ? AND user_id = ?", projectID, userID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to remove project member: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetMemberRole returns userID's role in a project, or an empty string if
+// userID is not a member.
+func (r *ProjectRepository) GetMemberRole(projectID, userID int) (string, error) {
+	var role string
+	err := r.db.QueryRow(`
+		SELECT role FROM project_members WHERE project_id = ? AND user_id = ?
+	`, projectID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		logger.Sugar().Errorf("Failed to get project member role: %v", err)
+		return "", err
+	}
+
+	return role, nil
+}
+
+func (r *ProjectRepository) CountOwners(projectID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM project_members WHERE project_id = ? AND role = ?
+	`, projectID, models.ProjectRoleOwner).Scan(&count)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to count project owners: %v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetProjectIDs returns the IDs of the projects userID is a member of.
+func (r *ProjectRepository) GetProjectIDs(userID int) ([]int, error) {
+	rows, err := r.db.Query("SELECT project_id FROM project_members WHERE user_id = ? ORDER BY project_id", userID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get project IDs: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			logger.Sugar().Errorf("Failed to scan project ID: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (r *ProjectRepository) UserExists(userID int) (bool, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", userID).Scan(&count); err != nil {
+		logger.Sugar().Errorf("Failed to look up user: %v", err)
+		return false, err
+	}
+
+	return count > 0, nil
+}
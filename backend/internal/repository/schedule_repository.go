@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository() *ScheduleRepository {
+	return &ScheduleRepository{
+		db: database.DB,
+	}
+}
+
+const scheduleColumns = `id, project_id, name, cron_expression, timezone, status,
+	last_run_at, next_run_at, created_at, updated_at`
+
+func scanSchedule(row rowScanner) (*models.CrawlSchedule, error) {
+	var schedule models.CrawlSchedule
+	var projectID sql.NullInt64
+	var name sql.NullString
+	var lastRunAt, nextRunAt sql.NullTime
+
+	err := row.Scan(
+		&schedule.ID, &projectID, &name, &schedule.CronExpression, &schedule.Timezone, &schedule.Status,
+		&lastRunAt, &nextRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.ProjectID = int(projectID.Int64)
+	if name.Valid {
+		schedule.Name = name.String
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	if nextRunAt.Valid {
+		schedule.NextRunAt = &nextRunAt.Time
+	}
+
+	return &schedule, nil
+}
+
+func (r *ScheduleRepository) Create(schedule *models.CrawlSchedule) (*models.CrawlSchedule, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin schedule transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO crawl_schedules (project_id, name, cron_expression, timezone, status, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, schedule.ProjectID, schedule.Name, schedule.CronExpression, schedule.Timezone, schedule.Status, schedule.NextRunAt)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create schedule: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return nil, err
+	}
+
+	for _, urlID := range schedule.URLIDs {
+		_, err := tx.Exec("INSERT IGNORE INTO crawl_schedule_urls (schedule_id, crawl_url_id) VALUES (?, ?)", id, urlID)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to attach URL %d to schedule: %v", urlID, err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit schedule: %v", err)
+		return nil, err
+	}
+
+	return r.GetByID(int(id))
+}
+
+func (r *ScheduleRepository) GetByID(id int) (*models.CrawlSchedule, error) {
+	query := fmt.Sprintf("SELECT %s FROM crawl_schedules WHERE id = ?", scheduleColumns)
+
+	schedule, err := scanSchedule(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get schedule by ID: %v", err)
+		return nil, err
+	}
+
+	schedule.URLIDs, err = r.getURLIDs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// Get returns the schedule with the given ID if it is in scope, or nil
+// otherwise.
+func (r *ScheduleRepository) Get(scope models.ProjectScope, id int) (*models.CrawlSchedule, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM crawl_schedules WHERE id = ? AND %s", scheduleColumns, condition)
+
+	schedule, err := scanSchedule(r.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get schedule by ID: %v", err)
+		return nil, err
+	}
+
+	schedule.URLIDs, err = r.getURLIDs(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// GetAll returns the schedules in scope.
+func (r *ScheduleRepository) GetAll(scope models.ProjectScope) ([]models.CrawlSchedule, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM crawl_schedules WHERE %s ORDER BY id", scheduleColumns, condition)
+	return r.query(query, args...)
+}
+
+// GetDue returns active schedules whose next run time has passed.
+func (r *ScheduleRepository) GetDue(now time.Time) ([]models.CrawlSchedule, error) {
+	query := fmt.Sprintf(`
+		SELECT %s FROM crawl_schedules
+		WHERE status = ? AND next_run_at <= ?
+		ORDER BY next_run_at
+	`, scheduleColumns)
+	return r.query(query, models.ScheduleActive, now)
+}
+
+func (r *ScheduleRepository) query(query string, args ...interface{}) ([]models.CrawlSchedule, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get schedules: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.CrawlSchedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan schedule: %v", err)
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	rows.Close()
+
+	for i := range schedules {
+		schedules[i].URLIDs, err = r.getURLIDs(schedules[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) getURLIDs(scheduleID int) ([]int, error) {
+	rows, err := r.db.Query("SELECT crawl_url_id FROM crawl_schedule_urls WHERE schedule_id = ? ORDER BY crawl_url_id", scheduleID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get schedule URLs: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			logger.Sugar().Errorf("Failed to scan schedule URL: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// SetStatus pauses or resumes a schedule. nextRunAt is recorded alongside
+// so that a resumed schedule does not fire for runs missed while paused.
+func (r *ScheduleRepository) SetStatus(id int, status string, nextRunAt *time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE crawl_schedules SET status = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, status, nextRunAt, id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to update schedule status: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// FireSchedule advances a due schedule from scheduledAt to nextRunAt and
+// requeues its URLs that are not already queued or running, at scheduled
+// priority, in one transaction. It only fires for the first caller, so when
+// several backend instances find the same due schedule exactly one of them
+// fires it; the others get false. It returns the requeued URLs.
+func (r *ScheduleRepository) FireSchedule(id int, scheduledAt, firedAt, nextRunAt time.Time) (bool, []models.CrawlURL, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin schedule fire transaction: %v", err)
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE crawl_schedules SET last_run_at = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND next_run_at = ?
+	`, firedAt, nextRunAt, id, models.ScheduleActive, scheduledAt)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to mark schedule as fired: %v", err)
+		return false, nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, err
+	}
+	if affected != 1 {
+		return false, nil, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT u.id, u.project_id, u.url FROM crawl_urls u JOIN crawl_schedule_urls su ON su.crawl_url_id = u.id
+		WHERE su.schedule_id = ? AND u.status NOT IN (?, ?)
+		FOR UPDATE
+	`, id, models.StatusQueued, models.StatusRunning)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select scheduled URLs: %v", err)
+		return false, nil, err
+	}
+
+	var crawlURLs []models.CrawlURL
+	for rows.Next() {
+		crawlURL := models.CrawlURL{Status: models.StatusQueued}
+		var projectID sql.NullInt64
+		if err := rows.Scan(&crawlURL.ID, &projectID, &crawlURL.URL); err != nil {
+			rows.Close()
+			logger.Sugar().Errorf("Failed to scan scheduled URL: %v", err)
+			return false, nil, err
+		}
+		crawlURL.ProjectID = int(projectID.Int64)
+		crawlURL.Priority = models.PriorityScheduled
+		crawlURLs = append(crawlURLs, crawlURL)
+	}
+	rows.Close()
+
+	if len(crawlURLs) > 0 {
+		args := []interface{}{models.StatusQueued, models.PriorityScheduled}
+		for _, crawlURL := range crawlURLs {
+			args = append(args, crawlURL.ID)
+		}
+		query := fmt.Sprintf(`
+			UPDATE crawl_urls SET status = ?, priority = ?, error_message = '', attempts = 0, next_attempt_at = NULL
+			WHERE id IN (%s)
+		`, strings.Repeat("?,", len(crawlURLs)-1)+"?")
+		if _, err := tx.Exec(query, args...); err != nil {
+			logger.Sugar().Errorf("Failed to queue scheduled URLs: %v", err)
+			return false, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit schedule fire: %v", err)
+		return false, nil, err
+	}
+
+	return true, crawlURLs, nil
+}
+
+func (r *ScheduleRepository) Delete(id int) error {
+	_, err := r.db.Exec("DELETE FROM crawl_schedules WHERE id = ?", id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to delete schedule: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// CountExistingURLs returns how many of ids are URLs of projectID.
+func (r *ScheduleRepository) CountExistingURLs(projectID int, ids []int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.Repeat("?,", len(ids)-1) + "?"
+	args := []interface{}{projectID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM crawl_urls WHERE project_id = ? AND id IN (%s)", placeholders)
+	if err := r.db.QueryRow(query, args...).Scan(&count); err != nil {
+		logger.Sugar().Errorf("Failed to count crawl URLs: %v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
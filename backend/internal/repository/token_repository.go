@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the
+// token itself is kept.
+type RefreshToken struct {
+	ID              int
+	UserID          int
+	TokenHash       string
+	FamilyID        string
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+}
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository() *TokenRepository {
+	return &TokenRepository{
+		db: database.DB,
+	}
+}
+
+func (r *TokenRepository) CreateRefreshToken(token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, access_jti, access_expires_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.Exec(query,
+		token.UserID, token.TokenHash, token.FamilyID,
+		token.AccessJTI, token.AccessExpiresAt, token.ExpiresAt,
+	)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create refresh token: %v", err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return err
+	}
+	token.ID = int(id)
+
+	return nil
+}
+
+// GetRefreshToken returns the refresh token with the given hash, or nil if
+// there is none.
+func (r *TokenRepository) GetRefreshToken(hash string) (*RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, family_id, access_jti, access_expires_at,
+			expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = ?
+	`
+
+	var token RefreshToken
+	var usedAt, revokedAt sql.NullTime
+
+	err := r.db.QueryRow(query, hash).Scan(
+		&token.ID, &token.UserID, &token.TokenHash, &token.FamilyID, &token.AccessJTI,
+		&token.AccessExpiresAt, &token.ExpiresAt, &usedAt, &revokedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get refresh token: %v", err)
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// UseRefreshToken marks a refresh token as used. It returns false if the
+// token was already used, revoked or expired, which includes losing a race
+// against a concurrent refresh with the same token.
+func (r *TokenRepository) UseRefreshToken(id int) (bool, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE refresh_tokens SET used_at = ?
+		WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?
+	`, now, id, now)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to use refresh token: %v", err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get affected rows: %v", err)
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// GetFamilyByAccessToken returns the family of the refresh token issued
+// together with the access token jti, or an empty string if there is none.
+func (r *TokenRepository) GetFamilyByAccessToken(jti string) (string, error) {
+	var familyID string
+	err := r.db.QueryRow("SELECT family_id FROM refresh_tokens WHERE access_jti = ?", jti).Scan(&familyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		logger.Sugar().Errorf("Failed to get refresh token family: %v", err)
+		return "", err
+	}
+
+	return familyID, nil
+}
+
+// RevokeFamily revokes every refresh token of a family and the unexpired
+// access tokens issued with them.
+func (r *TokenRepository) RevokeFamily(familyID string) error {
+	return r.revokeRefreshTokens("family_id = ?", familyID)
+}
+
+// RevokeUser revokes every refresh token of a user and the unexpired access
+// tokens issued with them.
+func (r *TokenRepository) RevokeUser(userID int) error {
+	return r.revokeRefreshTokens("user_id = ?", userID)
+}
+
+func (r *TokenRepository) revokeRefreshTokens(condition string, arg interface{}) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin token revocation transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO revoked_tokens (jti, user_id, expires_at)
+		SELECT access_jti, user_id, access_expires_at
+		FROM refresh_tokens
+		WHERE `+condition+` AND revoked_at IS NULL AND access_expires_at > ?
+	`, arg, now)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to revoke access tokens: %v", err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE `+condition+` AND revoked_at IS NULL
+	`, now, arg)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to revoke refresh tokens: %v", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit token revocation: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAccessToken adds an access token to the denylist until it expires.
+func (r *TokenRepository) RevokeAccessToken(jti string, userID int, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT IGNORE INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)
+	`, jti, userID, expiresAt)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to revoke access token: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// IsRevoked reports whether an access token is on the denylist.
+func (r *TokenRepository) IsRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow("SELECT COUNT(*) > 0 FROM revoked_tokens WHERE jti = ?", jti).Scan(&revoked)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to check revoked token: %v", err)
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpired removes expired refresh tokens and denylist entries of
+// access tokens that have expired anyway.
+func (r *TokenRepository) DeleteExpired() error {
+	now := time.Now()
+	if _, err := r.db.Exec("DELETE FROM refresh_tokens WHERE expires_at <= ?", now); err != nil {
+		logger.Sugar().Errorf("Failed to delete expired refresh tokens: %v", err)
+		return err
+	}
+
+	if _, err := r.db.Exec("DELETE FROM revoked_tokens WHERE expires_at <= ?", now); err != nil {
+		logger.Sugar().Errorf("Failed to delete expired revoked tokens: %v", err)
+		return err
+	}
+
+	return nil
+}
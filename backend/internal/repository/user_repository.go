@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	"sykell-backend/internal/models"
 	"sykell-backend/pkg/database"
 	"sykell-backend/pkg/logger"
 )
@@ -13,6 +14,7 @@ type User struct {
 	Name      string    `json:"name"`
 	Email     string    `json:"email,omitempty"`
 	Password  string    `json:"-"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -28,7 +30,7 @@ func NewUserRepository() *UserRepository {
 }
 
 func (r *UserRepository) GetAll() ([]User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users ORDER BY id"
+	query := "SELECT id, name, email, role, created_at, updated_at FROM users ORDER BY id"
 	
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -42,7 +44,7 @@ func (r *UserRepository) GetAll() ([]User, error) {
 		var user User
 		var email sql.NullString
 		
-		err := rows.Scan(&user.ID, &user.Name, &email, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Name, &email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			logger.Sugar().Errorf("Failed to scan user: %v", err)
 			return nil, err
@@ -63,10 +65,10 @@ func (r *UserRepository) GetAll() ([]User, error) {
 	return users, nil
 }
 
-func (r *UserRepository) Create(name, email, password string) (*User, error) {
-	query := "INSERT INTO users (name, email, password) VALUES (?, ?, ?)"
+func (r *UserRepository) Create(name, email, password, role string) (*User, error) {
+	query := "INSERT INTO users (name, email, password, role) VALUES (?, ?, ?, ?)"
 	
-	result, err := r.db.Exec(query, name, email, password)
+	result, err := r.db.Exec(query, name, email, password, role)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to create user: %v", err)
 		return nil, err
@@ -82,12 +84,12 @@ func (r *UserRepository) Create(name, email, password string) (*User, error) {
 }
 
 func (r *UserRepository) GetByID(id int) (*User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users WHERE id = ?"
+	query := "SELECT id, name, email, role, created_at, updated_at FROM users WHERE id = ?"
 	
 	var user User
 	var email sql.NullString
 	
-	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Name, &email, &user.CreatedAt, &user.UpdatedAt)
+	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Name, &email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -104,12 +106,12 @@ func (r *UserRepository) GetByID(id int) (*User, error) {
 }
 
 func (r *UserRepository) GetByName(name string) (*User, error) {
-	query := "SELECT id, name, email, password, created_at, updated_at FROM users WHERE name = ?"
+	query := "SELECT id, name, email, password, role, created_at, updated_at FROM users WHERE name = ?"
 	
 	var user User
-	var email sql.NullString
+	var email, password sql.NullString
 	
-	err := r.db.QueryRow(query, name).Scan(&user.ID, &user.Name, &email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	err := r.db.QueryRow(query, name).Scan(&user.ID, &user.Name, &email, &password, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -121,6 +123,9 @@ func (r *UserRepository) GetByName(name string) (*User, error) {
 	if email.Valid {
 		user.Email = email.String
 	}
+	if password.Valid {
+		user.Password = password.String
+	}
 
 	return &user, nil
 }
@@ -137,12 +142,141 @@ func (r *UserRepository) Update(id int, name, email string) (*User, error) {
 	return r.GetByID(id)
 }
 
-func (r *UserRepository) Delete(id int) error {
-	query := "DELETE FROM users WHERE id = ?"
-	
-	_, err := r.db.Exec(query, id)
+// Delete deletes a user. Deleting the only admin is refused and returns
+// false.
+func (r *UserRepository) Delete(id int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin user deletion transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	role, admins, err := lockUserAndAdmins(tx, id)
 	if err != nil {
+		return false, err
+	}
+	if role == models.RoleAdmin && admins <= 1 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
 		logger.Sugar().Errorf("Failed to delete user: %v", err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit user deletion: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetRole changes a user's role and returns the role they had, or an empty
+// string if there is no such user. Taking the admin role from the only
+// admin is refused and returns false. The change takes effect with the
+// user's next token.
+func (r *UserRepository) SetRole(id int, role string) (previous string, ok bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin role change transaction: %v", err)
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	previous, admins, err := lockUserAndAdmins(tx, id)
+	if err != nil || previous == "" {
+		return "", false, err
+	}
+	if previous == models.RoleAdmin && role != models.RoleAdmin && admins <= 1 {
+		return previous, false, nil
+	}
+
+	_, err = tx.Exec("UPDATE users SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", role, id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to set user role: %v", err)
+		return "", false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit role change: %v", err)
+		return "", false, err
+	}
+
+	return previous, true, nil
+}
+
+// lockUserAndAdmins returns the role of a user, or an empty string if there
+// is no such user, and the number of admins. The admins and the user stay
+// locked until the transaction ends, so that concurrent demotions or
+// deletions cannot both see another admin left and remove the last two.
+func lockUserAndAdmins(tx *sql.Tx, id int) (role string, admins int, err error) {
+	err = tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? FOR UPDATE", models.RoleAdmin).Scan(&admins)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to lock admins: %v", err)
+		return "", 0, err
+	}
+
+	err = tx.QueryRow("SELECT role FROM users WHERE id = ? FOR UPDATE", id).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", admins, nil
+		}
+		logger.Sugar().Errorf("Failed to lock user: %v", err)
+		return "", 0, err
+	}
+
+	return role, admins, nil
+}
+
+func (r *UserRepository) CountByRole(role string) (int, error) {
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", role).Scan(&count); err != nil {
+		logger.Sugar().Errorf("Failed to count users by role: %v", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetByEmail returns the user with the given email address, or nil if there
+// is none.
+func (r *UserRepository) GetByEmail(email string) (*User, error) {
+	query := "SELECT id, name, email, role, created_at, updated_at FROM users WHERE email = ?"
+
+	var user User
+	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get user by email: %v", err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetPasswordHash returns the bcrypt hash of a user's password, or an empty
+// string if the user has no password.
+func (r *UserRepository) GetPasswordHash(id int) (string, error) {
+	var password sql.NullString
+	err := r.db.QueryRow("SELECT password FROM users WHERE id = ?", id).Scan(&password)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Sugar().Errorf("Failed to get user password: %v", err)
+		return "", err
+	}
+
+	return password.String, nil
+}
+
+// SetPassword stores the bcrypt hash of a user's new password. An empty hash
+// leaves the user unable to log in until the password is reset.
+func (r *UserRepository) SetPassword(id int, hash string) error {
+	_, err := r.db.Exec("UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", hash, id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to set user password: %v", err)
 		return err
 	}
 
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/database"
+	"sykell-backend/pkg/logger"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		db: database.DB,
+	}
+}
+
+const webhookSubscriptionColumns = `id, project_id, url, event_types, secret, active, created_at, updated_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	var projectID sql.NullInt64
+	var eventTypes string
+
+	err := row.Scan(
+		&subscription.ID, &projectID, &subscription.URL, &eventTypes, &subscription.Secret,
+		&subscription.Active, &subscription.CreatedAt, &subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.ProjectID = int(projectID.Int64)
+	subscription.EventTypes = splitList(eventTypes)
+	return &subscription, nil
+}
+
+func (r *WebhookRepository) CreateSubscription(subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_subscriptions (project_id, url, event_types, secret, active)
+		VALUES (?, ?, ?, ?, ?)
+	`, subscription.ProjectID, subscription.URL, strings.Join(subscription.EventTypes, ","), subscription.Secret, subscription.Active)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create webhook subscription: %v", err)
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get last insert ID: %v", err)
+		return nil, err
+	}
+
+	return r.GetSubscriptionByID(int(id))
+}
+
+func (r *WebhookRepository) GetSubscriptionByID(id int) (*models.WebhookSubscription, error) {
+	query := fmt.Sprintf("SELECT %s FROM webhook_subscriptions WHERE id = ?", webhookSubscriptionColumns)
+
+	subscription, err := scanWebhookSubscription(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get webhook subscription by ID: %v", err)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// GetSubscription returns the subscription with the given ID if it is in
+// scope, or nil otherwise.
+func (r *WebhookRepository) GetSubscription(scope models.ProjectScope, id int) (*models.WebhookSubscription, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM webhook_subscriptions WHERE id = ? AND %s", webhookSubscriptionColumns, condition)
+
+	subscription, err := scanWebhookSubscription(r.db.QueryRow(query, append([]interface{}{id}, args...)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Sugar().Errorf("Failed to get webhook subscription by ID: %v", err)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// GetSubscriptions returns the subscriptions in scope.
+func (r *WebhookRepository) GetSubscriptions(scope models.ProjectScope) ([]models.WebhookSubscription, error) {
+	condition, args := projectCondition("project_id", scope)
+	query := fmt.Sprintf("SELECT %s FROM webhook_subscriptions WHERE %s ORDER BY id", webhookSubscriptionColumns, condition)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get webhook subscriptions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan webhook subscription: %v", err)
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(id int) error {
+	_, err := r.db.Exec("DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to delete webhook subscription: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries adds a pending delivery of payload to every active
+// subscription of projectID to eventType and returns how many were queued.
+func (r *WebhookRepository) EnqueueDeliveries(projectID int, eventType, payload string) (int64, error) {
+	// event_types is a comma-separated list, which FIND_IN_SET matches exactly
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_type, payload, status, next_attempt_at)
+		SELECT id, ?, ?, ?, ? FROM webhook_subscriptions
+		WHERE active AND project_id = ? AND FIND_IN_SET(?, event_types) > 0
+	`, eventType, payload, models.DeliveryPending, time.Now(), projectID, eventType)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to enqueue webhook deliveries: %v", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries that are due,
+// together with their subscriptions. Their next attempt is pushed back by
+// lease, so other processes skip them while they are being sent and a
+// process that dies mid-delivery has them retried afterwards.
+func (r *WebhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]models.WebhookDelivery, map[int]*models.WebhookSubscription, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin delivery claim transaction: %v", err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.Query(`
+		SELECT id, subscription_id, event_type, payload, attempts
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`, models.DeliveryPending, now, limit)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to select due webhook deliveries: %v", err)
+		return nil, nil, err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventType, &delivery.Payload, &delivery.Attempts); err != nil {
+			rows.Close()
+			logger.Sugar().Errorf("Failed to scan webhook delivery: %v", err)
+			return nil, nil, err
+		}
+		delivery.Status = models.DeliveryPending
+		deliveries = append(deliveries, delivery)
+	}
+	rows.Close()
+
+	if len(deliveries) == 0 {
+		return nil, nil, nil
+	}
+
+	args := []interface{}{now.Add(lease)}
+	for _, delivery := range deliveries {
+		args = append(args, delivery.ID)
+	}
+	query := fmt.Sprintf("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (%s)",
+		strings.Repeat("?,", len(deliveries)-1)+"?")
+	if _, err := tx.Exec(query, args...); err != nil {
+		logger.Sugar().Errorf("Failed to claim webhook deliveries: %v", err)
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit delivery claim: %v", err)
+		return nil, nil, err
+	}
+
+	subscriptions := make(map[int]*models.WebhookSubscription)
+	for _, delivery := range deliveries {
+		if _, ok := subscriptions[delivery.SubscriptionID]; ok {
+			continue
+		}
+		subscription, err := r.GetSubscriptionByID(delivery.SubscriptionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		subscriptions[delivery.SubscriptionID] = subscription
+	}
+
+	return deliveries, subscriptions, nil
+}
+
+// FinishDeliveryAttempt records the outcome of sending a delivery: its new
+// status, the response received and, for pending deliveries, when to retry.
+func (r *WebhookRepository) FinishDeliveryAttempt(delivery *models.WebhookDelivery) error {
+	var statusCode, lastError interface{}
+	if delivery.LastStatusCode != 0 {
+		statusCode = delivery.LastStatusCode
+	}
+	if delivery.LastError != "" {
+		lastError = delivery.LastError
+	}
+
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries SET
+			status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?,
+			delivered_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, statusCode,
+		lastError, delivery.DeliveredAt, delivery.ID)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to update webhook delivery: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetDeliveries returns the most recent deliveries to a subscription, newest first.
+func (r *WebhookRepository) GetDeliveries(subscriptionID, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	var total int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = ?", subscriptionID).Scan(&total)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to count webhook deliveries: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT id, subscription_id, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, delivered_at, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE subscription_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, subscriptionID, limit, offset)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get webhook deliveries: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var nextAttemptAt, deliveredAt sql.NullTime
+		var lastStatusCode sql.NullInt64
+		var lastError sql.NullString
+
+		err := rows.Scan(
+			&delivery.ID, &delivery.SubscriptionID, &delivery.EventType, &delivery.Payload,
+			&delivery.Status, &delivery.Attempts, &nextAttemptAt,
+			&lastStatusCode, &lastError, &deliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt,
+		)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to scan webhook delivery: %v", err)
+			return nil, 0, err
+		}
+
+		if nextAttemptAt.Valid {
+			delivery.NextAttemptAt = &nextAttemptAt.Time
+		}
+		if lastStatusCode.Valid {
+			delivery.LastStatusCode = int(lastStatusCode.Int64)
+		}
+		if lastError.Valid {
+			delivery.LastError = lastError.String
+		}
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, total, nil
+}
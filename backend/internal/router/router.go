@@ -1,15 +1,50 @@
 package router
 
 import (
+	"os"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 
 	"sykell-backend/internal/handler"
 	"sykell-backend/internal/middleware"
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/openapi"
+	"sykell-backend/pkg/logger"
 )
 
+// proxyConfig returns the header that holds the client address behind a
+// reverse proxy, and the addresses of the proxies trusted to set it, from
+// PROXY_HEADER and TRUSTED_PROXIES. Without trusted proxies the header is
+// ignored, since any client could set it; requests are then told apart by
+// the address they connect from.
+func proxyConfig() (string, []string) {
+	header := strings.TrimSpace(os.Getenv("PROXY_HEADER"))
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	if header != "" && len(proxies) == 0 {
+		logger.Sugar().Warn("PROXY_HEADER is set without TRUSTED_PROXIES, ignoring it")
+		return "", nil
+	}
+	return header, proxies
+}
+
 func Setup() *fiber.App {
+	proxyHeader, trustedProxies := proxyConfig()
+
 	app := fiber.New(fiber.Config{
+		// Client addresses key the password reset throttle, so they are
+		// only read from the proxy header on requests from trusted proxies
+		ProxyHeader:             proxyHeader,
+		EnableTrustedProxyCheck: proxyHeader != "",
+		TrustedProxies:          trustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -32,29 +67,102 @@ func Setup() *fiber.App {
 	api := app.Group("/api")
 
 	// Public routes
+	api.Get("/openapi.json", handler.GetOpenAPIDocument) // OpenAPI 3 document of this API
 	api.Post("/login", handler.Login)
-	api.Post("/users", handler.CreateUser) // Allow public user registration
+	api.Post("/refresh", handler.RefreshSession)           // Exchange a refresh token for a new token pair
+	api.Get("/password/policy", handler.GetPasswordPolicy) // Rules new passwords have to meet
+	api.Post("/password/forgot", handler.ForgotPassword)   // Mail a password reset token
+	api.Post("/password/reset", handler.ResetPassword)     // Set a new password with a reset token
+	api.Post("/users", handler.CreateUser)                 // Allow public user registration
+
+	// Crawl event stream. Registered ahead of the protected group because
+	// EventSource clients pass their token in the query string.
+	api.Get("/crawler/events", middleware.JWTStreamMiddleware(), handler.StreamCrawlEvents)
 
 	// Protected routes
 	protected := api.Group("/", middleware.JWTMiddleware())
 
+	// Every role may read. Adding, changing and deleting crawl data needs an
+	// editor, and managing users needs an admin.
+	editors := middleware.RequireRole(models.RoleAdmin, models.RoleEditor)
+	admins := middleware.RequireRole(models.RoleAdmin)
+
+	// Session and password routes
+	protected.Post("/logout", handler.Logout)                  // End the current session
+	protected.Post("/logout/all", handler.LogoutEverywhere)    // End every session of the user
+	protected.Post("/password/change", handler.ChangePassword) // Change the user's password
+
 	// User routes
 	users := protected.Group("/users")
 	users.Get("/", handler.GetUsers)
 	users.Get("/:id", handler.GetUser)
-	users.Put("/:id", handler.UpdateUser)
-	users.Delete("/:id", handler.DeleteUser)
+	users.Put("/:id", middleware.RequireSelfOrRole("id", models.RoleAdmin), handler.UpdateUser)
+	users.Delete("/:id", admins, handler.DeleteUser)
+
+	// Project routes
+	projects := protected.Group("/projects")
+	projects.Post("/", editors, handler.CreateProject)                            // Create a project
+	projects.Get("/", handler.GetProjects)                                        // List the user's projects
+	projects.Get("/:id", handler.GetProject)                                      // Get a project
+	projects.Delete("/:id", editors, handler.DeleteProject)                       // Delete a project and its URLs
+	projects.Get("/:id/members", handler.GetProjectMembers)                       // List a project's members
+	projects.Post("/:id/members", editors, handler.AddProjectMember)              // Add a member or change their role
+	projects.Delete("/:id/members/:userId", editors, handler.RemoveProjectMember) // Remove a member
 
 	// Crawler routes
 	crawler := protected.Group("/crawler")
-	crawler.Post("/urls", handler.AddURL)               // Add single URL
-	crawler.Post("/urls/bulk", handler.BulkAddURLs)     // Add multiple URLs
-	crawler.Get("/urls", handler.GetCrawlURLs)          // Get all crawl URLs with pagination/filtering
-	crawler.Get("/urls/:id", handler.GetCrawlResult)    // Get detailed crawl result
-	crawler.Post("/urls/:id/crawl", handler.StartCrawl) // Start crawling a URL
-	crawler.Delete("/urls", handler.DeleteCrawlURLs)    // Delete multiple URLs
-	crawler.Post("/urls/recrawl", handler.ReCrawlURLs)  // Re-crawl multiple URLs
-	crawler.Get("/stats", handler.GetCrawlStats)        // Get crawl statistics
+	crawler.Post("/urls", editors, handler.AddURL)                 // Add single URL
+	crawler.Post("/urls/bulk", editors, handler.BulkAddURLs)       // Add multiple URLs
+	crawler.Get("/urls", handler.GetCrawlURLs)                     // Get all crawl URLs with pagination/filtering
+	crawler.Get("/urls/export", handler.ExportCrawlURLs)           // Export crawl URLs as CSV, JSONL or XLSX
+	crawler.Get("/urls/:id", handler.GetCrawlResult)               // Get detailed crawl result
+	crawler.Post("/urls/:id/crawl", editors, handler.StartCrawl)   // Start crawling a URL
+	crawler.Delete("/urls", editors, handler.DeleteCrawlURLs)      // Delete multiple URLs
+	crawler.Post("/urls/recrawl", editors, handler.ReCrawlURLs)    // Re-crawl multiple URLs
+	crawler.Post("/urls/:id/cancel", editors, handler.CancelCrawl) // Cancel a queued or running crawl
+	crawler.Post("/urls/cancel", editors, handler.CancelCrawls)    // Cancel multiple crawls
+	crawler.Get("/stats", handler.GetCrawlStats)                   // Get crawl statistics
+	crawler.Get("/search", handler.SearchPages)                    // Full-text search over crawled pages
+
+	// Import routes
+	crawler.Post("/imports", editors, handler.ImportURLs)   // Upload a CSV or text file of URLs
+	crawler.Get("/imports/:id", handler.GetImport)          // Get an import job's progress
+	crawler.Get("/imports/:id/rows", handler.GetImportRows) // Get an import job's per-row report
+
+	// Schedule routes
+	crawler.Post("/schedules", editors, handler.CreateSchedule)            // Create a recurring crawl
+	crawler.Get("/schedules", handler.GetSchedules)                        // List schedules
+	crawler.Post("/schedules/:id/pause", editors, handler.PauseSchedule)   // Pause a schedule
+	crawler.Post("/schedules/:id/resume", editors, handler.ResumeSchedule) // Resume a paused schedule
+	crawler.Delete("/schedules/:id", editors, handler.DeleteSchedule)      // Delete a schedule
+
+	// Webhook routes
+	webhooks := protected.Group("/webhooks")
+	webhooks.Post("/", editors, handler.CreateWebhook)            // Subscribe to crawl events
+	webhooks.Get("/", handler.GetWebhooks)                        // List subscriptions
+	webhooks.Delete("/:id", editors, handler.DeleteWebhook)       // Delete a subscription
+	webhooks.Get("/:id/deliveries", handler.GetWebhookDeliveries) // Get a subscription's delivery log
+
+	// Admin routes
+	admin := protected.Group("/admin", admins)
+	admin.Get("/crawler/pool", handler.GetWorkerPoolStats)       // Get worker pool queue depth and active workers
+	admin.Put("/users/:id/role", handler.AssignUserRole)         // Assign a user's role
+	admin.Post("/users/:id/password", handler.ResetUserPassword) // Set a user's password or mail a reset link
+
+	checkDocumented(app)
 
 	return app
 }
+
+// checkDocumented warns about API routes that the OpenAPI document does not
+// describe, so that a new route is not shipped without its schema.
+func checkDocumented(app *fiber.App) {
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		if !openapi.Documented(route.Method, route.Path) {
+			logger.Sugar().Warnf("Route %s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+)
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown,
+// expired, revoked or already used.
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// Session is the token pair issued on login and on every refresh. The
+// access token is a short-lived JWT; the refresh token is an opaque string
+// that can be exchanged for a new pair once.
+type Session struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func tokenRepo() *repository.TokenRepository {
+	return repository.NewTokenRepository()
+}
+
+func accessTokenTTL() time.Duration {
+	return getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+func refreshTokenTTL() time.Duration {
+	return getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+// startSession issues a token pair for user in a new refresh token family.
+func startSession(user *repository.User) (*Session, error) {
+	// Logins are rare enough to clean up after
+	tokenRepo().DeleteExpired()
+
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueSession(user, familyID)
+}
+
+// issueSession signs an access token for user and stores the refresh token
+// issued with it in familyID.
+func issueSession(user *repository.User, familyID string) (*Session, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessExpiresAt := now.Add(accessTokenTTL())
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"name":    user.Name,
+		"role":    user.Role,
+		"jti":     jti,
+		"iat":     now.Unix(),
+		"exp":     accessExpiresAt.Unix(),
+	})
+
+	signed, err := token.SignedString(getJWTSecret())
+	if err != nil {
+		logger.Sugar().Errorf("JWT signing error: %v", err)
+		return nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = tokenRepo().CreateRefreshToken(&repository.RefreshToken{
+		UserID:          user.ID,
+		TokenHash:       hashToken(refreshToken),
+		FamilyID:        familyID,
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExpiresAt,
+		ExpiresAt:       now.Add(refreshTokenTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		Token:        signed,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(accessTokenTTL().Seconds()),
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new token pair. Every
+// refresh token works once: presenting a used one again means it was
+// copied, so the whole family is revoked and its holder has to log in.
+func RefreshSession(refreshToken string) (*Session, error) {
+	stored, err := tokenRepo().GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil && stored.RevokedAt == nil {
+		logger.Sugar().Warnf("Refresh token reused for user %d, revoking its session", stored.UserID)
+		if err := tokenRepo().RevokeFamily(stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	used, err := tokenRepo().UseRefreshToken(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Reload the user so that role changes apply from the next refresh
+	user, err := userRepo().GetByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return issueSession(user, stored.FamilyID)
+}
+
+// Logout revokes the access token jti and the session it belongs to.
+func Logout(userID int, jti string, expiresAt time.Time) error {
+	familyID, err := tokenRepo().GetFamilyByAccessToken(jti)
+	if err != nil {
+		return err
+	}
+	if familyID != "" {
+		if err := tokenRepo().RevokeFamily(familyID); err != nil {
+			return err
+		}
+	}
+
+	return tokenRepo().RevokeAccessToken(jti, userID, expiresAt)
+}
+
+// LogoutEverywhere revokes every session of a user, and with them all of
+// the user's unexpired access tokens.
+func LogoutEverywhere(userID int, jti string, expiresAt time.Time) error {
+	if err := tokenRepo().RevokeUser(userID); err != nil {
+		return err
+	}
+
+	return tokenRepo().RevokeAccessToken(jti, userID, expiresAt)
+}
+
+// TokenRevoked reports whether the access token jti has been revoked.
+func TokenRevoked(jti string) (bool, error) {
+	return tokenRepo().IsRevoked(jti)
+}
+
+// hashToken is the form refresh tokens are stored and looked up in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"sykell-backend/pkg/logger"
+)
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Sugar().Warnf("Invalid value %q for %s, using default %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Sugar().Warnf("Invalid value %q for %s, using default %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Sugar().Warnf("Invalid value %q for %s, using default %t", value, key, defaultValue)
+		return defaultValue
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"sykell-backend/internal/models"
+)
+
+// eventBufferSize is how many events a subscriber may fall behind by
+// before further events to it are dropped.
+const eventBufferSize = 64
+
+// CrawlEventBus fans out crawl events to subscribers within this process.
+// Publishing never blocks: a subscriber that does not keep up misses events
+// rather than stalling crawls.
+type CrawlEventBus struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscription]struct{}
+	closed      bool
+}
+
+type eventSubscription struct {
+	events   chan models.CrawlEvent
+	ids      map[int]bool // nil matches every URL
+	projects map[int]bool
+}
+
+var (
+	defaultCrawlEventBus     *CrawlEventBus
+	defaultCrawlEventBusOnce sync.Once
+)
+
+// DefaultCrawlEventBus returns the process-wide event bus.
+func DefaultCrawlEventBus() *CrawlEventBus {
+	defaultCrawlEventBusOnce.Do(func() {
+		defaultCrawlEventBus = NewCrawlEventBus()
+	})
+	return defaultCrawlEventBus
+}
+
+func NewCrawlEventBus() *CrawlEventBus {
+	return &CrawlEventBus{
+		subscribers: make(map[*eventSubscription]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events for the given URL IDs,
+// or for all URLs if ids is empty, within the given projects, and a
+// function that ends the subscription. Without projects nothing is
+// received. The channel is closed when the subscription ends or the bus is
+// closed.
+func (b *CrawlEventBus) Subscribe(ids, projectIDs []int) (<-chan models.CrawlEvent, func()) {
+	sub := &eventSubscription{
+		events:   make(chan models.CrawlEvent, eventBufferSize),
+		projects: make(map[int]bool, len(projectIDs)),
+	}
+	for _, id := range projectIDs {
+		sub.projects[id] = true
+	}
+	if len(ids) > 0 {
+		sub.ids = make(map[int]bool, len(ids))
+		for _, id := range ids {
+			sub.ids[id] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Publish delivers event to every matching subscriber.
+func (b *CrawlEventBus) Publish(event models.CrawlEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if !sub.projects[event.ProjectID] || (sub.ids != nil && !sub.ids[event.CrawlURLID]) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// Close ends every subscription, so that long-lived streams let the server
+// shut down.
+func (b *CrawlEventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.events)
+	}
+	b.subscribers = nil
+}
+
+// publishCrawlEvent announces the current state of crawlURL.
+func publishCrawlEvent(eventType string, crawlURL *models.CrawlURL) {
+	DefaultCrawlEventBus().Publish(models.CrawlEvent{
+		Type:         eventType,
+		CrawlURLID:   crawlURL.ID,
+		ProjectID:    crawlURL.ProjectID,
+		URL:          crawlURL.URL,
+		Status:       crawlURL.Status,
+		ErrorMessage: crawlURL.ErrorMessage,
+	})
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+	"sykell-backend/pkg/xlsx"
+)
+
+// ErrInvalidExport is returned for exports with an unknown format or detail.
+var ErrInvalidExport = errors.New("invalid export request")
+
+// ExportOptions selects the URLs to export, using the same filter and sort
+// as the URL list, and the format and per-crawl detail to export them with.
+type ExportOptions struct {
+	Format  string
+	Filter  models.CrawlURLFilter
+	Sort    []models.SortField
+	Include []string
+}
+
+// CrawlExport is an export whose rows are read from the database as they
+// are written out. Write must be called exactly once to release the cursor.
+type CrawlExport struct {
+	Format  string
+	repo    *repository.CrawlerRepository
+	cursor  *repository.CrawlURLCursor
+	include map[string]bool
+}
+
+// exportContentTypes maps each export format to its MIME type.
+var exportContentTypes = map[string]string{
+	models.ExportCSV:   "text/csv; charset=utf-8",
+	models.ExportJSONL: "application/x-ndjson",
+	models.ExportXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exportDetails lists the per-crawl detail that can be included, in the
+// order its columns appear in tabular exports.
+var exportDetails = []string{
+	models.ExportBrokenLinks,
+	models.ExportLinkIssues,
+	models.ExportLinkChecks,
+	models.ExportAttemptHistory,
+}
+
+// OpenExport validates opts and opens a cursor over the matching URLs.
+func (s *CrawlerService) OpenExport(opts ExportOptions) (*CrawlExport, error) {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format == "" {
+		format = models.ExportCSV
+	}
+	if _, ok := exportContentTypes[format]; !ok {
+		return nil, fmt.Errorf("%w: format must be csv, jsonl or xlsx", ErrInvalidExport)
+	}
+
+	include := make(map[string]bool)
+	for _, detail := range opts.Include {
+		detail = strings.TrimSpace(detail)
+		if detail == "" {
+			continue
+		}
+		if !containsString(exportDetails, detail) {
+			return nil, fmt.Errorf("%w: include must be a list of %s", ErrInvalidExport, strings.Join(exportDetails, ", "))
+		}
+		include[detail] = true
+	}
+
+	cursor, err := s.repo.OpenCrawlURLCursor(opts.Filter, opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CrawlExport{
+		Format:  format,
+		repo:    s.repo,
+		cursor:  cursor,
+		include: include,
+	}, nil
+}
+
+// ContentType returns the MIME type of the export.
+func (e *CrawlExport) ContentType() string {
+	return exportContentTypes[e.Format]
+}
+
+// Write writes every matching URL to w and closes the cursor.
+func (e *CrawlExport) Write(w io.Writer) error {
+	defer e.cursor.Close()
+
+	out, err := e.newWriter(w)
+	if err != nil {
+		return err
+	}
+
+	rows := 0
+	for {
+		crawlURL, err := e.cursor.Next()
+		if err != nil {
+			return err
+		}
+		if crawlURL == nil {
+			break
+		}
+
+		row, err := e.loadDetail(crawlURL)
+		if err != nil {
+			return err
+		}
+		if err := out.write(row); err != nil {
+			return err
+		}
+		rows++
+	}
+
+	if err := out.close(); err != nil {
+		return err
+	}
+
+	logger.Sugar().Infof("Exported %d crawl URLs as %s", rows, e.Format)
+	return nil
+}
+
+// loadDetail fetches the detail requested for one URL.
+func (e *CrawlExport) loadDetail(crawlURL *models.CrawlURL) (*models.CrawlExportRow, error) {
+	row := &models.CrawlExportRow{CrawlURL: *crawlURL}
+	var err error
+
+	if e.include[models.ExportBrokenLinks] {
+		if row.BrokenLinks, err = e.repo.GetBrokenLinks(crawlURL.ID); err != nil {
+			return nil, err
+		}
+	}
+	if e.include[models.ExportLinkIssues] {
+		if row.LinkIssues, err = e.repo.GetLinkIssues(crawlURL.ID); err != nil {
+			return nil, err
+		}
+	}
+	if e.include[models.ExportLinkChecks] {
+		if row.LinkChecks, err = e.repo.GetLinkChecks(crawlURL.ID); err != nil {
+			return nil, err
+		}
+	}
+	if e.include[models.ExportAttemptHistory] {
+		if row.AttemptHistory, err = e.repo.GetCrawlAttempts(crawlURL.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+// exportWriter writes export rows in one format.
+type exportWriter interface {
+	write(row *models.CrawlExportRow) error
+	close() error
+}
+
+func (e *CrawlExport) newWriter(w io.Writer) (exportWriter, error) {
+	switch e.Format {
+	case models.ExportJSONL:
+		return &jsonlExportWriter{encoder: json.NewEncoder(w)}, nil
+	case models.ExportXLSX:
+		columns := e.columns()
+		sheet, err := xlsx.NewWriter(w, "Crawl URLs")
+		if err != nil {
+			return nil, err
+		}
+		if err := sheet.WriteRow(columnHeader(columns)); err != nil {
+			return nil, err
+		}
+		return &xlsxExportWriter{sheet: sheet, columns: columns}, nil
+	default:
+		columns := e.columns()
+		out := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.name
+		}
+		if err := out.Write(header); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter{out: out, columns: columns}, nil
+	}
+}
+
+type jsonlExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlExportWriter) write(row *models.CrawlExportRow) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *jsonlExportWriter) close() error {
+	return nil
+}
+
+type csvExportWriter struct {
+	out     *csv.Writer
+	columns []exportColumn
+}
+
+func (w *csvExportWriter) write(row *models.CrawlExportRow) error {
+	record := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		switch v := column.value(row).(type) {
+		case nil:
+		case string:
+			record[i] = escapeFormula(v)
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return w.out.Write(record)
+}
+
+func (w *csvExportWriter) close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+type xlsxExportWriter struct {
+	sheet   *xlsx.Writer
+	columns []exportColumn
+}
+
+func (w *xlsxExportWriter) write(row *models.CrawlExportRow) error {
+	cells := make([]interface{}, len(w.columns))
+	for i, column := range w.columns {
+		cells[i] = column.value(row)
+	}
+	return w.sheet.WriteRow(cells)
+}
+
+func (w *xlsxExportWriter) close() error {
+	return w.sheet.Close()
+}
+
+// escapeFormula keeps spreadsheet applications from evaluating page titles
+// and other crawled text that happens to start like a formula.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// exportColumn is one column of a tabular export.
+type exportColumn struct {
+	name  string
+	value func(row *models.CrawlExportRow) interface{}
+}
+
+func columnHeader(columns []exportColumn) []interface{} {
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	return header
+}
+
+// optionalTime returns t, or nil so that the cell is left empty.
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// crawlURLExportColumns are the columns every tabular export has.
+var crawlURLExportColumns = []exportColumn{
+	{"id", func(r *models.CrawlExportRow) interface{} { return r.ID }},
+	{"url", func(r *models.CrawlExportRow) interface{} { return r.URL }},
+	{"normalized_url", func(r *models.CrawlExportRow) interface{} { return r.NormalizedURL }},
+	{"status", func(r *models.CrawlExportRow) interface{} { return r.Status }},
+	{"tags", func(r *models.CrawlExportRow) interface{} { return strings.Join(r.Tags, ", ") }},
+	{"title", func(r *models.CrawlExportRow) interface{} { return r.Title }},
+	{"html_version", func(r *models.CrawlExportRow) interface{} { return r.HTMLVersion }},
+	{"h1_count", func(r *models.CrawlExportRow) interface{} { return r.H1Count }},
+	{"h2_count", func(r *models.CrawlExportRow) interface{} { return r.H2Count }},
+	{"h3_count", func(r *models.CrawlExportRow) interface{} { return r.H3Count }},
+	{"h4_count", func(r *models.CrawlExportRow) interface{} { return r.H4Count }},
+	{"h5_count", func(r *models.CrawlExportRow) interface{} { return r.H5Count }},
+	{"h6_count", func(r *models.CrawlExportRow) interface{} { return r.H6Count }},
+	{"internal_links_count", func(r *models.CrawlExportRow) interface{} { return r.InternalLinksCount }},
+	{"external_links_count", func(r *models.CrawlExportRow) interface{} { return r.ExternalLinksCount }},
+	{"inaccessible_links_count", func(r *models.CrawlExportRow) interface{} { return r.InaccessibleLinksCount }},
+	{"checked_links_count", func(r *models.CrawlExportRow) interface{} { return r.CheckedLinksCount }},
+	{"cached_links_count", func(r *models.CrawlExportRow) interface{} { return r.CachedLinksCount }},
+	{"unchecked_links_count", func(r *models.CrawlExportRow) interface{} { return r.UncheckedLinksCount }},
+	{"mailto_links_count", func(r *models.CrawlExportRow) interface{} { return r.MailtoLinksCount }},
+	{"tel_links_count", func(r *models.CrawlExportRow) interface{} { return r.TelLinksCount }},
+	{"javascript_links_count", func(r *models.CrawlExportRow) interface{} { return r.JavascriptLinksCount }},
+	{"data_links_count", func(r *models.CrawlExportRow) interface{} { return r.DataLinksCount }},
+	{"other_scheme_links_count", func(r *models.CrawlExportRow) interface{} { return r.OtherSchemeLinksCount }},
+	{"has_login_form", func(r *models.CrawlExportRow) interface{} { return r.HasLoginForm }},
+	{"error_message", func(r *models.CrawlExportRow) interface{} { return r.ErrorMessage }},
+	{"attempts", func(r *models.CrawlExportRow) interface{} { return r.Attempts }},
+	{"last_crawled_at", func(r *models.CrawlExportRow) interface{} { return optionalTime(r.LastCrawledAt) }},
+	{"created_at", func(r *models.CrawlExportRow) interface{} { return r.CreatedAt }},
+	{"updated_at", func(r *models.CrawlExportRow) interface{} { return r.UpdatedAt }},
+}
+
+// detailExportColumns flatten the per-crawl detail into one cell per kind,
+// with one line per item.
+var detailExportColumns = map[string]exportColumn{
+	models.ExportBrokenLinks: {models.ExportBrokenLinks, func(r *models.CrawlExportRow) interface{} {
+		lines := make([]string, len(r.BrokenLinks))
+		for i, link := range r.BrokenLinks {
+			lines[i] = link.URL + " (" + linkStatus(link.StatusCode, link.ErrorMessage) + ")"
+		}
+		return strings.Join(lines, "\n")
+	}},
+	models.ExportLinkIssues: {models.ExportLinkIssues, func(r *models.CrawlExportRow) interface{} {
+		lines := make([]string, len(r.LinkIssues))
+		for i, issue := range r.LinkIssues {
+			lines[i] = issue.IssueType + ": " + issue.URL
+		}
+		return strings.Join(lines, "\n")
+	}},
+	models.ExportLinkChecks: {models.ExportLinkChecks, func(r *models.CrawlExportRow) interface{} {
+		lines := make([]string, len(r.LinkChecks))
+		for i, check := range r.LinkChecks {
+			lines[i] = check.URL + " (" + linkStatus(check.StatusCode, check.ErrorMessage) + ")"
+		}
+		return strings.Join(lines, "\n")
+	}},
+	models.ExportAttemptHistory: {models.ExportAttemptHistory, func(r *models.CrawlExportRow) interface{} {
+		lines := make([]string, len(r.AttemptHistory))
+		for i, attempt := range r.AttemptHistory {
+			line := "#" + strconv.Itoa(attempt.Attempt) + " " + attempt.Outcome
+			if attempt.ErrorMessage != "" {
+				line += ": " + attempt.ErrorMessage
+			}
+			lines[i] = line
+		}
+		return strings.Join(lines, "\n")
+	}},
+}
+
+// columns returns the columns of a tabular export: the URL's own columns
+// followed by one column per requested kind of detail.
+func (e *CrawlExport) columns() []exportColumn {
+	columns := append([]exportColumn{}, crawlURLExportColumns...)
+	for _, detail := range exportDetails {
+		if e.include[detail] {
+			columns = append(columns, detailExportColumns[detail])
+		}
+	}
+	return columns
+}
+
+// linkStatus describes the result of checking a link: its status code, or
+// the error if no response was received.
+func linkStatus(statusCode int, errorMessage string) string {
+	if statusCode != 0 {
+		return strconv.Itoa(statusCode)
+	}
+	return errorMessage
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,525 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+)
+
+var (
+	// ErrImportNotFound is returned for operations on an unknown import job.
+	ErrImportNotFound = errors.New("import job not found")
+	// ErrInvalidImport is returned for uploads that cannot be imported at all.
+	ErrInvalidImport = errors.New("invalid import")
+
+	// errImportClaimLost stops a job whose claim another instance took over.
+	errImportClaimLost = errors.New("import job is no longer claimed")
+)
+
+const (
+	// importLease is how long a claim on an import job lasts without the
+	// claiming instance reporting progress.
+	importLease = 2 * time.Minute
+	// importBatchSize is how many rows are processed between reports.
+	importBatchSize = 100
+	// maxImportURLLength is the longest URL kept in a row report.
+	maxImportURLLength = 2048
+)
+
+// crawlProfiles are named sets of crawl options that imported rows can
+// refer to instead of spelling out every option.
+var crawlProfiles = map[string]models.CrawlOptions{
+	"default":  {},
+	"quick":    {LinkCheckMode: models.LinkCheckLimit, LinkCheckLimit: 10},
+	"thorough": {LinkCheckMode: models.LinkCheckAll},
+	"internal": {LinkCheckMode: models.LinkCheckInternal},
+}
+
+// CrawlImportService adds the URLs of uploaded CSV or plain-text files in
+// the background. Jobs and their files are stored in the database and
+// claimed with a lease, so an interrupted job is resumed, from the row
+// after the last one reported, by whichever instance claims it next.
+type CrawlImportService struct {
+	repo     *repository.ImportRepository
+	crawler  *CrawlerService
+	workerID string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var (
+	defaultCrawlImportService     *CrawlImportService
+	defaultCrawlImportServiceOnce sync.Once
+)
+
+// DefaultCrawlImportService returns the process-wide CrawlImportService.
+func DefaultCrawlImportService() *CrawlImportService {
+	defaultCrawlImportServiceOnce.Do(func() {
+		defaultCrawlImportService = NewCrawlImportService()
+	})
+	return defaultCrawlImportService
+}
+
+func NewCrawlImportService() *CrawlImportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CrawlImportService{
+		repo:     repository.NewImportRepository(),
+		crawler:  DefaultCrawlerService(),
+		workerID: DefaultCrawlWorkerPool().WorkerID(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// ImportOptions describes an uploaded file. Priority and Profile apply to
+// rows that do not set their own; Format is detected from the file name if
+// it is empty.
+type ImportOptions struct {
+	Filename string
+	Format   string
+	Priority string
+	Profile  string
+}
+
+// CreateImport validates an uploaded file, stores it as a pending job of
+// projectID and starts processing it in the background.
+func (s *CrawlImportService) CreateImport(ownerID, projectID int, opts ImportOptions, content []byte) (*models.ImportJob, error) {
+	format, err := importFormat(opts.Format, opts.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	priority, err := normalizePriority(opts.Priority, models.PriorityBulk)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	profile := strings.ToLower(strings.TrimSpace(opts.Profile))
+	if _, ok := crawlProfiles[profile]; profile != "" && !ok {
+		return nil, fmt.Errorf("%w: unknown profile %q", ErrInvalidImport, profile)
+	}
+
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("%w: file must be UTF-8 encoded", ErrInvalidImport)
+	}
+
+	// Parse up front so that malformed files are rejected at upload time
+	records, err := parseImportFile(format, string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: file contains no URLs", ErrInvalidImport)
+	}
+
+	job := &models.ImportJob{
+		Filename:  filepath.Base(opts.Filename),
+		Format:    format,
+		Priority:  priority,
+		Profile:   profile,
+		ProjectID: projectID,
+		TotalRows: len(records),
+	}
+	if ownerID > 0 {
+		job.OwnerID = &ownerID
+	}
+
+	job, err = s.repo.Create(job, string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	s.start(job.ID)
+	return job, nil
+}
+
+// GetImport returns the job with the given ID if it is in scope.
+func (s *CrawlImportService) GetImport(scope models.ProjectScope, id int) (*models.ImportJob, error) {
+	job, err := s.repo.Get(scope, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrImportNotFound
+	}
+	return job, nil
+}
+
+// GetImportRows returns a page of a job's row report, optionally limited
+// to accepted, duplicate or rejected rows.
+func (s *CrawlImportService) GetImportRows(scope models.ProjectScope, id int, result string, page, limit int) ([]models.ImportRow, int, error) {
+	if _, err := s.GetImport(scope, id); err != nil {
+		return nil, 0, err
+	}
+
+	switch result {
+	case "", models.ImportRowAccepted, models.ImportRowDuplicate, models.ImportRowRejected:
+	default:
+		return nil, 0, fmt.Errorf("%w: result must be accepted, duplicate or rejected", ErrInvalidImport)
+	}
+
+	return s.repo.GetRows(id, result, limit, (page-1)*limit)
+}
+
+// ResumeImports starts the jobs that are waiting or were left unfinished
+// by an instance that stopped. CrawlerJobProcessor calls it periodically.
+func (s *CrawlImportService) ResumeImports() {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	ids, err := s.repo.GetClaimableIDs(10)
+	if err != nil {
+		return
+	}
+	for _, id := range ids {
+		s.start(id)
+	}
+}
+
+// Shutdown stops processing imports. Jobs in progress report the rows
+// processed so far and are handed back to the queue, to be resumed by
+// another instance or after a restart.
+func (s *CrawlImportService) Shutdown() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *CrawlImportService) start(id int) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(id)
+	}()
+}
+
+// run claims a job and processes its remaining rows.
+func (s *CrawlImportService) run(id int) {
+	claimed, err := s.repo.Claim(id, s.workerID, importLease)
+	if err != nil || !claimed {
+		return
+	}
+
+	job, err := s.repo.GetByID(id)
+	if err != nil || job == nil {
+		return
+	}
+
+	content, err := s.repo.GetContent(id)
+	if err != nil {
+		return
+	}
+
+	records, err := parseImportFile(job.Format, content)
+	if err != nil {
+		s.repo.Finish(id, s.workerID, models.ImportFailed, err.Error())
+		return
+	}
+
+	lastLine, err := s.repo.GetLastLine(id)
+	if err != nil {
+		return
+	}
+	if lastLine > 0 {
+		logger.Sugar().Infof("Resuming import %d after line %d", id, lastLine)
+	}
+
+	ownerID := 0
+	if job.OwnerID != nil {
+		ownerID = *job.OwnerID
+	}
+
+	var batch []models.ImportRow
+	processed := 0
+	for _, record := range records {
+		if record.line <= lastLine {
+			continue
+		}
+
+		if s.ctx.Err() != nil {
+			if _, err := s.repo.AddRows(id, batch, s.workerID, importLease); err == nil {
+				s.repo.Release(id, s.workerID)
+			}
+			logger.Sugar().Infof("Import %d interrupted by shutdown", id)
+			return
+		}
+
+		row, recorded, err := s.importRecord(job, ownerID, record, batch)
+		if errors.Is(err, errImportClaimLost) {
+			logger.Sugar().Warnf("Import %d stopped: claim lost", id)
+			return
+		}
+		if err != nil {
+			s.repo.AddRows(id, batch, s.workerID, importLease)
+			s.repo.Finish(id, s.workerID, models.ImportFailed, fmt.Sprintf("Failed to add line %d: %v", record.line, err))
+			return
+		}
+		if recorded {
+			batch = nil
+		} else {
+			batch = append(batch, row)
+		}
+
+		// Accepted rows are recorded as they are added, but the counts
+		// and the lease are only updated with each batch
+		processed++
+		if processed%importBatchSize == 0 {
+			stillClaimed, err := s.repo.AddRows(id, batch, s.workerID, importLease)
+			if err != nil || !stillClaimed {
+				logger.Sugar().Warnf("Import %d stopped: claim lost", id)
+				return
+			}
+			batch = nil
+		}
+	}
+
+	stillClaimed, err := s.repo.AddRows(id, batch, s.workerID, importLease)
+	if err != nil || !stillClaimed {
+		return
+	}
+	s.repo.Finish(id, s.workerID, models.ImportCompleted, "")
+
+	logger.Sugar().Infof("Import %d completed", id)
+}
+
+// importRecord adds the URL of one row and reports the result. Invalid rows
+// are rejected with the reason; the error is only set if the URL could not
+// be stored, and is errImportClaimLost if the job was claimed by another
+// instance. An accepted row is recorded together with its URL and the
+// pending rows before it, which recorded reports; otherwise recording is
+// left to the caller.
+func (s *CrawlImportService) importRecord(job *models.ImportJob, ownerID int, record importRecord, pending []models.ImportRow) (row models.ImportRow, recorded bool, err error) {
+	row = models.ImportRow{
+		LineNumber: record.line,
+		URL:        record.url,
+		Result:     models.ImportRowRejected,
+	}
+	if utf8.RuneCountInString(row.URL) > maxImportURLLength {
+		row.URL = string([]rune(row.URL)[:maxImportURLLength])
+	}
+
+	if record.url == "" {
+		row.Reason = "URL is required"
+		return row, false, nil
+	}
+	if record.err != "" {
+		row.Reason = record.err
+		return row, false, nil
+	}
+
+	profile := record.profile
+	if profile == "" {
+		profile = job.Profile
+	}
+	opts, ok := crawlProfiles[profile]
+	if profile != "" && !ok {
+		row.Reason = fmt.Sprintf("unknown profile %q", profile)
+		return row, false, nil
+	}
+
+	// Columns set on the row override the profile
+	if record.opts.LinkScope != "" {
+		opts.LinkScope = record.opts.LinkScope
+	}
+	if len(record.opts.SameSiteDomains) > 0 {
+		opts.SameSiteDomains = record.opts.SameSiteDomains
+	}
+	if record.opts.LinkCheckMode != "" {
+		opts.LinkCheckMode = record.opts.LinkCheckMode
+	}
+	if record.opts.LinkCheckLimit != 0 {
+		opts.LinkCheckLimit = record.opts.LinkCheckLimit
+	}
+	opts.Priority = record.opts.Priority
+	opts.Tags = record.opts.Tags
+
+	normalizedURL, opts, err := prepareURL(record.url, opts, job.Priority)
+	if err != nil {
+		row.Reason = err.Error()
+		return row, false, nil
+	}
+
+	crawlURLID, duplicate, claimed, err := s.repo.AddURL(job.ID, s.workerID, pending, row, record.url,
+		normalizedURL, ownerID, job.ProjectID, opts)
+	if err != nil {
+		return row, false, err
+	}
+	if !claimed {
+		return row, false, errImportClaimLost
+	}
+
+	row.CrawlURLID = &crawlURLID
+	if duplicate {
+		row.Result = models.ImportRowDuplicate
+		row.Reason = fmt.Sprintf("matches existing URL %d", crawlURLID)
+		return row, false, nil
+	}
+
+	row.Result = models.ImportRowAccepted
+	if crawlURL, err := s.crawler.repo.GetCrawlURLByID(crawlURLID); err == nil && crawlURL != nil {
+		publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+	}
+	return row, true, nil
+}
+
+// importRecord is one row of an uploaded file.
+type importRecord struct {
+	line    int
+	url     string
+	profile string
+	opts    models.CrawlOptions
+	// err describes a column value that could not be parsed
+	err string
+}
+
+// importFormat returns the requested format, or detects it from the file
+// name: .csv files are CSV and anything else is one URL per line.
+func importFormat(format, filename string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "":
+		if strings.EqualFold(filepath.Ext(filename), ".csv") {
+			return models.ImportFormatCSV, nil
+		}
+		return models.ImportFormatText, nil
+	case models.ImportFormatCSV:
+		return models.ImportFormatCSV, nil
+	case models.ImportFormatText, "txt":
+		return models.ImportFormatText, nil
+	default:
+		return "", fmt.Errorf("%w: format must be csv or text", ErrInvalidImport)
+	}
+}
+
+func parseImportFile(format, content string) ([]importRecord, error) {
+	if format == models.ImportFormatCSV {
+		return parseImportCSV(content)
+	}
+	return parseImportText(content), nil
+}
+
+// parseImportText reads one URL per line, skipping blank lines and lines
+// starting with #.
+func parseImportText(content string) []importRecord {
+	var records []importRecord
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		records = append(records, importRecord{line: i + 1, url: line})
+	}
+	return records
+}
+
+// importColumns are the CSV columns that are understood. Only url is
+// required; other columns are ignored.
+var importColumns = []string{
+	"url", "tags", "profile", "priority",
+	"link_scope", "same_site_domains", "link_check_mode", "link_check_limit",
+}
+
+// parseImportCSV reads a CSV file whose header row names its columns.
+// List columns (tags and same_site_domains) separate values with
+// semicolons, commas or pipes.
+func parseImportCSV(content string) ([]importRecord, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if containsString(importColumns, name) {
+			if _, ok := columns[name]; !ok {
+				columns[name] = i
+			}
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a url column")
+	}
+
+	var records []importRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		// Skip blank lines, which the CSV reader returns as empty fields
+		if strings.TrimSpace(strings.Join(fields, "")) == "" {
+			continue
+		}
+
+		record := importRecord{
+			line:    line,
+			url:     value("url"),
+			profile: strings.ToLower(value("profile")),
+			opts: models.CrawlOptions{
+				LinkScope:       value("link_scope"),
+				SameSiteDomains: splitImportList(value("same_site_domains")),
+				LinkCheckMode:   value("link_check_mode"),
+				Priority:        value("priority"),
+				Tags:            splitImportList(value("tags")),
+			},
+		}
+		if limit := value("link_check_limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				record.err = fmt.Sprintf("invalid link_check_limit %q", limit)
+			}
+			record.opts.LinkCheckLimit = n
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func splitImportList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || r == '|'
+	})
+}
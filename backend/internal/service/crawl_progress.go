@@ -0,0 +1,148 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+)
+
+// crawlProgressTracker keeps the progress of the crawls running in this
+// process in memory. Phase changes are persisted at once and link-check
+// progress at most every interval, so other processes serving the API can
+// report progress too. Every persisted update is also published as a
+// progress event.
+type crawlProgressTracker struct {
+	repo     *repository.CrawlerRepository
+	interval time.Duration
+
+	mu        sync.Mutex
+	entries   map[int]*models.CrawlProgress
+	lastSaved map[int]time.Time
+	// projects maps each crawl to its project, for its progress events
+	projects map[int]int
+}
+
+func newCrawlProgressTracker(repo *repository.CrawlerRepository) *crawlProgressTracker {
+	return &crawlProgressTracker{
+		repo:      repo,
+		interval:  getEnvDuration("CRAWLER_PROGRESS_INTERVAL", 2*time.Second),
+		entries:   make(map[int]*models.CrawlProgress),
+		lastSaved: make(map[int]time.Time),
+		projects:  make(map[int]int),
+	}
+}
+
+// setPhase moves a crawl to a new phase. total is the number of links to
+// check in the checking_links phase.
+func (t *crawlProgressTracker) setPhase(crawlURL *models.CrawlURL, phase string, total int) {
+	now := time.Now()
+
+	t.mu.Lock()
+	progress := &models.CrawlProgress{
+		CrawlURLID: crawlURL.ID,
+		URL:        crawlURL.URL,
+		Phase:      phase,
+		LinksTotal: total,
+		UpdatedAt:  now,
+	}
+	t.entries[crawlURL.ID] = progress
+	t.lastSaved[crawlURL.ID] = now
+	t.projects[crawlURL.ID] = crawlURL.ProjectID
+	snapshot := *progress
+	t.mu.Unlock()
+
+	t.save(snapshot, crawlURL.ProjectID)
+}
+
+// linkChecked records that one more link has been checked.
+func (t *crawlProgressTracker) linkChecked(id int) {
+	now := time.Now()
+
+	t.mu.Lock()
+	progress, ok := t.entries[id]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	progress.LinksChecked++
+	progress.UpdatedAt = now
+
+	save := progress.LinksChecked == progress.LinksTotal || now.Sub(t.lastSaved[id]) >= t.interval
+	if save {
+		t.lastSaved[id] = now
+	}
+	snapshot := *progress
+	projectID := t.projects[id]
+	t.mu.Unlock()
+
+	if save {
+		t.save(snapshot, projectID)
+	}
+}
+
+func (t *crawlProgressTracker) save(progress models.CrawlProgress, projectID int) {
+	t.repo.UpdateCrawlProgress(&progress)
+
+	progress.Percent = progressPercent(progress)
+	DefaultCrawlEventBus().Publish(models.CrawlEvent{
+		Type:       models.EventCrawlProgress,
+		CrawlURLID: progress.CrawlURLID,
+		ProjectID:  projectID,
+		URL:        progress.URL,
+		Status:     models.StatusRunning,
+		Progress:   &progress,
+		Timestamp:  progress.UpdatedAt,
+	})
+}
+
+// finish forgets a crawl once it has stopped running.
+func (t *crawlProgressTracker) finish(id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, id)
+	delete(t.lastSaved, id)
+	delete(t.projects, id)
+}
+
+// get returns the live progress of a crawl running in this process.
+func (t *crawlProgressTracker) get(id int) (models.CrawlProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	progress, ok := t.entries[id]
+	if !ok {
+		return models.CrawlProgress{}, false
+	}
+	return *progress, true
+}
+
+// withLiveProgress returns progress updated from memory if the crawl runs in
+// this process, and with its percentage filled in.
+func (t *crawlProgressTracker) withLiveProgress(progress models.CrawlProgress) models.CrawlProgress {
+	if live, ok := t.get(progress.CrawlURLID); ok {
+		progress = live
+	}
+	progress.Percent = progressPercent(progress)
+	return progress
+}
+
+// progressPercent estimates overall completion. Fetching and parsing count
+// for the first fifth of a crawl; checking links makes up the rest.
+func progressPercent(progress models.CrawlProgress) int {
+	switch progress.Phase {
+	case models.ProgressFetching:
+		return 0
+	case models.ProgressParsing:
+		return 10
+	case models.ProgressCheckingLinks:
+		if progress.LinksTotal == 0 {
+			return 100
+		}
+		return 20 + 80*progress.LinksChecked/progress.LinksTotal
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+)
+
+// crawlFailure describes why an attempt at crawling a page failed.
+type crawlFailure struct {
+	Class      string
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+// retryPolicy controls how often and how soon a class of failure is retried.
+type retryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// retryPolicyFor returns the policy for an error class. Permanent failures
+// such as 404s or unparseable pages are never retried.
+func retryPolicyFor(class string) retryPolicy {
+	baseDelay := getEnvDuration("CRAWLER_RETRY_BASE_DELAY", 30*time.Second)
+	maxDelay := getEnvDuration("CRAWLER_RETRY_MAX_DELAY", 30*time.Minute)
+
+	switch class {
+	case models.ErrorClassNetwork:
+		return retryPolicy{getEnvInt("CRAWLER_RETRY_NETWORK_ATTEMPTS", 3), baseDelay, maxDelay}
+	case models.ErrorClassServer:
+		return retryPolicy{getEnvInt("CRAWLER_RETRY_SERVER_ERROR_ATTEMPTS", 3), baseDelay, maxDelay}
+	case models.ErrorClassRateLimited:
+		return retryPolicy{getEnvInt("CRAWLER_RETRY_RATE_LIMITED_ATTEMPTS", 5), baseDelay, maxDelay}
+	default:
+		return retryPolicy{MaxAttempts: 1}
+	}
+}
+
+// delay returns the backoff before the attempt following attempt number
+// attempt: BaseDelay doubled for every previous attempt, capped at MaxDelay.
+// A Retry-After requested by the server takes precedence if it is longer.
+func (p retryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	d := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(attempt-1)))
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// classifyHTTPFailure maps an error response to a crawl failure.
+func classifyHTTPFailure(resp *http.Response) *crawlFailure {
+	failure := &crawlFailure{
+		StatusCode: resp.StatusCode,
+		Message:    "HTTP error: " + strconv.Itoa(resp.StatusCode),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		failure.Class = models.ErrorClassRateLimited
+		failure.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	case resp.StatusCode >= 500:
+		failure.Class = models.ErrorClassServer
+		failure.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	default:
+		failure.Class = models.ErrorClassPermanent
+	}
+
+	return failure
+}
+
+// maxRetryAfter bounds the delay a server can impose through Retry-After.
+const maxRetryAfter = 24 * time.Hour
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var d time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = time.Until(t)
+	}
+
+	if d < 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		// slack allows for the time passing between formatting and parsing dates
+		slack time.Duration
+	}{
+		{"empty", "", 0, 0},
+		{"seconds", "120", 2 * time.Minute, 0},
+		{"seconds with whitespace", " 5 ", 5 * time.Second, 0},
+		{"zero", "0", 0, 0},
+		{"negative seconds", "-5", 0, 0},
+		{"capped seconds", "999999", maxRetryAfter, 0},
+		{"garbage", "soon", 0, 0},
+		{"future date", now.Add(90 * time.Second).UTC().Format(http.TimeFormat), 90 * time.Second, 2 * time.Second},
+		{"past date", now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+		{"capped date", now.Add(48 * time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRetryAfter(tt.value)
+			if got < tt.want-tt.slack || got > tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s (within %s)", tt.value, got, tt.want, tt.slack)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := retryPolicy{MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{"first attempt", 1, 0, 30 * time.Second},
+		{"doubles", 2, 0, time.Minute},
+		{"doubles again", 3, 0, 2 * time.Minute},
+		{"below the cap", 6, 0, 16 * time.Minute},
+		{"capped", 7, 0, 30 * time.Minute},
+		{"capped on overflow", 100, 0, 30 * time.Minute},
+		{"longer Retry-After wins", 1, 5 * time.Minute, 5 * time.Minute},
+		{"shorter Retry-After ignored", 3, 10 * time.Second, 2 * time.Minute},
+		{"Retry-After beyond the cap", 7, time.Hour, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.delay(tt.attempt, tt.retryAfter); got != tt.want {
+				t.Errorf("delay(%d, %s) = %s, want %s", tt.attempt, tt.retryAfter, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+)
+
+// ErrScheduleNotFound is returned for operations on an unknown schedule.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// CrawlScheduleService manages recurring crawls. Due schedules are fired by
+// CrawlerJobProcessor, which requeues their URLs for the worker pool.
+type CrawlScheduleService struct {
+	repo *repository.ScheduleRepository
+}
+
+var (
+	defaultCrawlScheduleService     *CrawlScheduleService
+	defaultCrawlScheduleServiceOnce sync.Once
+)
+
+// DefaultCrawlScheduleService returns the process-wide CrawlScheduleService.
+func DefaultCrawlScheduleService() *CrawlScheduleService {
+	defaultCrawlScheduleServiceOnce.Do(func() {
+		defaultCrawlScheduleService = NewCrawlScheduleService()
+	})
+	return defaultCrawlScheduleService
+}
+
+func NewCrawlScheduleService() *CrawlScheduleService {
+	return &CrawlScheduleService{
+		repo: repository.NewScheduleRepository(),
+	}
+}
+
+// parseSchedule parses a standard five-field cron expression (or a
+// descriptor such as "@daily") evaluated in the given IANA time zone.
+func parseSchedule(expression, timezone string) (cron.Schedule, *time.Location, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone %q", timezone)
+	}
+
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression: %v", err)
+	}
+
+	return schedule, location, nil
+}
+
+// nextRun returns the first activation of schedule after t. Times are kept
+// to whole seconds so they compare equal after a round trip through MySQL.
+func nextRun(schedule cron.Schedule, location *time.Location, t time.Time) (time.Time, error) {
+	next := schedule.Next(t.In(location))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression never fires")
+	}
+	return next.UTC().Truncate(time.Second), nil
+}
+
+// CreateSchedule validates req and stores a new active schedule of
+// projectID. Its URLs must belong to the same project.
+func (s *CrawlScheduleService) CreateSchedule(projectID int, req models.CreateScheduleRequest) (*models.CrawlSchedule, error) {
+	expression := strings.TrimSpace(req.CronExpression)
+	if expression == "" {
+		return nil, fmt.Errorf("cron_expression is required")
+	}
+
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	schedule, location, err := parseSchedule(expression, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	urlIDs := uniqueIDs(req.URLIDs)
+	if len(urlIDs) == 0 {
+		return nil, fmt.Errorf("url_ids must contain at least one URL ID")
+	}
+
+	count, err := s.repo.CountExistingURLs(projectID, urlIDs)
+	if err != nil {
+		return nil, err
+	}
+	if count != len(urlIDs) {
+		return nil, fmt.Errorf("url_ids contains unknown URL IDs")
+	}
+
+	next, err := nextRun(schedule, location, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.Create(&models.CrawlSchedule{
+		ProjectID:      projectID,
+		Name:           strings.TrimSpace(req.Name),
+		CronExpression: expression,
+		Timezone:       timezone,
+		Status:         models.ScheduleActive,
+		URLIDs:         urlIDs,
+		NextRunAt:      &next,
+	})
+}
+
+// GetSchedules returns the schedules in scope.
+func (s *CrawlScheduleService) GetSchedules(scope models.ProjectScope) ([]models.CrawlSchedule, error) {
+	schedules, err := s.repo.GetAll(scope)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []models.CrawlSchedule{}
+	}
+	return schedules, nil
+}
+
+// PauseSchedule stops a schedule from firing until it is resumed.
+func (s *CrawlScheduleService) PauseSchedule(scope models.ProjectScope, id int) (*models.CrawlSchedule, error) {
+	schedule, err := s.getSchedule(scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetStatus(id, models.SchedulePaused, nil); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(schedule.ID)
+}
+
+// ResumeSchedule reactivates a paused schedule. Runs missed while it was
+// paused are skipped; the next run is computed from the current time.
+func (s *CrawlScheduleService) ResumeSchedule(scope models.ProjectScope, id int) (*models.CrawlSchedule, error) {
+	schedule, err := s.getSchedule(scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cronSchedule, location, err := parseSchedule(schedule.CronExpression, schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := nextRun(cronSchedule, location, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetStatus(id, models.ScheduleActive, &next); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(schedule.ID)
+}
+
+func (s *CrawlScheduleService) DeleteSchedule(scope models.ProjectScope, id int) error {
+	if _, err := s.getSchedule(scope, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+func (s *CrawlScheduleService) getSchedule(scope models.ProjectScope, id int) (*models.CrawlSchedule, error) {
+	schedule, err := s.repo.Get(scope, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// RunDueSchedules fires every active schedule whose next run time has
+// passed. Each run is claimed in the same transaction that queues its URLs,
+// so with several backend instances a run fires on only one of them, and a
+// run is never recorded without its URLs being queued. A
+// schedule that was due several times while the backend was down fires
+// once.
+func (s *CrawlScheduleService) RunDueSchedules() {
+	now := time.Now()
+
+	schedules, err := s.repo.GetDue(now)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		cronSchedule, location, err := parseSchedule(schedule.CronExpression, schedule.Timezone)
+		if err != nil {
+			logger.Sugar().Errorf("Pausing schedule %d: %v", schedule.ID, err)
+			s.repo.SetStatus(schedule.ID, models.SchedulePaused, nil)
+			continue
+		}
+
+		next, err := nextRun(cronSchedule, location, now)
+		if err != nil {
+			logger.Sugar().Errorf("Pausing schedule %d: %v", schedule.ID, err)
+			s.repo.SetStatus(schedule.ID, models.SchedulePaused, nil)
+			continue
+		}
+
+		claimed, queued, err := s.repo.FireSchedule(schedule.ID, *schedule.NextRunAt, now, next)
+		if err != nil || !claimed {
+			continue
+		}
+		for i := range queued {
+			publishCrawlEvent(models.EventCrawlQueued, &queued[i])
+		}
+
+		logger.Sugar().Infof("Schedule %d fired: %d URLs queued, next run at %s", schedule.ID, len(queued), next.Format(time.RFC3339))
+	}
+}
+
+// uniqueIDs returns ids without duplicates or non-positive values, keeping
+// their order.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var unique []int
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
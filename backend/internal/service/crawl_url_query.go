@@ -0,0 +1,225 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+)
+
+// ErrInvalidListQuery is returned for URL list filters, sorts or cursors
+// that cannot be applied.
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+var crawlURLStatuses = []string{
+	models.StatusQueued, models.StatusRunning, models.StatusCompleted,
+	models.StatusError, models.StatusFailed, models.StatusCancelled,
+}
+
+// CrawlURLListOptions selects a page of the URL list. Pages continue after
+// Cursor, the next_cursor of the previous page, if it is set, and are
+// numbered by Page otherwise.
+type CrawlURLListOptions struct {
+	Filter models.CrawlURLFilter
+	Sort   []models.SortField
+	Page   int
+	Limit  int
+	Cursor string
+}
+
+// ParseCrawlURLFilter reads the URL list filters from query parameters:
+//
+//   - status: one status or a comma-separated list of them
+//   - search: part of the URL or title
+//   - html_version, has_login_form and tag: exact matches
+//   - <metric>, <metric>_min and <metric>_max: an exact value or an
+//     inclusive range of a metric column such as h1_count
+//   - crawled_from, crawled_to, created_from and created_to: RFC 3339 times
+//     or dates, where a date as the upper bound includes the whole day
+func ParseCrawlURLFilter(query func(key string) string) (models.CrawlURLFilter, error) {
+	filter := models.CrawlURLFilter{
+		Search:      query("search"),
+		HTMLVersion: strings.TrimSpace(query("html_version")),
+		Tag:         strings.TrimSpace(query("tag")),
+	}
+
+	for _, status := range strings.Split(query("status"), ",") {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if status == "" {
+			continue
+		}
+		if !containsString(crawlURLStatuses, status) {
+			return filter, fmt.Errorf("%w: status must be a list of %s", ErrInvalidListQuery, strings.Join(crawlURLStatuses, ", "))
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	if value := query("has_login_form"); value != "" {
+		hasLoginForm, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("%w: has_login_form must be true or false", ErrInvalidListQuery)
+		}
+		filter.HasLoginForm = &hasLoginForm
+	}
+
+	for _, column := range models.CrawlURLMetricColumns {
+		metric := models.MetricRange{Column: column}
+		for _, bound := range []struct {
+			param  string
+			target []**int
+		}{
+			{column, []**int{&metric.Min, &metric.Max}},
+			{column + "_min", []**int{&metric.Min}},
+			{column + "_max", []**int{&metric.Max}},
+		} {
+			value := query(bound.param)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("%w: %s must be an integer", ErrInvalidListQuery, bound.param)
+			}
+			for _, target := range bound.target {
+				*target = &n
+			}
+		}
+
+		if metric.Min == nil && metric.Max == nil {
+			continue
+		}
+		if metric.Min != nil && metric.Max != nil && *metric.Min > *metric.Max {
+			return filter, fmt.Errorf("%w: %s_min is greater than %s_max", ErrInvalidListQuery, column, column)
+		}
+		filter.Ranges = append(filter.Ranges, metric)
+	}
+
+	for _, bound := range []struct {
+		param  string
+		upper  bool
+		target **time.Time
+	}{
+		{"crawled_from", false, &filter.CrawledFrom},
+		{"crawled_to", true, &filter.CrawledTo},
+		{"created_from", false, &filter.CreatedFrom},
+		{"created_to", true, &filter.CreatedTo},
+	} {
+		value := strings.TrimSpace(query(bound.param))
+		if value == "" {
+			continue
+		}
+		t, err := parseListTime(value, bound.upper)
+		if err != nil {
+			return filter, fmt.Errorf("%w: %s must be an RFC 3339 time or a date (YYYY-MM-DD)", ErrInvalidListQuery, bound.param)
+		}
+		*bound.target = &t
+	}
+
+	return filter, nil
+}
+
+// parseListTime parses an RFC 3339 time or a date. A date is the start of
+// that day in local time, or the start of the next day if it is an upper
+// bound, so that the bound covers the whole day.
+func parseListTime(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// ParseCrawlURLSort reads a comma-separated list of columns to sort the URL
+// list on, each prefixed with - to sort descending, such as
+// "-h1_count,title". The list defaults to the newest URLs first, and id is
+// appended as a tie-breaker so that every URL has a unique sort key.
+func ParseCrawlURLSort(value string) ([]models.SortField, error) {
+	var sort []models.SortField
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := models.SortField{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !containsString(models.CrawlURLSortColumns, field.Column) && !containsString(models.CrawlURLMetricColumns, field.Column) {
+			return nil, fmt.Errorf("%w: cannot sort on %q", ErrInvalidListQuery, field.Column)
+		}
+		if seen[field.Column] {
+			return nil, fmt.Errorf("%w: %q is sorted on twice", ErrInvalidListQuery, field.Column)
+		}
+		seen[field.Column] = true
+		sort = append(sort, field)
+	}
+
+	if len(sort) == 0 {
+		sort = []models.SortField{{Column: "created_at", Desc: true}}
+	}
+	if !seen["id"] {
+		sort = append(sort, models.SortField{Column: "id", Desc: sort[len(sort)-1].Desc})
+	}
+
+	return sort, nil
+}
+
+// crawlURLCursor is the decoded form of a next_cursor. It records the sort
+// it was made for, since its key means nothing under another sort.
+type crawlURLCursor struct {
+	Sort string   `json:"s"`
+	Key  []string `json:"k"`
+}
+
+func formatSort(sort []models.SortField) string {
+	fields := make([]string, len(sort))
+	for i, field := range sort {
+		fields[i] = field.Column
+		if field.Desc {
+			fields[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func encodeCrawlURLCursor(crawlURL *models.CrawlURL, sort []models.SortField) string {
+	data, _ := json.Marshal(crawlURLCursor{
+		Sort: formatSort(sort),
+		Key:  repository.CrawlURLSortKey(crawlURL, sort),
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCrawlURLCursor(value string, sort []models.SortField) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListQuery)
+	}
+
+	var cursor crawlURLCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListQuery)
+	}
+	if cursor.Sort != formatSort(sort) {
+		return nil, fmt.Errorf("%w: cursor was made for a different sort", ErrInvalidListQuery)
+	}
+
+	after, err := repository.ParseCrawlURLSortKey(sort, cursor.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidListQuery, err)
+	}
+	return after, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"sykell-backend/internal/models"
+)
+
+// equalSortKeys compares decoded sort keys, comparing times by instant.
+func equalSortKeys(got, want []interface{}) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if wantTime, ok := want[i].(time.Time); ok {
+			gotTime, ok := got[i].(time.Time)
+			if !ok || !gotTime.Equal(wantTime) {
+				return false
+			}
+		} else if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCrawlURLCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	crawledAt := time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC)
+	crawlURL := &models.CrawlURL{
+		ID:            42,
+		URL:           "https://example.com/",
+		Title:         "Example, with \"quotes\"",
+		Status:        models.StatusCompleted,
+		H1Count:       3,
+		HasLoginForm:  true,
+		LastCrawledAt: &crawledAt,
+		CreatedAt:     createdAt,
+	}
+
+	tests := []struct {
+		sort string
+		want []interface{}
+	}{
+		{"", []interface{}{createdAt, 42}},
+		{"-h1_count,title", []interface{}{3, "Example, with \"quotes\"", 42}},
+		{"status,-id", []interface{}{models.StatusCompleted, 42}},
+		{"has_login_form,last_crawled_at", []interface{}{1, crawledAt, 42}},
+		{"url", []interface{}{"https://example.com/", 42}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			sort, err := ParseCrawlURLSort(tt.sort)
+			if err != nil {
+				t.Fatalf("ParseCrawlURLSort(%q) error = %v", tt.sort, err)
+			}
+
+			got, err := decodeCrawlURLCursor(encodeCrawlURLCursor(crawlURL, sort), sort)
+			if err != nil {
+				t.Fatalf("decodeCrawlURLCursor() error = %v", err)
+			}
+			if !equalSortKeys(got, tt.want) {
+				t.Errorf("decodeCrawlURLCursor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCrawlURLCursorErrors(t *testing.T) {
+	cursor := func(sort string, key ...string) string {
+		data, _ := json.Marshal(crawlURLCursor{Sort: sort, Key: key})
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"not base64", "", "not a cursor!"},
+		{"not JSON", "", base64.RawURLEncoding.EncodeToString([]byte("not json"))},
+		{"other sort", "title", cursor("-h1_count,-id", "3", "42")},
+		{"other direction", "-title", cursor("title,id", "a", "42")},
+		{"too few values", "title", cursor("title,id", "a")},
+		{"too many values", "title", cursor("title,id", "a", "42", "43")},
+		{"invalid number", "h1_count", cursor("h1_count,id", "three", "42")},
+		{"invalid time", "created_at", cursor("created_at,id", "yesterday", "42")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sort, err := ParseCrawlURLSort(tt.sort)
+			if err != nil {
+				t.Fatalf("ParseCrawlURLSort(%q) error = %v", tt.sort, err)
+			}
+
+			if _, err := decodeCrawlURLCursor(tt.cursor, sort); !errors.Is(err, ErrInvalidListQuery) {
+				t.Errorf("decodeCrawlURLCursor() error = %v, want ErrInvalidListQuery", err)
+			}
+		})
+	}
+}
+
+func TestParseCrawlURLSort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "-created_at,-id", false},
+		{"title", "title,id", false},
+		{"-h1_count, title", "-h1_count,title,id", false},
+		{"-title", "-title,-id", false},
+		{"id", "id", false},
+		{"-id,title", "-id,title", false},
+		{"password", "", true},
+		{"title,-title", "", true},
+	}
+
+	for _, tt := range tests {
+		sort, err := ParseCrawlURLSort(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCrawlURLSort(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidListQuery) {
+				t.Errorf("ParseCrawlURLSort(%q) error = %v, want ErrInvalidListQuery", tt.value, err)
+			}
+			continue
+		}
+		if got := formatSort(sort); got != tt.want {
+			t.Errorf("ParseCrawlURLSort(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
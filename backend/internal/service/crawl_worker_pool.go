@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+)
+
+// CrawlWorkerPool runs crawls on a fixed number of workers fed from a
+// bounded in-memory queue. Every crawl in the process goes through it, so
+// the number of concurrent crawls never exceeds the pool size. URLs that do
+// not fit in the queue stay queued in the database and are submitted again
+// by CrawlerJobProcessor once there is room.
+//
+// Several backend processes may share one database. A worker only crawls a
+// URL after atomically claiming it under the pool's worker ID, and keeps the
+// claim alive with heartbeats that extend its lease. A heartbeat that finds
+// the URL no longer running, because it was cancelled through any process,
+// cancels the crawl's context.
+type CrawlWorkerPool struct {
+	crawl    func(ctx context.Context, crawlURL *models.CrawlURL)
+	repo     crawlClaimStore
+	jobs     chan int
+	size     int
+	workerID string
+	stableID bool // workerID was configured and survives restarts
+	lease    time.Duration
+
+	mu      sync.Mutex
+	pending map[int]bool                    // IDs waiting in the queue or being crawled
+	running map[int]context.CancelCauseFunc // cancels the crawls in progress
+	active  int
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// crawlClaimStore is the part of CrawlerRepository that claims URLs for
+// the pool and keeps the claims alive.
+type crawlClaimStore interface {
+	StartClaimedCrawl(id int, workerID string, lease time.Duration) (bool, error)
+	GetCrawlURLByID(id int) (*models.CrawlURL, error)
+	RenewLease(id int, workerID string, lease time.Duration) (bool, error)
+	ReleaseClaim(id int, workerID string) error
+	RequeueCrawl(id int, workerID string) error
+}
+
+// Reasons a running crawl's context is cancelled
+var (
+	errCrawlCancelled = errors.New("crawl cancelled")
+	errLeaseLost      = errors.New("crawl no longer claimed by this worker")
+	errPoolShutdown   = errors.New("worker pool shutting down")
+)
+
+// Heartbeats run every third of the lease, so a lease needs to leave them
+// time to reach the database.
+const (
+	defaultCrawlLease = 2 * time.Minute
+	minCrawlLease     = 3 * time.Second
+)
+
+var (
+	defaultCrawlWorkerPool     *CrawlWorkerPool
+	defaultCrawlWorkerPoolOnce sync.Once
+)
+
+// DefaultCrawlWorkerPool returns the process-wide worker pool, starting it
+// on first use.
+func DefaultCrawlWorkerPool() *CrawlWorkerPool {
+	defaultCrawlWorkerPoolOnce.Do(func() {
+		size := getEnvInt("CRAWLER_WORKERS", 5)
+		if size < 1 {
+			size = 1
+		}
+		queueSize := getEnvInt("CRAWLER_QUEUE_SIZE", 100)
+		if queueSize < 1 {
+			queueSize = 1
+		}
+
+		lease := getEnvDuration("CRAWLER_LEASE_DURATION", defaultCrawlLease)
+		if lease < minCrawlLease {
+			logger.Sugar().Warnf("CRAWLER_LEASE_DURATION %s is shorter than %s, using default %s", lease, minCrawlLease, defaultCrawlLease)
+			lease = defaultCrawlLease
+		}
+
+		workerID := getEnv("CRAWLER_WORKER_ID", "")
+		stableID := workerID != ""
+		if !stableID {
+			workerID = defaultWorkerID()
+		}
+
+		defaultCrawlWorkerPool = &CrawlWorkerPool{
+			crawl:    DefaultCrawlerService().performCrawl,
+			repo:     repository.NewCrawlerRepository(),
+			jobs:     make(chan int, queueSize),
+			size:     size,
+			workerID: workerID,
+			stableID: stableID,
+			lease:    lease,
+			pending:  make(map[int]bool),
+			running:  make(map[int]context.CancelCauseFunc),
+		}
+		defaultCrawlWorkerPool.start()
+	})
+	return defaultCrawlWorkerPool
+}
+
+// defaultWorkerID identifies this process in claimed_by columns. It is
+// unique to the process, so a restarted process cannot tell its previous
+// run's claims from another instance's.
+func defaultWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// WorkerID returns the ID this pool claims URLs under.
+func (p *CrawlWorkerPool) WorkerID() string {
+	return p.workerID
+}
+
+// PreviousWorkerID returns the ID a previous run of this process claimed
+// URLs under, or an empty string if that is unknown because no stable
+// CRAWLER_WORKER_ID is configured.
+func (p *CrawlWorkerPool) PreviousWorkerID() string {
+	if !p.stableID {
+		return ""
+	}
+	return p.workerID
+}
+
+// Lease returns how long a claim is held without a heartbeat.
+func (p *CrawlWorkerPool) Lease() time.Duration {
+	return p.lease
+}
+
+func (p *CrawlWorkerPool) start() {
+	logger.Sugar().Infof("Starting crawl worker pool %s with %d workers and queue size %d", p.workerID, p.size, cap(p.jobs))
+	p.workers.Add(p.size)
+	for i := 0; i < p.size; i++ {
+		go p.worker()
+	}
+}
+
+// Submit queues a crawl of the URL with the given ID. It returns false
+// without blocking if the URL is already queued or running in this process,
+// the queue is full or the pool is shutting down.
+func (p *CrawlWorkerPool) Submit(id int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || p.pending[id] {
+		return false
+	}
+
+	select {
+	case p.jobs <- id:
+		p.pending[id] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel stops the crawl of the URL with the given ID if this process is
+// running it, and reports whether it was.
+func (p *CrawlWorkerPool) Cancel(id int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cancel, ok := p.running[id]
+	if ok {
+		cancel(errCrawlCancelled)
+	}
+	return ok
+}
+
+// Shutdown stops the pool before the process exits. It stops accepting
+// work and releases the URLs still waiting in its queue, then waits up to
+// timeout for running crawls to finish. Crawls still running after that are
+// cancelled and put back in the queue, so they are crawled again from
+// scratch rather than left with partial results.
+func (p *CrawlWorkerPool) Shutdown(timeout time.Duration) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	// Submit checks closed under the same lock, so nothing is sent after this
+	close(p.jobs)
+	running := p.active
+	p.mu.Unlock()
+
+	logger.Sugar().Infof("Shutting down crawl worker pool, waiting up to %s for %d running crawls", timeout, running)
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Sugar().Info("Crawl worker pool stopped")
+		return
+	case <-time.After(timeout):
+	}
+
+	p.mu.Lock()
+	logger.Sugar().Warnf("Shutdown deadline reached, requeueing %d running crawls", len(p.running))
+	for _, cancel := range p.running {
+		cancel(errPoolShutdown)
+	}
+	p.mu.Unlock()
+
+	// Cancelled crawls return as soon as their current request is aborted
+	select {
+	case <-done:
+		logger.Sugar().Info("Crawl worker pool stopped")
+	case <-time.After(10 * time.Second):
+		logger.Sugar().Warn("Crawl worker pool did not stop; remaining crawls will be recovered once their leases expire")
+	}
+}
+
+// FreeCapacity returns how many more crawls the queue can accept.
+func (p *CrawlWorkerPool) FreeCapacity() int {
+	return cap(p.jobs) - len(p.jobs)
+}
+
+func (p *CrawlWorkerPool) Stats() models.WorkerPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return models.WorkerPoolStats{
+		WorkerID:      p.workerID,
+		Workers:       p.size,
+		ActiveWorkers: p.active,
+		QueueDepth:    len(p.jobs),
+		QueueCapacity: cap(p.jobs),
+	}
+}
+
+func (p *CrawlWorkerPool) worker() {
+	defer p.workers.Done()
+
+	for id := range p.jobs {
+		p.mu.Lock()
+		closed := p.closed
+		if !closed {
+			p.active++
+		}
+		p.mu.Unlock()
+
+		// Hand URLs that never started back to other processes
+		if closed {
+			p.repo.ReleaseClaim(id, p.workerID)
+			continue
+		}
+
+		p.run(id)
+
+		p.mu.Lock()
+		p.active--
+		delete(p.pending, id)
+		p.mu.Unlock()
+	}
+}
+
+func (p *CrawlWorkerPool) run(id int) {
+	// Another process may have claimed the URL, or it may have been
+	// deleted or already crawled while it waited in the queue
+	claimed, err := p.repo.StartClaimedCrawl(id, p.workerID, p.lease)
+	if err != nil || !claimed {
+		return
+	}
+	defer p.repo.ReleaseClaim(id, p.workerID)
+
+	crawlURL, err := p.repo.GetCrawlURLByID(id)
+	if err != nil || crawlURL == nil {
+		logger.Sugar().Errorf("Failed to load claimed crawl URL %d: %v", id, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	p.mu.Lock()
+	p.running[id] = cancel
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, id)
+		p.mu.Unlock()
+		cancel(nil)
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.heartbeat(id, stop, cancel)
+
+	p.crawl(ctx, crawlURL)
+
+	if errors.Is(context.Cause(ctx), errPoolShutdown) {
+		if p.repo.RequeueCrawl(id, p.workerID) == nil {
+			crawlURL.Status = models.StatusQueued
+			publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+		}
+	}
+}
+
+// maxHeartbeatFailures is how many heartbeats in a row may fail before a
+// crawl gives up its claim. Heartbeats run every third of the lease, so
+// after two failures the lease expires before the next one and another
+// process may already be claiming the URL.
+const maxHeartbeatFailures = 2
+
+// heartbeat extends the lease on a running crawl until stop is closed. If
+// the crawl is found to be no longer running, or its lease cannot be
+// renewed before it expires, it is cancelled.
+func (p *CrawlWorkerPool) heartbeat(id int, stop <-chan struct{}, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(p.lease / 3)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			renewed, err := p.repo.RenewLease(id, p.workerID, p.lease)
+			if err != nil {
+				failures++
+				if failures >= maxHeartbeatFailures {
+					logger.Sugar().Warnf("Could not renew the lease on URL %d %d times, cancelling: %v", id, failures, err)
+					cancel(errLeaseLost)
+					return
+				}
+				continue
+			}
+			if !renewed {
+				logger.Sugar().Infof("Crawl of URL %d is no longer running, cancelling", id)
+				cancel(errLeaseLost)
+				return
+			}
+			failures = 0
+		}
+	}
+}
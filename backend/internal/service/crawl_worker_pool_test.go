@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init()
+	os.Exit(m.Run())
+}
+
+// renewal is the scripted outcome of one RenewLease call.
+type renewal struct {
+	renewed bool
+	err     error
+}
+
+// fakeClaimStore stands in for the crawler repository. Renewals past the
+// end of its script succeed.
+type fakeClaimStore struct {
+	claimed  bool
+	claimErr error
+	crawlURL *models.CrawlURL
+	renewals []renewal
+
+	mu         sync.Mutex
+	renewCalls int
+	released   []int
+	requeued   []int
+}
+
+func (s *fakeClaimStore) StartClaimedCrawl(id int, workerID string, lease time.Duration) (bool, error) {
+	return s.claimed, s.claimErr
+}
+
+func (s *fakeClaimStore) GetCrawlURLByID(id int) (*models.CrawlURL, error) {
+	return s.crawlURL, nil
+}
+
+func (s *fakeClaimStore) RenewLease(id int, workerID string, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.renewCalls++
+	if s.renewCalls > len(s.renewals) {
+		return true, nil
+	}
+	r := s.renewals[s.renewCalls-1]
+	return r.renewed, r.err
+}
+
+func (s *fakeClaimStore) ReleaseClaim(id int, workerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released = append(s.released, id)
+	return nil
+}
+
+func (s *fakeClaimStore) RequeueCrawl(id int, workerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requeued = append(s.requeued, id)
+	return nil
+}
+
+func (s *fakeClaimStore) calls() (renewCalls int, released, requeued []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.renewCalls, append([]int(nil), s.released...), append([]int(nil), s.requeued...)
+}
+
+func newTestWorkerPool(store *fakeClaimStore, lease time.Duration, crawl func(context.Context, *models.CrawlURL)) *CrawlWorkerPool {
+	return &CrawlWorkerPool{
+		crawl:    crawl,
+		repo:     store,
+		jobs:     make(chan int, 2),
+		size:     1,
+		workerID: "test-worker",
+		lease:    lease,
+		pending:  make(map[int]bool),
+		running:  make(map[int]context.CancelCauseFunc),
+	}
+}
+
+// blockingCrawl returns a crawl that signals started and then runs until
+// its context is cancelled, reporting the cause on causes.
+func blockingCrawl() (crawl func(context.Context, *models.CrawlURL), started chan int, causes chan error) {
+	started = make(chan int, 1)
+	causes = make(chan error, 1)
+	crawl = func(ctx context.Context, crawlURL *models.CrawlURL) {
+		started <- crawlURL.ID
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+	}
+	return crawl, started, causes
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCrawlWorkerPoolRunClaim(t *testing.T) {
+	tests := []struct {
+		name         string
+		store        *fakeClaimStore
+		wantCrawled  bool
+		wantReleased bool
+	}{
+		{"claimed", &fakeClaimStore{claimed: true, crawlURL: &models.CrawlURL{ID: 1}}, true, true},
+		{"claimed by another process", &fakeClaimStore{claimed: false}, false, false},
+		{"claim failed", &fakeClaimStore{claimErr: errors.New("connection refused")}, false, false},
+		{"deleted after claiming", &fakeClaimStore{claimed: true}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawled := false
+			pool := newTestWorkerPool(tt.store, time.Hour, func(ctx context.Context, crawlURL *models.CrawlURL) {
+				crawled = true
+			})
+
+			pool.run(1)
+
+			if crawled != tt.wantCrawled {
+				t.Errorf("crawled = %v, want %v", crawled, tt.wantCrawled)
+			}
+			if _, released, _ := tt.store.calls(); (len(released) == 1) != tt.wantReleased {
+				t.Errorf("released = %v, want released %v", released, tt.wantReleased)
+			}
+			if len(pool.running) != 0 {
+				t.Errorf("running = %v after the crawl, want none", pool.running)
+			}
+		})
+	}
+}
+
+func TestCrawlWorkerPoolHeartbeat(t *testing.T) {
+	errDB := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		renewals   []renewal
+		wantCancel bool
+	}{
+		{"renewed", []renewal{{renewed: true}, {renewed: true}, {renewed: true}}, false},
+		{"no longer running", []renewal{{renewed: true}, {renewed: false}}, true},
+		{"single failures recover", []renewal{{err: errDB}, {renewed: true}, {err: errDB}, {renewed: true}}, false},
+		{"failures in a row", []renewal{{renewed: true}, {err: errDB}, {err: errDB}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeClaimStore{renewals: tt.renewals}
+			pool := newTestWorkerPool(store, 15*time.Millisecond, nil)
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				pool.heartbeat(1, stop, cancel)
+				close(done)
+			}()
+
+			if tt.wantCancel {
+				select {
+				case <-done:
+				case <-time.After(2 * time.Second):
+					t.Fatal("heartbeat did not give up the claim")
+				}
+				if cause := context.Cause(ctx); !errors.Is(cause, errLeaseLost) {
+					t.Errorf("cause = %v, want errLeaseLost", cause)
+				}
+				if calls, _, _ := store.calls(); calls != len(tt.renewals) {
+					t.Errorf("renewed %d times, want %d", calls, len(tt.renewals))
+				}
+				return
+			}
+
+			waitFor(t, "renewals", func() bool {
+				calls, _, _ := store.calls()
+				return calls > len(tt.renewals)
+			})
+			close(stop)
+			<-done
+			if ctx.Err() != nil {
+				t.Errorf("crawl cancelled with %v, want it to keep running", context.Cause(ctx))
+			}
+		})
+	}
+}
+
+func TestCrawlWorkerPoolCancel(t *testing.T) {
+	store := &fakeClaimStore{claimed: true, crawlURL: &models.CrawlURL{ID: 7}}
+	crawl, started, causes := blockingCrawl()
+	pool := newTestWorkerPool(store, time.Hour, crawl)
+
+	if pool.Cancel(7) {
+		t.Fatal("Cancel() = true before the crawl started")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pool.run(7)
+		close(done)
+	}()
+	<-started
+
+	if !pool.Cancel(7) {
+		t.Fatal("Cancel() = false for a running crawl")
+	}
+	if cause := <-causes; !errors.Is(cause, errCrawlCancelled) {
+		t.Errorf("cause = %v, want errCrawlCancelled", cause)
+	}
+	<-done
+
+	if pool.Cancel(7) {
+		t.Error("Cancel() = true after the crawl ended")
+	}
+	_, released, requeued := store.calls()
+	if len(released) != 1 || len(requeued) != 0 {
+		t.Errorf("released %v and requeued %v, want the claim released only", released, requeued)
+	}
+}
+
+func TestCrawlWorkerPoolShutdownRequeues(t *testing.T) {
+	store := &fakeClaimStore{claimed: true, crawlURL: &models.CrawlURL{ID: 7}}
+	crawl, started, causes := blockingCrawl()
+	pool := newTestWorkerPool(store, time.Hour, crawl)
+	pool.start()
+
+	if !pool.Submit(7) {
+		t.Fatal("Submit() = false with room in the queue")
+	}
+	if pool.Submit(7) {
+		t.Error("Submit() = true for a URL already submitted")
+	}
+	<-started
+
+	pool.Shutdown(10 * time.Millisecond)
+
+	if cause := <-causes; !errors.Is(cause, errPoolShutdown) {
+		t.Errorf("cause = %v, want errPoolShutdown", cause)
+	}
+	if _, _, requeued := store.calls(); len(requeued) != 1 || requeued[0] != 7 {
+		t.Errorf("requeued = %v, want [7]", requeued)
+	}
+	if pool.Submit(8) {
+		t.Error("Submit() = true after shutdown")
+	}
+}
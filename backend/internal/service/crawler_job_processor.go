@@ -10,19 +10,51 @@ import (
 )
 
 type CrawlerJobProcessor struct {
-	crawlerService *CrawlerService
-	repo           *repository.CrawlerRepository
-	stopChan       chan bool
-	wg             sync.WaitGroup
-	isRunning      bool
-	mu             sync.RWMutex
+	pool      *CrawlWorkerPool
+	repo      *repository.CrawlerRepository
+	schedules *CrawlScheduleService
+	webhooks  *WebhookService
+	imports   *CrawlImportService
+	stopChan  chan bool
+	wg        sync.WaitGroup
+	isRunning bool
+	mu        sync.RWMutex
+
+	// Stale crawl recovery
+	recoveryInterval time.Duration
+	staleAfter       time.Duration
+	maxAttempts      int
+
+	// How long Stop waits for running crawls before requeueing them
+	shutdownTimeout time.Duration
+
+	// How often the webhook outbox is checked for due deliveries
+	webhookInterval time.Duration
 }
 
 func NewCrawlerJobProcessor() *CrawlerJobProcessor {
+	recoveryInterval := getEnvDuration("CRAWLER_RECOVERY_INTERVAL", time.Minute)
+	if recoveryInterval <= 0 {
+		recoveryInterval = time.Minute
+	}
+
+	webhookInterval := getEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second)
+	if webhookInterval <= 0 {
+		webhookInterval = 5 * time.Second
+	}
+
 	return &CrawlerJobProcessor{
-		crawlerService: NewCrawlerService(),
-		repo:           repository.NewCrawlerRepository(),
-		stopChan:       make(chan bool),
+		pool:             DefaultCrawlWorkerPool(),
+		repo:             repository.NewCrawlerRepository(),
+		schedules:        DefaultCrawlScheduleService(),
+		webhooks:         DefaultWebhookService(),
+		imports:          DefaultCrawlImportService(),
+		stopChan:         make(chan bool),
+		recoveryInterval: recoveryInterval,
+		staleAfter:       getEnvDuration("CRAWLER_STALE_AFTER", 15*time.Minute),
+		maxAttempts:      getEnvInt("CRAWLER_MAX_ATTEMPTS", 3),
+		shutdownTimeout:  getEnvDuration("CRAWLER_SHUTDOWN_TIMEOUT", 30*time.Second),
+		webhookInterval:  webhookInterval,
 	}
 }
 
@@ -37,10 +69,21 @@ func (p *CrawlerJobProcessor) Start() {
 
 	logger.Sugar().Info("Starting crawler job processor")
 
-	p.wg.Add(1)
+	// Crawls whose leases expired while no process was watching are
+	// recovered straight away, and with a stable worker ID so are those a
+	// previous run of this process left running; for other workers, wait
+	// for their leases to expire
+	p.recoverStaleCrawls(p.pool.PreviousWorkerID())
+
+	p.wg.Add(2)
 	go p.processJobs()
+	go p.processWebhooks()
 }
 
+// Stop stops claiming queued URLs, hands unfinished imports back to the
+// queue and shuts down the worker pool, waiting up to
+// CRAWLER_SHUTDOWN_TIMEOUT for running crawls. Crawls still running after
+// that are requeued.
 func (p *CrawlerJobProcessor) Stop() {
 	p.mu.Lock()
 	if !p.isRunning {
@@ -53,6 +96,9 @@ func (p *CrawlerJobProcessor) Stop() {
 	logger.Sugar().Info("Stopping crawler job processor")
 	close(p.stopChan)
 	p.wg.Wait()
+
+	p.imports.Shutdown()
+	p.pool.Shutdown(p.shutdownTimeout)
 }
 
 func (p *CrawlerJobProcessor) processJobs() {
@@ -61,46 +107,94 @@ func (p *CrawlerJobProcessor) processJobs() {
 	ticker := time.NewTicker(10 * time.Second) // Check for queued jobs every 10 seconds
 	defer ticker.Stop()
 
+	recoveryTicker := time.NewTicker(p.recoveryInterval)
+	defer recoveryTicker.Stop()
+
 	for {
 		select {
 		case <-p.stopChan:
 			logger.Sugar().Info("Crawler job processor stopped")
 			return
 		case <-ticker.C:
+			p.schedules.RunDueSchedules()
+			p.imports.ResumeImports()
 			p.processQueuedJobs()
+		case <-recoveryTicker.C:
+			p.recoverStaleCrawls("")
 		}
 	}
 }
 
-func (p *CrawlerJobProcessor) processQueuedJobs() {
-	// Get queued crawl URLs
-	queuedURLs, _, err := p.repo.GetCrawlURLs(10, 0, models.StatusQueued, "")
+// processWebhooks drains the webhook outbox. It runs apart from
+// processJobs so that slow subscribers never hold up claiming crawls; on
+// stop it finishes the batch it is sending.
+func (p *CrawlerJobProcessor) processWebhooks() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.webhookInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+			p.webhooks.DeliverDue()
+		}
+	}
+}
+
+// recoverStaleCrawls requeues running crawls whose worker has died, or
+// marks them as failed once they have used up their attempts, and
+// announces their new status.
+func (p *CrawlerJobProcessor) recoverStaleCrawls(deadWorkerID string) {
+	requeued, failed, err := p.repo.RecoverStaleCrawls(p.staleAfter, p.maxAttempts, deadWorkerID)
 	if err != nil {
-		logger.Sugar().Errorf("Failed to get queued URLs: %v", err)
+		logger.Sugar().Errorf("Failed to recover stale crawls: %v", err)
 		return
 	}
 
-	if len(queuedURLs) == 0 {
-		return
+	for _, id := range requeued {
+		if crawlURL, err := p.repo.GetCrawlURLByID(id); err == nil && crawlURL != nil {
+			publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+		}
+	}
+	for _, id := range failed {
+		if crawlURL, err := p.repo.GetCrawlURLByID(id); err == nil && crawlURL != nil {
+			publishCrawlEvent(models.EventCrawlFailed, crawlURL)
+			p.webhooks.EnqueueCrawlEvent(models.EventCrawlFailed, crawlURL)
+		}
+	}
+
+	if len(requeued) > 0 || len(failed) > 0 {
+		logger.Sugar().Infof("Recovered stale crawls: %d requeued, %d marked as failed", len(requeued), len(failed))
 	}
+}
 
-	logger.Sugar().Infof("Processing %d queued crawl jobs", len(queuedURLs))
+func (p *CrawlerJobProcessor) processQueuedJobs() {
+	free := p.pool.FreeCapacity()
+	if free == 0 {
+		return
+	}
 
-	for _, crawlURL := range queuedURLs {
-		// Start crawling in background
-		go func(url models.CrawlURL) {
-			// Update status to running
-			url.Status = models.StatusRunning
-			now := time.Now()
-			url.LastCrawledAt = &now
+	// Claim queued crawl URLs so that other backend instances skip them
+	ids, err := p.repo.ClaimQueuedCrawlURLs(p.pool.WorkerID(), free, p.pool.Lease())
+	if err != nil {
+		logger.Sugar().Errorf("Failed to claim queued URLs: %v", err)
+		return
+	}
 
-			if err := p.repo.UpdateCrawlURL(&url); err != nil {
-				logger.Sugar().Errorf("Failed to update crawl URL status: %v", err)
-				return
-			}
+	submitted := 0
+	for _, id := range ids {
+		if p.pool.Submit(id) {
+			submitted++
+			continue
+		}
+		// Hand back URLs the pool refused so they are not held until the lease expires
+		p.repo.ReleaseClaim(id, p.pool.WorkerID())
+	}
 
-			// Perform crawl
-			p.crawlerService.performCrawl(&url)
-		}(crawlURL)
+	if submitted > 0 {
+		logger.Sugar().Infof("Submitted %d queued crawl jobs", submitted)
 	}
 }
@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
@@ -16,15 +18,41 @@ import (
 	"sykell-backend/pkg/logger"
 )
 
+var (
+	// ErrCrawlURLNotFound is returned for operations on an unknown URL.
+	ErrCrawlURLNotFound = errors.New("crawl URL not found")
+	// ErrCrawlNotActive is returned when cancelling a URL that is neither
+	// queued nor running.
+	ErrCrawlNotActive = errors.New("crawl is not queued or running")
+)
+
 type CrawlerService struct {
-	repo   *repository.CrawlerRepository
-	client *http.Client
-	mu     sync.RWMutex
+	repo     *repository.CrawlerRepository
+	client   *http.Client
+	progress *crawlProgressTracker
+	mu       sync.RWMutex
+}
+
+var (
+	defaultCrawlerService     *CrawlerService
+	defaultCrawlerServiceOnce sync.Once
+)
+
+// DefaultCrawlerService returns the process-wide CrawlerService. It is
+// created on first use so that its repository picks up the database
+// connection opened in main.
+func DefaultCrawlerService() *CrawlerService {
+	defaultCrawlerServiceOnce.Do(func() {
+		defaultCrawlerService = NewCrawlerService()
+	})
+	return defaultCrawlerService
 }
 
 func NewCrawlerService() *CrawlerService {
+	repo := repository.NewCrawlerRepository()
 	return &CrawlerService{
-		repo: repository.NewCrawlerRepository(),
+		repo:     repo,
+		progress: newCrawlProgressTracker(repo),
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -38,100 +66,424 @@ func NewCrawlerService() *CrawlerService {
 	}
 }
 
-func (s *CrawlerService) AddURL(urlStr string) (*models.CrawlURL, error) {
-	// Validate URL
-	parsedURL, err := url.Parse(urlStr)
+// normalizePriority validates a requested crawl priority, returning
+// fallback if none was given.
+func normalizePriority(priority, fallback string) (string, error) {
+	priority = strings.ToLower(strings.TrimSpace(priority))
+	switch priority {
+	case "":
+		return fallback, nil
+	case models.PriorityInteractive, models.PriorityBulk, models.PriorityScheduled:
+		return priority, nil
+	default:
+		return "", fmt.Errorf("unknown priority %q", priority)
+	}
+}
+
+// AddURL queues urlStr for crawling in projectID on behalf of the user
+// ownerID. Submissions are deduplicated by their canonical form within the
+// project; if the URL matches an existing entry, that entry is returned
+// unchanged and duplicate is true. URLs without a priority are queued as
+// interactive.
+func (s *CrawlerService) AddURL(urlStr string, ownerID, projectID int, opts models.CrawlOptions) (crawlURL *models.CrawlURL, duplicate bool, err error) {
+	normalizedURL, opts, err := prepareURL(urlStr, opts, models.PriorityInteractive)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s.createCrawlURL(urlStr, normalizedURL, ownerID, projectID, opts)
+}
+
+// prepareURL validates a submitted URL and its options, returning the
+// URL's canonical form and the normalized options. URLs without a priority
+// get fallbackPriority.
+func prepareURL(urlStr string, opts models.CrawlOptions, fallbackPriority string) (string, models.CrawlOptions, error) {
+	// Validate and canonicalize URL
+	normalizedURL, err := canonicalizeURL(urlStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL: %v", err)
+		return "", opts, err
+	}
+
+	opts, err = normalizeCrawlOptions(opts)
+	if err != nil {
+		return "", opts, err
+	}
+
+	opts.Priority, err = normalizePriority(opts.Priority, fallbackPriority)
+	if err != nil {
+		return "", opts, err
+	}
+
+	opts.Tags, err = normalizeTags(opts.Tags)
+	if err != nil {
+		return "", opts, err
+	}
+
+	return normalizedURL, opts, nil
+}
+
+// createCrawlURL stores a URL prepared by prepareURL and announces it.
+func (s *CrawlerService) createCrawlURL(urlStr, normalizedURL string, ownerID, projectID int, opts models.CrawlOptions) (crawlURL *models.CrawlURL, duplicate bool, err error) {
+	crawlURL, duplicate, err = s.repo.CreateCrawlURL(urlStr, normalizedURL, ownerID, projectID, opts)
+	if err == nil && !duplicate {
+		publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+	}
+	return crawlURL, duplicate, err
+}
+
+// Tags are stored comma-separated, which bounds their number and length.
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+// normalizeTags trims tags and drops empty ones and case-insensitive
+// duplicates, keeping the first spelling.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("tag %q must not contain commas", tag)
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
 	}
+	return normalized, nil
+}
+
+// BackfillNormalizedURLs canonicalizes the URLs added before duplicate
+// detection existed, so that submitting them again is detected. When two
+// old URLs of a project turn out to be the same page, the older one keeps
+// the canonical form and the newer one is tagged "duplicate-of-<id>" for
+// the project's members to review; nothing is deleted.
+func (s *CrawlerService) BackfillNormalizedURLs() error {
+	backfilled, flagged := 0, 0
+	for {
+		crawlURLs, err := s.repo.GetCrawlURLsWithoutNormalizedURL(500)
+		if err != nil {
+			return err
+		}
+		if len(crawlURLs) == 0 {
+			break
+		}
 
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return nil, fmt.Errorf("URL must use http or https scheme")
+		for _, crawlURL := range crawlURLs {
+			// URLs that no longer pass validation keep their raw form
+			normalizedURL, err := canonicalizeURL(crawlURL.URL)
+			if err != nil {
+				normalizedURL = crawlURL.URL
+			}
+
+			stored, err := s.repo.SetNormalizedURL(crawlURL.ID, normalizedURL)
+			if err != nil {
+				return err
+			}
+			if stored {
+				backfilled++
+				continue
+			}
+
+			original, err := s.repo.GetCrawlURLByNormalizedURL(crawlURL.ProjectID, normalizedURL)
+			if err != nil {
+				return err
+			}
+			tag := "duplicate"
+			if original != nil {
+				tag = fmt.Sprintf("duplicate-of-%d", original.ID)
+			}
+			if err := s.repo.FlagDuplicateCrawlURL(crawlURL.ID, normalizedURL, tag); err != nil {
+				return err
+			}
+			logger.Sugar().Warnf("Crawl URL %d (%s) duplicates another URL in project %d, tagged %q",
+				crawlURL.ID, crawlURL.URL, crawlURL.ProjectID, tag)
+			flagged++
+		}
 	}
 
-	return s.repo.CreateCrawlURL(urlStr)
+	if backfilled > 0 || flagged > 0 {
+		logger.Sugar().Infof("Canonicalized %d existing URLs, %d flagged as duplicates", backfilled, flagged)
+	}
+	return nil
 }
 
-func (s *CrawlerService) CrawlURL(id int) error {
-	crawlURL, err := s.repo.GetCrawlURLByID(id)
+// CrawlURL queues the URL with the given ID at interactive priority and
+// hands it to the worker pool. If the pool's queue is full the URL stays
+// queued in the database and CrawlerJobProcessor submits it later.
+func (s *CrawlerService) CrawlURL(scope models.ProjectScope, id int) error {
+	crawlURL, err := s.repo.GetCrawlURL(scope, id)
 	if err != nil {
 		return err
 	}
 
 	if crawlURL == nil {
-		return fmt.Errorf("crawl URL not found")
+		return ErrCrawlURLNotFound
 	}
 
-	// Update status to running
-	crawlURL.Status = models.StatusRunning
-	now := time.Now()
-	crawlURL.LastCrawledAt = &now
+	if crawlURL.Status == models.StatusRunning {
+		return fmt.Errorf("crawl already running")
+	}
 
-	if err := s.repo.UpdateCrawlURL(crawlURL); err != nil {
+	requeued, err := s.repo.RequeueCrawlURL(id, models.PriorityInteractive)
+	if err != nil {
 		return err
 	}
+	if !requeued {
+		return fmt.Errorf("crawl already running")
+	}
+
+	crawlURL.Status = models.StatusQueued
+	crawlURL.Priority = models.PriorityInteractive
+	crawlURL.ErrorMessage = ""
+	crawlURL.Attempts = 0
+	crawlURL.NextAttemptAt = nil
 
-	// Perform the actual crawling
-	go s.performCrawl(crawlURL)
+	publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+	DefaultCrawlWorkerPool().Submit(id)
 
 	return nil
 }
 
-func (s *CrawlerService) performCrawl(crawlURL *models.CrawlURL) {
-	logger.Sugar().Infof("Starting crawl for URL: %s", crawlURL.URL)
+// performCrawl fetches and analyses a page. If ctx is cancelled the crawl
+// stops as soon as possible and its results are discarded. crawlURL must
+// have been loaded after its claim was won, since results are only stored
+// while the URL is still running under that claim.
+func (s *CrawlerService) performCrawl(ctx context.Context, crawlURL *models.CrawlURL) {
+	logger.Sugar().Infof("Starting crawl for URL: %s (attempt %d)", crawlURL.URL, crawlURL.Attempts)
+
+	attempt := &models.CrawlAttempt{
+		CrawlURLID: crawlURL.ID,
+		Attempt:    crawlURL.Attempts,
+		Outcome:    models.AttemptRunning,
+		StartedAt:  time.Now(),
+	}
+	if err := s.repo.CreateCrawlAttempt(attempt); err != nil {
+		logger.Sugar().Errorf("Failed to record crawl attempt for %s: %v", crawlURL.URL, err)
+	}
+
+	publishCrawlEvent(models.EventCrawlStarted, crawlURL)
+	s.progress.setPhase(crawlURL, models.ProgressFetching, 0)
+	defer s.progress.finish(crawlURL.ID)
 
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Sugar().Errorf("Panic during crawl of %s: %v", crawlURL.URL, r)
-			crawlURL.Status = models.StatusError
-			crawlURL.ErrorMessage = fmt.Sprintf("Internal error: %v", r)
-			s.repo.UpdateCrawlURL(crawlURL)
+			s.failCrawl(crawlURL, attempt, &crawlFailure{
+				Class:   models.ErrorClassPermanent,
+				Message: fmt.Sprintf("Internal error: %v", r),
+			})
 		}
 	}()
 
 	// Fetch the webpage
-	resp, err := s.client.Get(crawlURL.URL)
+	req, err := http.NewRequestWithContext(ctx, "GET", crawlURL.URL, nil)
 	if err != nil {
-		crawlURL.Status = models.StatusError
-		crawlURL.ErrorMessage = fmt.Sprintf("Failed to fetch URL: %v", err)
-		s.repo.UpdateCrawlURL(crawlURL)
+		s.failCrawl(crawlURL, attempt, &crawlFailure{
+			Class:   models.ErrorClassPermanent,
+			Message: fmt.Sprintf("Invalid URL: %v", err),
+		})
+		return
+	}
+
+	resp, err := s.client.Do(req)
+	if ctx.Err() != nil {
+		s.abandonCrawl(crawlURL, attempt)
+		return
+	}
+	if err != nil {
+		s.failCrawl(crawlURL, attempt, &crawlFailure{
+			Class:   models.ErrorClassNetwork,
+			Message: fmt.Sprintf("Failed to fetch URL: %v", err),
+		})
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		crawlURL.Status = models.StatusError
-		crawlURL.ErrorMessage = fmt.Sprintf("HTTP error: %d", resp.StatusCode)
-		s.repo.UpdateCrawlURL(crawlURL)
+		s.failCrawl(crawlURL, attempt, classifyHTTPFailure(resp))
 		return
 	}
 
-	// Parse HTML
+	// Parse HTML. The tokenizer accepts any input, so an error here means
+	// reading the response body failed.
+	s.progress.setPhase(crawlURL, models.ProgressParsing, 0)
 	doc, err := html.Parse(resp.Body)
+	if ctx.Err() != nil {
+		s.abandonCrawl(crawlURL, attempt)
+		return
+	}
 	if err != nil {
-		crawlURL.Status = models.StatusError
-		crawlURL.ErrorMessage = fmt.Sprintf("Failed to parse HTML: %v", err)
-		s.repo.UpdateCrawlURL(crawlURL)
+		s.failCrawl(crawlURL, attempt, &crawlFailure{
+			Class:   models.ErrorClassNetwork,
+			Message: fmt.Sprintf("Failed to parse HTML: %v", err),
+		})
 		return
 	}
 
-	// Extract information from HTML
+	// Extract information from HTML. crawlURL still holds the results of
+	// the previous crawl, which must not carry over.
+	resetPageResults(crawlURL)
 	s.extractHTMLInfo(crawlURL, doc)
-
-	// Extract and check links
-	links := s.extractLinks(doc, crawlURL.URL)
-	s.categorizeAndCheckLinks(crawlURL, links)
+	metaDescription, bodyText := extractPageText(doc)
+
+	// Extract and check links, resolving them against the final URL after
+	// redirects or the document's <base href> if it declares one
+	baseURL := resolveBaseURL(doc, resp.Request.URL)
+	links := s.extractLinks(doc)
+	linkResults := s.categorizeAndCheckLinks(ctx, crawlURL, baseURL, links)
+	if ctx.Err() != nil {
+		s.abandonCrawl(crawlURL, attempt)
+		return
+	}
 
 	crawlURL.Status = models.StatusCompleted
 	crawlURL.ErrorMessage = ""
+	crawlURL.NextAttemptAt = nil
 
-	if err := s.repo.UpdateCrawlURL(crawlURL); err != nil {
+	stored, err := s.repo.UpdateCrawlResult(crawlURL, linkResults)
+	if err != nil {
 		logger.Sugar().Errorf("Failed to update crawl URL: %v", err)
 	}
+	if err == nil && !stored {
+		s.abandonCrawl(crawlURL, attempt)
+		return
+	}
+
+	content := &models.PageContent{
+		CrawlURLID:      crawlURL.ID,
+		Title:           crawlURL.Title,
+		MetaDescription: metaDescription,
+		BodyText:        bodyText,
+	}
+	if err := s.repo.SavePageContent(content); err != nil {
+		logger.Sugar().Errorf("Failed to index page content of %s: %v", crawlURL.URL, err)
+	}
+
+	s.finishAttempt(attempt, models.AttemptSucceeded, nil, nil)
+
+	publishCrawlEvent(models.EventCrawlCompleted, crawlURL)
+	DefaultWebhookService().EnqueueCrawlEvent(models.EventCrawlCompleted, crawlURL)
 
 	logger.Sugar().Infof("Completed crawl for URL: %s", crawlURL.URL)
 }
 
+// failCrawl records a failed attempt. Transient failures are requeued with
+// exponential backoff until their retry policy is exhausted, after which the
+// URL is marked as failed. Permanent failures are marked as errors at once.
+// Like a successful result, the failure is dropped if the crawl was
+// cancelled or lost its claim in the meantime.
+func (s *CrawlerService) failCrawl(crawlURL *models.CrawlURL, attempt *models.CrawlAttempt, failure *crawlFailure) {
+	policy := retryPolicyFor(failure.Class)
+	crawlURL.ErrorMessage = failure.Message
+
+	var nextAttemptAt *time.Time
+	outcome := models.AttemptError
+
+	switch {
+	case crawlURL.Attempts < policy.MaxAttempts:
+		next := time.Now().Add(policy.delay(crawlURL.Attempts, failure.RetryAfter))
+		nextAttemptAt = &next
+		outcome = models.AttemptRetrying
+		crawlURL.Status = models.StatusQueued
+		logger.Sugar().Warnf("Crawl of %s failed (%s), retrying at %s: %s",
+			crawlURL.URL, failure.Class, next.Format(time.RFC3339), failure.Message)
+	case policy.MaxAttempts > 1:
+		outcome = models.AttemptFailed
+		crawlURL.Status = models.StatusFailed
+		logger.Sugar().Errorf("Crawl of %s failed after %d attempts: %s",
+			crawlURL.URL, crawlURL.Attempts, failure.Message)
+	default:
+		crawlURL.Status = models.StatusError
+	}
+
+	crawlURL.NextAttemptAt = nextAttemptAt
+	stored, err := s.repo.UpdateCrawlResult(crawlURL, nil)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to update crawl URL: %v", err)
+	}
+	if err == nil && !stored {
+		s.abandonCrawl(crawlURL, attempt)
+		return
+	}
+
+	if crawlURL.Status == models.StatusQueued {
+		publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+	} else {
+		publishCrawlEvent(models.EventCrawlFailed, crawlURL)
+		DefaultWebhookService().EnqueueCrawlEvent(models.EventCrawlFailed, crawlURL)
+	}
+
+	s.finishAttempt(attempt, outcome, failure, nextAttemptAt)
+}
+
+// abandonCrawl closes the attempt of a crawl whose context was cancelled,
+// or whose result could not be stored, either because a user cancelled it
+// or because this process lost its claim.
+// The URL's row already reflects the new state and is left untouched.
+func (s *CrawlerService) abandonCrawl(crawlURL *models.CrawlURL, attempt *models.CrawlAttempt) {
+	outcome := models.AttemptInterrupted
+	if current, err := s.repo.GetCrawlURLByID(crawlURL.ID); err == nil && current != nil && current.Status == models.StatusCancelled {
+		outcome = models.AttemptCancelled
+		publishCrawlEvent(models.EventCrawlCancelled, current)
+	}
+
+	s.finishAttempt(attempt, outcome, nil, nil)
+
+	logger.Sugar().Infof("Stopped crawl for URL: %s (%s)", crawlURL.URL, outcome)
+}
+
+func (s *CrawlerService) finishAttempt(attempt *models.CrawlAttempt, outcome string, failure *crawlFailure, nextAttemptAt *time.Time) {
+	if attempt.ID == 0 {
+		return
+	}
+
+	now := time.Now()
+	attempt.Outcome = outcome
+	attempt.FinishedAt = &now
+	attempt.NextAttemptAt = nextAttemptAt
+	if failure != nil {
+		attempt.ErrorClass = failure.Class
+		attempt.StatusCode = failure.StatusCode
+		attempt.ErrorMessage = failure.Message
+	}
+
+	s.repo.FinishCrawlAttempt(attempt)
+}
+
+// resetPageResults clears the fields derived from a page's content.
+func resetPageResults(crawlURL *models.CrawlURL) {
+	crawlURL.Title = ""
+	crawlURL.HTMLVersion = ""
+	crawlURL.H1Count = 0
+	crawlURL.H2Count = 0
+	crawlURL.H3Count = 0
+	crawlURL.H4Count = 0
+	crawlURL.H5Count = 0
+	crawlURL.H6Count = 0
+	crawlURL.HasLoginForm = false
+	crawlURL.InternalLinksCount = 0
+	crawlURL.ExternalLinksCount = 0
+	crawlURL.InaccessibleLinksCount = 0
+	crawlURL.CheckedLinksCount = 0
+	crawlURL.CachedLinksCount = 0
+	crawlURL.UncheckedLinksCount = 0
+	crawlURL.MailtoLinksCount = 0
+	crawlURL.TelLinksCount = 0
+	crawlURL.JavascriptLinksCount = 0
+	crawlURL.DataLinksCount = 0
+	crawlURL.OtherSchemeLinksCount = 0
+}
+
 func (s *CrawlerService) extractHTMLInfo(crawlURL *models.CrawlURL, doc *html.Node) {
 	var f func(*html.Node)
 	f = func(n *html.Node) {
@@ -218,7 +570,7 @@ func (s *CrawlerService) isLoginForm(form *html.Node) bool {
 	return hasPasswordField && hasUsernameField
 }
 
-func (s *CrawlerService) extractLinks(doc *html.Node, baseURL string) []string {
+func (s *CrawlerService) extractLinks(doc *html.Node) []string {
 	var links []string
 
 	var f func(*html.Node)
@@ -226,8 +578,9 @@ func (s *CrawlerService) extractLinks(doc *html.Node, baseURL string) []string {
 		if n.Type == html.ElementNode && n.Data == "a" {
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
-					if attr.Val != "" && !strings.HasPrefix(attr.Val, "#") {
-						links = append(links, attr.Val)
+					href := strings.TrimSpace(attr.Val)
+					if href != "" && !strings.HasPrefix(href, "#") {
+						links = append(links, href)
 					}
 					break
 				}
@@ -243,19 +596,41 @@ func (s *CrawlerService) extractLinks(doc *html.Node, baseURL string) []string {
 	return links
 }
 
-func (s *CrawlerService) categorizeAndCheckLinks(crawlURL *models.CrawlURL, links []string) {
-	baseURL, err := url.Parse(crawlURL.URL)
+// categorizeAndCheckLinks counts the page's links by kind, checks the
+// selected ones and returns the results. Nothing is written to the database
+// here: the results are stored together with the crawl's outcome, so a crawl
+// that is interrupted leaves the previous results intact.
+func (s *CrawlerService) categorizeAndCheckLinks(ctx context.Context, crawlURL *models.CrawlURL, baseURL *url.URL, links []string) *models.LinkResults {
+	results := &models.LinkResults{}
+
+	pageURL, err := url.Parse(crawlURL.URL)
 	if err != nil {
-		logger.Sugar().Errorf("Failed to parse base URL: %v", err)
-		return
+		logger.Sugar().Errorf("Failed to parse page URL: %v", err)
+		return results
 	}
 
+	site := newSiteMatcher(pageURL, crawlURL.CrawlOptions)
+
 	internalCount := 0
 	externalCount := 0
 	inaccessibleCount := 0
+	mailtoCount := 0
+	telCount := 0
+	javascriptCount := 0
+	dataCount := 0
+	otherSchemeCount := 0
+
+	recordIssue := func(link, issueType, message string) {
+		results.Issues = append(results.Issues, models.LinkIssue{
+			CrawlURLID: crawlURL.ID,
+			URL:        link,
+			IssueType:  issueType,
+			Message:    message,
+		})
+	}
 
-	// Create a context with timeout for link checking
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// Bound link checking in time; cancelling the crawl stops it too
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	// Channel to limit concurrent requests
@@ -263,14 +638,43 @@ func (s *CrawlerService) categorizeAndCheckLinks(crawlURL *models.CrawlURL, link
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
+	// Unique HTTP links in the order they first appear
+	var candidates []linkCandidate
+	seen := make(map[string]bool)
+
 	for _, link := range links {
+		// Non-HTTP schemes are never fetched
+		switch scheme := linkScheme(link); scheme {
+		case "", "http", "https":
+		case schemeMailto:
+			mailtoCount++
+			if err := validateMailto(link); err != nil {
+				recordIssue(link, models.LinkIssueInvalidMailto, err.Error())
+			}
+			continue
+		case schemeTel:
+			telCount++
+			continue
+		case schemeJavascript:
+			javascriptCount++
+			recordIssue(link, models.LinkIssueJavascriptLink,
+				"javascript: links are not keyboard or screen reader friendly; use a <button> instead")
+			continue
+		case schemeData:
+			dataCount++
+			continue
+		default:
+			otherSchemeCount++
+			continue
+		}
+
 		// Resolve relative URLs
 		linkURL, err := baseURL.Parse(link)
 		if err != nil {
 			continue
 		}
 
-		isInternal := linkURL.Host == baseURL.Host || linkURL.Host == ""
+		isInternal := site.isInternal(linkURL)
 
 		if isInternal {
 			internalCount++
@@ -278,35 +682,81 @@ func (s *CrawlerService) categorizeAndCheckLinks(crawlURL *models.CrawlURL, link
 			externalCount++
 		}
 
-		// Check if link is accessible (only for a sample to avoid overwhelming)
-		if len(links) <= 50 || (len(links) > 50 && (internalCount+externalCount) <= 50) {
-			wg.Add(1)
-			go func(linkStr string, linkURL *url.URL) {
-				defer wg.Done()
+		key := normalizeLinkKey(linkURL)
+		if !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, linkCandidate{URL: linkURL, Key: key, IsInternal: isInternal})
+		}
+	}
+
+	toCheck := selectLinksToCheck(candidates, crawlURL.CrawlOptions)
+	s.progress.setPhase(crawlURL, models.ProgressCheckingLinks, len(toCheck))
+
+	cache := getLinkStatusCache()
+	cachedCount := 0
+
+	// Links whose check was cut short by the deadline or by cancellation
+	// are counted as unchecked rather than checked
+	var checkedCount atomic.Int64
+
+	for _, candidate := range toCheck {
+		wg.Add(1)
+		go func(candidate linkCandidate) {
+			defer wg.Done()
+
+			linkCheck := models.LinkCheck{
+				CrawlURLID: crawlURL.ID,
+				URL:        candidate.URL.String(),
+			}
 
+			if cached, ok := cache.Get(candidate.Key); ok {
+				linkCheck.StatusCode = cached.StatusCode
+				linkCheck.ErrorMessage = cached.ErrorMessage
+				linkCheck.CheckedAt = cached.CheckedAt
+				linkCheck.FromCache = true
+			} else {
 				select {
 				case semaphore <- struct{}{}:
-					defer func() { <-semaphore }()
-
-					if s.checkLinkAccessibility(ctx, linkURL.String()) != nil {
-						mu.Lock()
-						inaccessibleCount++
-
-						// Store broken link in database
-						brokenLink := &models.BrokenLink{
-							CrawlURLID:   crawlURL.ID,
-							URL:          linkURL.String(),
-							StatusCode:   0, // Will be set by checkLinkAccessibility if available
-							ErrorMessage: "Link check failed",
-						}
-						s.repo.CreateBrokenLink(brokenLink)
-						mu.Unlock()
+					linkCheck.CheckedAt = time.Now()
+					statusCode, err := s.checkLinkAccessibility(ctx, linkCheck.URL)
+					<-semaphore
+					if ctx.Err() != nil {
+						return
+					}
+
+					linkCheck.StatusCode = statusCode
+					if err != nil {
+						linkCheck.ErrorMessage = err.Error()
 					}
+					cache.Set(candidate.Key, linkCheck.StatusCode, linkCheck.ErrorMessage, linkCheck.CheckedAt)
 				case <-ctx.Done():
 					return
 				}
-			}(link, linkURL)
-		}
+			}
+
+			checkedCount.Add(1)
+			s.progress.linkChecked(crawlURL.ID)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results.Checks = append(results.Checks, linkCheck)
+
+			if linkCheck.FromCache {
+				cachedCount++
+			}
+
+			if linkCheck.ErrorMessage != "" {
+				inaccessibleCount++
+
+				results.BrokenLinks = append(results.BrokenLinks, models.BrokenLink{
+					CrawlURLID:   crawlURL.ID,
+					URL:          linkCheck.URL,
+					StatusCode:   linkCheck.StatusCode,
+					ErrorMessage: linkCheck.ErrorMessage,
+				})
+			}
+		}(candidate)
 	}
 
 	wg.Wait()
@@ -314,40 +764,93 @@ func (s *CrawlerService) categorizeAndCheckLinks(crawlURL *models.CrawlURL, link
 	crawlURL.InternalLinksCount = internalCount
 	crawlURL.ExternalLinksCount = externalCount
 	crawlURL.InaccessibleLinksCount = inaccessibleCount
+	checked := int(checkedCount.Load())
+	crawlURL.CheckedLinksCount = checked
+	crawlURL.CachedLinksCount = cachedCount
+	crawlURL.UncheckedLinksCount = len(candidates) - checked
+	crawlURL.MailtoLinksCount = mailtoCount
+	crawlURL.TelLinksCount = telCount
+	crawlURL.JavascriptLinksCount = javascriptCount
+	crawlURL.DataLinksCount = dataCount
+	crawlURL.OtherSchemeLinksCount = otherSchemeCount
+
+	return results
 }
 
-func (s *CrawlerService) checkLinkAccessibility(ctx context.Context, urlStr string) error {
+// checkLinkAccessibility sends a HEAD request to urlStr. It returns the
+// response status code, or 0 if no response was received, and a non-nil
+// error if the link is inaccessible.
+func (s *CrawlerService) checkLinkAccessibility(ctx context.Context, urlStr string) (int, error) {
 	req, err := http.NewRequestWithContext(ctx, "HEAD", urlStr, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
 
-func (s *CrawlerService) GetCrawlURLs(page, limit int, status, search string) ([]models.CrawlURL, int, error) {
-	offset := (page - 1) * limit
-	return s.repo.GetCrawlURLs(limit, offset, status, search)
+// GetCrawlURLs returns a page of the URL list and the number of URLs
+// matching its filter. The returned cursor continues the list after the
+// page, and is empty on the last page.
+func (s *CrawlerService) GetCrawlURLs(opts CrawlURLListOptions) ([]models.CrawlURL, int, string, error) {
+	if len(opts.Sort) == 0 {
+		opts.Sort, _ = ParseCrawlURLSort("")
+	}
+
+	// One URL more than the page is read to tell whether there is a next page
+	query := models.CrawlURLQuery{
+		Filter: opts.Filter,
+		Sort:   opts.Sort,
+		Limit:  opts.Limit + 1,
+		Offset: (opts.Page - 1) * opts.Limit,
+	}
+	if opts.Cursor != "" {
+		after, err := decodeCrawlURLCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		query.After = after
+	}
+
+	crawlURLs, total, err := s.repo.GetCrawlURLs(query)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var nextCursor string
+	if len(crawlURLs) > opts.Limit {
+		crawlURLs = crawlURLs[:opts.Limit]
+		nextCursor = encodeCrawlURLCursor(&crawlURLs[len(crawlURLs)-1], opts.Sort)
+	}
+
+	for i := range crawlURLs {
+		if crawlURLs[i].Progress != nil {
+			progress := s.progress.withLiveProgress(*crawlURLs[i].Progress)
+			crawlURLs[i].Progress = &progress
+		}
+	}
+
+	return crawlURLs, total, nextCursor, nil
 }
 
-func (s *CrawlerService) GetCrawlResult(id int) (*models.CrawlResult, error) {
-	crawlURL, err := s.repo.GetCrawlURLByID(id)
+func (s *CrawlerService) GetCrawlResult(scope models.ProjectScope, id int) (*models.CrawlResult, error) {
+	crawlURL, err := s.repo.GetCrawlURL(scope, id)
 	if err != nil {
 		return nil, err
 	}
 
 	if crawlURL == nil {
-		return nil, fmt.Errorf("crawl URL not found")
+		return nil, ErrCrawlURLNotFound
 	}
 
 	brokenLinks, err := s.repo.GetBrokenLinks(id)
@@ -355,33 +858,146 @@ func (s *CrawlerService) GetCrawlResult(id int) (*models.CrawlResult, error) {
 		return nil, err
 	}
 
+	linkIssues, err := s.repo.GetLinkIssues(id)
+	if err != nil {
+		return nil, err
+	}
+
+	linkChecks, err := s.repo.GetLinkChecks(id)
+	if err != nil {
+		return nil, err
+	}
+
+	attemptHistory, err := s.repo.GetCrawlAttempts(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if crawlURL.Progress != nil {
+		progress := s.progress.withLiveProgress(*crawlURL.Progress)
+		crawlURL.Progress = &progress
+	}
+
 	return &models.CrawlResult{
-		CrawlURL:    *crawlURL,
-		BrokenLinks: brokenLinks,
+		CrawlURL:       *crawlURL,
+		BrokenLinks:    brokenLinks,
+		LinkIssues:     linkIssues,
+		LinkChecks:     linkChecks,
+		AttemptHistory: attemptHistory,
 	}, nil
 }
 
-func (s *CrawlerService) DeleteCrawlURLs(ids []int) error {
-	return s.repo.DeleteCrawlURLs(ids)
+// DeleteCrawlURLs deletes the URLs with the given IDs that are in scope.
+func (s *CrawlerService) DeleteCrawlURLs(scope models.ProjectScope, ids []int) error {
+	return s.repo.DeleteCrawlURLs(scope, ids)
 }
 
-func (s *CrawlerService) GetStats() (*models.CrawlStats, error) {
-	return s.repo.GetCrawlStats()
+// GetStats returns URL counts by status and the progress of running
+// crawls in scope.
+func (s *CrawlerService) GetStats(scope models.ProjectScope) (*models.CrawlStats, error) {
+	stats, err := s.repo.GetCrawlStats(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.InProgress, err = s.repo.GetRunningCrawlProgress(scope)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats.InProgress {
+		stats.InProgress[i] = s.progress.withLiveProgress(stats.InProgress[i])
+	}
+
+	return stats, nil
 }
 
-func (s *CrawlerService) ReCrawlURLs(ids []int) error {
-	for _, id := range ids {
+// CancelCrawl cancels the queued or running crawl of the URL with the given ID.
+func (s *CrawlerService) CancelCrawl(scope models.ProjectScope, id int) error {
+	crawlURL, err := s.repo.GetCrawlURL(scope, id)
+	if err != nil {
+		return err
+	}
+	if crawlURL == nil {
+		return ErrCrawlURLNotFound
+	}
+
+	cancelled, err := s.CancelCrawls(scope, []int{id})
+	if err != nil {
+		return err
+	}
+	if len(cancelled) == 0 {
+		return ErrCrawlNotActive
+	}
+
+	return nil
+}
+
+// CancelCrawls moves the given URLs in scope that are queued or running to
+// the cancelled status and returns their IDs. Crawls running in this
+// process stop at once; crawls running elsewhere stop at their next
+// heartbeat.
+func (s *CrawlerService) CancelCrawls(scope models.ProjectScope, ids []int) ([]int, error) {
+	cancelled, err := s.repo.CancelCrawlURLs(scope, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Crawls running here announce their cancellation once they have stopped
+	for _, id := range cancelled {
+		if DefaultCrawlWorkerPool().Cancel(id) {
+			continue
+		}
 		crawlURL, err := s.repo.GetCrawlURLByID(id)
+		if err != nil || crawlURL == nil {
+			continue
+		}
+		publishCrawlEvent(models.EventCrawlCancelled, crawlURL)
+	}
+
+	if cancelled == nil {
+		cancelled = []int{}
+	}
+	return cancelled, nil
+}
+
+// ReCrawlURLs requeues the given URLs in scope. Without a priority, a single URL is
+// requeued as interactive and several as bulk. Only interactive crawls are
+// handed to the worker pool directly; the rest wait for CrawlerJobProcessor
+// to claim them in queue order.
+func (s *CrawlerService) ReCrawlURLs(scope models.ProjectScope, ids []int, priority string) error {
+	fallback := models.PriorityBulk
+	if len(ids) == 1 {
+		fallback = models.PriorityInteractive
+	}
+	priority, err := normalizePriority(priority, fallback)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		crawlURL, err := s.repo.GetCrawlURL(scope, id)
 		if err != nil {
 			logger.Sugar().Errorf("Failed to get crawl URL %d: %v", id, err)
 			continue
 		}
 
-		if crawlURL != nil {
+		if crawlURL != nil && crawlURL.Status != models.StatusRunning {
+			requeued, err := s.repo.RequeueCrawlURL(id, priority)
+			if err != nil {
+				logger.Sugar().Errorf("Failed to requeue crawl URL %d: %v", id, err)
+				continue
+			}
+			if !requeued {
+				continue
+			}
 			crawlURL.Status = models.StatusQueued
+			crawlURL.Priority = priority
 			crawlURL.ErrorMessage = ""
-			if err := s.repo.UpdateCrawlURL(crawlURL); err != nil {
-				logger.Sugar().Errorf("Failed to update crawl URL %d: %v", id, err)
+			crawlURL.Attempts = 0
+			crawlURL.NextAttemptAt = nil
+			publishCrawlEvent(models.EventCrawlQueued, crawlURL)
+			if priority == models.PriorityInteractive {
+				DefaultCrawlWorkerPool().Submit(id)
 			}
 		}
 	}
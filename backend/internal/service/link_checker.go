@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+
+	"sykell-backend/internal/models"
+)
+
+// linkCandidate is a unique HTTP(S) link found on a page.
+type linkCandidate struct {
+	URL        *url.URL
+	Key        string
+	IsInternal bool
+}
+
+// normalizeLinkKey returns the form used to deduplicate links before they are
+// checked: scheme and host are lowercased, default ports and fragments are
+// dropped and an empty path becomes "/".
+func normalizeLinkKey(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.User = nil
+
+	if port := n.Port(); (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+		n.Host = n.Hostname()
+	}
+	if n.Path == "" && n.RawPath == "" {
+		n.Path = "/"
+	}
+
+	return n.String()
+}
+
+func defaultLinkCheckMode() string {
+	return getEnv("CRAWLER_LINK_CHECK_MODE", models.LinkCheckLimit)
+}
+
+func defaultLinkCheckLimit() int {
+	return getEnvInt("CRAWLER_LINK_CHECK_LIMIT", 50)
+}
+
+// normalizeLinkCheckOptions validates the link-check strategy in opts and
+// fills in server defaults.
+func normalizeLinkCheckOptions(opts models.CrawlOptions) (models.CrawlOptions, error) {
+	opts.LinkCheckMode = strings.ToLower(strings.TrimSpace(opts.LinkCheckMode))
+	if opts.LinkCheckMode == "" {
+		opts.LinkCheckMode = defaultLinkCheckMode()
+	}
+
+	if opts.LinkCheckLimit < 0 {
+		return opts, fmt.Errorf("link check limit must not be negative")
+	}
+
+	switch opts.LinkCheckMode {
+	case models.LinkCheckAll, models.LinkCheckInternal:
+	case models.LinkCheckLimit, models.LinkCheckSample:
+		if opts.LinkCheckLimit == 0 {
+			opts.LinkCheckLimit = defaultLinkCheckLimit()
+		}
+	default:
+		return opts, fmt.Errorf("unknown link check mode %q", opts.LinkCheckMode)
+	}
+
+	return opts, nil
+}
+
+// selectLinksToCheck applies the crawl's link-check strategy to the unique
+// links found on a page and returns the ones that should be requested.
+func selectLinksToCheck(candidates []linkCandidate, opts models.CrawlOptions) []linkCandidate {
+	opts, err := normalizeLinkCheckOptions(opts)
+	if err != nil {
+		// Rows stored before validation existed fall back to the defaults
+		opts, _ = normalizeLinkCheckOptions(models.CrawlOptions{})
+	}
+
+	switch opts.LinkCheckMode {
+	case models.LinkCheckAll:
+		return candidates
+	case models.LinkCheckInternal:
+		var internal []linkCandidate
+		for _, c := range candidates {
+			if c.IsInternal {
+				internal = append(internal, c)
+			}
+		}
+		return internal
+	case models.LinkCheckSample:
+		if len(candidates) <= opts.LinkCheckLimit {
+			return candidates
+		}
+		sample := make([]linkCandidate, len(candidates))
+		copy(sample, candidates)
+		rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		return sample[:opts.LinkCheckLimit]
+	default:
+		if len(candidates) <= opts.LinkCheckLimit {
+			return candidates
+		}
+		return candidates[:opts.LinkCheckLimit]
+	}
+}
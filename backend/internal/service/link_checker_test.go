@@ -0,0 +1,103 @@
+package service
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"sykell-backend/internal/models"
+)
+
+func testLinkCandidates() []linkCandidate {
+	links := []struct {
+		raw        string
+		isInternal bool
+	}{
+		{"https://example.com/a", true},
+		{"https://other.com/b", false},
+		{"https://example.com/c", true},
+		{"https://another.org/d", false},
+		{"https://www.example.com/e", true},
+	}
+
+	candidates := make([]linkCandidate, len(links))
+	for i, link := range links {
+		u, _ := url.Parse(link.raw)
+		candidates[i] = linkCandidate{URL: u, Key: normalizeLinkKey(u), IsInternal: link.isInternal}
+	}
+	return candidates
+}
+
+func candidateKeys(candidates []linkCandidate) []string {
+	keys := make([]string, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+func TestSelectLinksToCheck(t *testing.T) {
+	t.Setenv("CRAWLER_LINK_CHECK_MODE", models.LinkCheckLimit)
+	t.Setenv("CRAWLER_LINK_CHECK_LIMIT", "2")
+
+	all := candidateKeys(testLinkCandidates())
+
+	tests := []struct {
+		name string
+		opts models.CrawlOptions
+		want []string
+	}{
+		{"all", models.CrawlOptions{LinkCheckMode: models.LinkCheckAll}, all},
+		{"internal only", models.CrawlOptions{LinkCheckMode: models.LinkCheckInternal}, []string{all[0], all[2], all[4]}},
+		{"first links up to the limit", models.CrawlOptions{LinkCheckMode: models.LinkCheckLimit, LinkCheckLimit: 3}, all[:3]},
+		{"limit above the number of links", models.CrawlOptions{LinkCheckMode: models.LinkCheckLimit, LinkCheckLimit: 10}, all},
+		{"mode in any case", models.CrawlOptions{LinkCheckMode: " ALL "}, all},
+		{"default mode and limit", models.CrawlOptions{}, all[:2]},
+		{"default limit", models.CrawlOptions{LinkCheckMode: models.LinkCheckLimit}, all[:2]},
+		{"unknown mode falls back to defaults", models.CrawlOptions{LinkCheckMode: "bogus", LinkCheckLimit: 4}, all[:2]},
+		{"negative limit falls back to defaults", models.CrawlOptions{LinkCheckMode: models.LinkCheckLimit, LinkCheckLimit: -1}, all[:2]},
+		{"sample above the number of links", models.CrawlOptions{LinkCheckMode: models.LinkCheckSample, LinkCheckLimit: 10}, all},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := candidateKeys(selectLinksToCheck(testLinkCandidates(), tt.opts))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectLinksToCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectLinksToCheckSample(t *testing.T) {
+	candidates := testLinkCandidates()
+	opts := models.CrawlOptions{LinkCheckMode: models.LinkCheckSample, LinkCheckLimit: 3}
+
+	isCandidate := make(map[string]bool)
+	for _, c := range candidates {
+		isCandidate[c.Key] = true
+	}
+
+	for i := 0; i < 20; i++ {
+		got := selectLinksToCheck(candidates, opts)
+		if len(got) != 3 {
+			t.Fatalf("selectLinksToCheck() returned %d links, want 3", len(got))
+		}
+
+		seen := make(map[string]bool)
+		for _, c := range got {
+			if !isCandidate[c.Key] {
+				t.Fatalf("selectLinksToCheck() returned %s, which is not a candidate", c.Key)
+			}
+			if seen[c.Key] {
+				t.Fatalf("selectLinksToCheck() returned %s twice", c.Key)
+			}
+			seen[c.Key] = true
+		}
+	}
+
+	// Sampling shuffles a copy, leaving the page's link order alone
+	if got, want := candidateKeys(candidates), candidateKeys(testLinkCandidates()); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates reordered to %v, want %v", got, want)
+	}
+}
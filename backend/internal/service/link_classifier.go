@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+
+	"sykell-backend/internal/models"
+)
+
+// Link schemes that are counted separately from HTTP links
+const (
+	schemeMailto     = "mailto"
+	schemeTel        = "tel"
+	schemeJavascript = "javascript"
+	schemeData       = "data"
+)
+
+// linkScheme returns the lowercased scheme of a raw href value, or an empty
+// string for relative references. It does not rely on url.Parse because
+// tel: and javascript: values routinely contain characters it rejects.
+func linkScheme(href string) string {
+	for i, r := range href {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.'):
+		case i > 0 && r == ':':
+			return strings.ToLower(href[:i])
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// resolveBaseURL returns the URL relative links in the document resolve
+// against. Per the HTML spec only the first <base> element with an href
+// counts, and its href is itself resolved against the document URL.
+func resolveBaseURL(doc *html.Node, documentURL *url.URL) *url.URL {
+	var href string
+	found := false
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if found {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "base" {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					href = strings.TrimSpace(attr.Val)
+					found = true
+					return
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	if !found || href == "" {
+		return documentURL
+	}
+
+	baseURL, err := documentURL.Parse(href)
+	if err != nil {
+		return documentURL
+	}
+
+	// A base URL is only usable for resolution if it is hierarchical
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return documentURL
+	}
+
+	return baseURL
+}
+
+// validateMailto checks that every recipient of a mailto: link is a
+// syntactically valid address.
+func validateMailto(href string) error {
+	target := href[len(schemeMailto)+1:]
+	if i := strings.IndexByte(target, '?'); i >= 0 {
+		target = target[:i]
+	}
+
+	target, err := url.PathUnescape(target)
+	if err != nil {
+		return fmt.Errorf("malformed escape sequence: %v", err)
+	}
+
+	if strings.TrimSpace(target) == "" {
+		return fmt.Errorf("no recipient address")
+	}
+
+	for _, addr := range strings.Split(target, ",") {
+		addr = strings.TrimSpace(addr)
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", addr, err)
+		}
+		// ParseAddress accepts display names; a mailto target must be a bare address
+		if parsed.Name != "" || parsed.Address != addr {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+	}
+
+	return nil
+}
+
+// defaultLinkScope is used for URLs added without an explicit link scope.
+var defaultLinkScope = getEnv("CRAWLER_LINK_SCOPE", models.LinkScopeDomain)
+
+// normalizeCrawlOptions validates client-supplied crawl options and fills in
+// server defaults.
+func normalizeCrawlOptions(opts models.CrawlOptions) (models.CrawlOptions, error) {
+	opts.LinkScope = strings.ToLower(strings.TrimSpace(opts.LinkScope))
+	if opts.LinkScope == "" {
+		opts.LinkScope = defaultLinkScope
+	}
+
+	var domains []string
+	for _, domain := range opts.SameSiteDomains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, ",/: ") {
+			return opts, fmt.Errorf("invalid same-site domain %q", domain)
+		}
+		domains = append(domains, domain)
+	}
+	opts.SameSiteDomains = domains
+
+	switch opts.LinkScope {
+	case models.LinkScopeHost, models.LinkScopeDomain:
+	case models.LinkScopeCustom:
+		if len(opts.SameSiteDomains) == 0 {
+			return opts, fmt.Errorf("link scope %q requires at least one same-site domain", opts.LinkScope)
+		}
+	default:
+		return opts, fmt.Errorf("unknown link scope %q", opts.LinkScope)
+	}
+
+	return normalizeLinkCheckOptions(opts)
+}
+
+// siteMatcher decides whether a link points at the same site as the crawled page.
+type siteMatcher struct {
+	scope   string
+	host    string
+	domain  string
+	domains []string
+}
+
+func newSiteMatcher(pageURL *url.URL, opts models.CrawlOptions) *siteMatcher {
+	scope := opts.LinkScope
+	if scope == "" {
+		scope = defaultLinkScope
+	}
+
+	host := strings.ToLower(pageURL.Hostname())
+	return &siteMatcher{
+		scope:   scope,
+		host:    host,
+		domain:  registrableDomain(host),
+		domains: append([]string{host}, opts.SameSiteDomains...),
+	}
+}
+
+func (m *siteMatcher) isInternal(linkURL *url.URL) bool {
+	host := strings.ToLower(linkURL.Hostname())
+
+	switch m.scope {
+	case models.LinkScopeDomain:
+		return registrableDomain(host) == m.domain
+	case models.LinkScopeCustom:
+		for _, domain := range m.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+		return false
+	default:
+		return host == m.host
+	}
+}
+
+// registrableDomain returns the eTLD+1 of host according to the public
+// suffix list compiled into golang.org/x/net/publicsuffix. Hosts without
+// one, such as IP addresses, localhost or bare public suffixes, are
+// returned unchanged so they only ever match themselves.
+func registrableDomain(host string) string {
+	if host == "" || net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
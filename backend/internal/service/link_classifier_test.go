@@ -0,0 +1,126 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestLinkScheme(t *testing.T) {
+	tests := []struct {
+		href string
+		want string
+	}{
+		{"https://example.com/", "https"},
+		{"HTTP://example.com/", "http"},
+		{"MAILTO:user@example.com", "mailto"},
+		{"tel:+1 (555) 123-4567", "tel"},
+		{"javascript:void(0)", "javascript"},
+		{"data:text/plain,hello", "data"},
+		{"web+app.v-2:thing", "web+app.v-2"},
+		{"/path/to/page", ""},
+		{"page.html", ""},
+		{"//cdn.example.com/lib.js", ""},
+		{"?q=a:b", ""},
+		{"#section:1", ""},
+		{"dir/file:name", ""},
+		{"1http://example.com/", ""},
+		{":foo", ""},
+		{"https", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := linkScheme(tt.href); got != tt.want {
+			t.Errorf("linkScheme(%q) = %q, want %q", tt.href, got, tt.want)
+		}
+	}
+}
+
+func TestResolveBaseURL(t *testing.T) {
+	documentURL, _ := url.Parse("https://example.com/dir/page.html")
+
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"no base", ``, "https://example.com/dir/page.html"},
+		{"absolute", `<base href="https://cdn.example.com/assets/">`, "https://cdn.example.com/assets/"},
+		{"relative to document", `<base href="../other/">`, "https://example.com/other/"},
+		{"root relative", `<base href="/root/">`, "https://example.com/root/"},
+		{"first base wins", `<base href="/first/"><base href="/second/">`, "https://example.com/first/"},
+		{"first base with href wins", `<base target="_blank"><base href="/second/">`, "https://example.com/second/"},
+		{"whitespace trimmed", `<base href="  /trimmed/  ">`, "https://example.com/trimmed/"},
+		{"empty href", `<base href="">`, "https://example.com/dir/page.html"},
+		{"non-hierarchical", `<base href="javascript:alert(1)">`, "https://example.com/dir/page.html"},
+		{"unparseable", `<base href="http://%zz/">`, "https://example.com/dir/page.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader("<html><head>" + tt.head + "</head><body></body></html>"))
+			if err != nil {
+				t.Fatalf("failed to parse document: %v", err)
+			}
+
+			if got := resolveBaseURL(doc, documentURL).String(); got != tt.want {
+				t.Errorf("resolveBaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMailto(t *testing.T) {
+	tests := []struct {
+		href    string
+		wantErr bool
+	}{
+		{"mailto:user@example.com", false},
+		{"mailto:a@example.com,b@example.com", false},
+		{"mailto:a@example.com, b@example.com", false},
+		{"mailto:user@example.com?subject=Hello&body=Hi", false},
+		{"mailto:user%40example.com", false},
+		{"mailto:", true},
+		{"mailto:?subject=Hello", true},
+		{"mailto:%20", true},
+		{"mailto:not-an-address", true},
+		{"mailto:John%20Doe%20%3Cjohn@example.com%3E", true},
+		{"mailto:a@example.com,,b@example.com", true},
+		{"mailto:%zz@example.com", true},
+	}
+
+	for _, tt := range tests {
+		err := validateMailto(tt.href)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateMailto(%q) error = %v, want error %v", tt.href, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"www.example.com", "example.com"},
+		{"a.b.example.com", "example.com"},
+		{"example.co.uk", "example.co.uk"},
+		{"shop.example.co.uk", "example.co.uk"},
+		{"user.github.io", "user.github.io"},
+		{"co.uk", "co.uk"},
+		{"localhost", "localhost"},
+		{"192.168.0.1", "192.168.0.1"},
+		{"::1", "::1"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := registrableDomain(tt.host); got != tt.want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"sykell-backend/internal/repository"
+)
+
+// linkStatusCache remembers link-check outcomes by normalized URL so that
+// links shared by many pages (navigation, footers, social profiles) are only
+// requested once per TTL. Entries live in memory and in MySQL; the memory
+// layer is shared by every CrawlerService in the process and the MySQL layer
+// survives restarts and is shared between processes.
+//
+// Only outcomes with an HTTP status are cached. Network errors are usually
+// transient and are retried on the next crawl.
+type linkStatusCache struct {
+	repo    *repository.LinkStatusRepository
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]repository.LinkStatus
+
+	lastPurge time.Time
+}
+
+var (
+	sharedLinkStatusCache     *linkStatusCache
+	sharedLinkStatusCacheOnce sync.Once
+)
+
+// getLinkStatusCache returns the process-wide cache. It is created lazily
+// because the repository needs an initialized database connection.
+func getLinkStatusCache() *linkStatusCache {
+	sharedLinkStatusCacheOnce.Do(func() {
+		sharedLinkStatusCache = &linkStatusCache{
+			repo:      repository.NewLinkStatusRepository(),
+			ttl:       getEnvDuration("CRAWLER_LINK_CACHE_TTL", time.Hour),
+			entries:   make(map[string]repository.LinkStatus),
+			lastPurge: time.Now(),
+		}
+	})
+	return sharedLinkStatusCache
+}
+
+func (c *linkStatusCache) enabled() bool {
+	return c.ttl > 0
+}
+
+// Get returns the cached status for key if it has not expired.
+func (c *linkStatusCache) Get(key string) (*repository.LinkStatus, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+
+	now := time.Now()
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+	if ok && entry.ExpiresAt.After(now) {
+		return &entry, true
+	}
+
+	status, err := c.repo.Get(key)
+	if err != nil || status == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	c.entries[key] = *status
+	c.mu.Unlock()
+
+	return status, true
+}
+
+// Set stores the outcome of a link check made at checkedAt.
+func (c *linkStatusCache) Set(key string, statusCode int, errorMessage string, checkedAt time.Time) {
+	if !c.enabled() || statusCode == 0 {
+		return
+	}
+
+	status := repository.LinkStatus{
+		URL:          key,
+		StatusCode:   statusCode,
+		ErrorMessage: errorMessage,
+		CheckedAt:    checkedAt,
+		ExpiresAt:    checkedAt.Add(c.ttl),
+	}
+
+	c.mu.Lock()
+	c.entries[key] = status
+	purge := time.Since(c.lastPurge) > c.ttl
+	if purge {
+		c.lastPurge = time.Now()
+		for k, e := range c.entries {
+			if !e.ExpiresAt.After(c.lastPurge) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	c.repo.Set(&status)
+	if purge {
+		c.repo.DeleteExpired()
+	}
+}
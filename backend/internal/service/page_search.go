@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sykell-backend/internal/models"
+)
+
+// snippetLength is roughly how many bytes of page text a body highlight shows.
+const snippetLength = 240
+
+// SearchPages runs a full-text search for terms over the title, meta
+// description and visible text of crawled pages matching filter. Results
+// are ordered by relevance and carry highlighted excerpts.
+func (s *CrawlerService) SearchPages(terms string, filter models.CrawlURLFilter, page, limit int) ([]models.PageSearchResult, int, error) {
+	terms = strings.TrimSpace(terms)
+	if terms == "" {
+		return nil, 0, fmt.Errorf("%w: q is required", ErrInvalidListQuery)
+	}
+
+	results, total, err := s.repo.SearchPages(terms, filter, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	words := searchWords(terms)
+	for i := range results {
+		result := &results[i]
+		result.Highlights = models.PageHighlights{
+			Title:           highlight(result.CrawlURL.Title, words, 0),
+			MetaDescription: highlight(result.MetaDescription, words, 0),
+			Body:            highlight(result.BodyText, words, snippetLength),
+		}
+	}
+
+	return results, total, nil
+}
+
+// searchWords returns the lowercased words of a search, which MySQL matches
+// as whole words.
+func searchWords(terms string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(terms, isNotWordRune) {
+		words[strings.ToLower(word)] = true
+	}
+	return words
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// wordSpan is the byte range of a word in a text.
+type wordSpan struct {
+	start, end int
+}
+
+// matchingWords returns the spans of the words of text that are in words.
+func matchingWords(text string, words map[string]bool) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range text + " " {
+		if isNotWordRune(r) {
+			if start >= 0 && words[strings.ToLower(text[start:i])] {
+				spans = append(spans, wordSpan{start, i})
+			}
+			start = -1
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return spans
+}
+
+// highlight returns text HTML-escaped with the matching words wrapped in
+// <mark> tags, or an empty string if no word matches. If maxLen is set, only
+// the excerpt of about maxLen bytes with the most matches is returned.
+func highlight(text string, words map[string]bool, maxLen int) string {
+	spans := matchingWords(text, words)
+	if len(spans) == 0 {
+		return ""
+	}
+
+	start, end := 0, len(text)
+	if maxLen > 0 && len(text) > maxLen {
+		start, end = excerpt(text, spans, maxLen)
+	}
+
+	var out strings.Builder
+	if start > 0 {
+		out.WriteString("… ")
+	}
+	pos := start
+	for _, span := range spans {
+		if span.start < start || span.end > end {
+			continue
+		}
+		out.WriteString(html.EscapeString(text[pos:span.start]))
+		out.WriteString("<mark>")
+		out.WriteString(html.EscapeString(text[span.start:span.end]))
+		out.WriteString("</mark>")
+		pos = span.end
+	}
+	out.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		out.WriteString(" …")
+	}
+	return out.String()
+}
+
+// excerpt picks the window of about maxLen bytes of text holding the most
+// matches, starting a little before its first match and cut at spaces.
+func excerpt(text string, spans []wordSpan, maxLen int) (int, int) {
+	best, bestCount := 0, 0
+	for i := range spans {
+		count := 0
+		for j := i; j < len(spans) && spans[j].end-spans[i].start <= maxLen; j++ {
+			count++
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+
+	start := spans[best].start - maxLen/4
+	if start <= 0 {
+		start = 0
+	} else if space := strings.IndexByte(text[start:spans[best].start], ' '); space >= 0 {
+		start += space + 1
+	} else {
+		start = spans[best].start
+	}
+
+	end := start + maxLen
+	if end >= len(text) {
+		return start, len(text)
+	}
+	if end < spans[best].end {
+		return start, spans[best].end
+	}
+	if space := strings.LastIndexByte(text[spans[best].end:end], ' '); space >= 0 {
+		end = spans[best].end + space
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	return start, end
+}
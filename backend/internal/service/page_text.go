@@ -0,0 +1,89 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// hiddenElements hold no text a visitor would read.
+var hiddenElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"iframe":   true,
+	"object":   true,
+}
+
+// indexedTextLimit caps the bytes of page text stored for search, so that
+// huge pages do not bloat the index.
+func indexedTextLimit() int {
+	return getEnvInt("CRAWLER_INDEXED_TEXT_LIMIT", 65536)
+}
+
+// extractPageText returns a page's meta description and its visible text
+// with whitespace collapsed, cut to indexedTextLimit bytes.
+func extractPageText(doc *html.Node) (string, string) {
+	var metaDescription string
+	var text strings.Builder
+	limit := indexedTextLimit()
+
+	var f func(n *html.Node, visible bool)
+	f = func(n *html.Node, visible bool) {
+		if n.Type == html.ElementNode {
+			tag := strings.ToLower(n.Data)
+			if tag == "meta" && metaDescription == "" && isMetaDescription(n) {
+				metaDescription = strings.Join(strings.Fields(attrValue(n, "content")), " ")
+			}
+			if hiddenElements[tag] {
+				visible = false
+			}
+		}
+
+		if visible && n.Type == html.TextNode && text.Len() < limit {
+			for _, word := range strings.Fields(n.Data) {
+				if text.Len() > 0 {
+					text.WriteByte(' ')
+				}
+				text.WriteString(word)
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c, visible)
+		}
+	}
+	f(doc, true)
+
+	return metaDescription, truncateUTF8(text.String(), limit)
+}
+
+func isMetaDescription(n *html.Node) bool {
+	name := strings.ToLower(attrValue(n, "name"))
+	property := strings.ToLower(attrValue(n, "property"))
+	return name == "description" || property == "og:description"
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// truncateUTF8 cuts s to at most limit bytes without splitting a character.
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
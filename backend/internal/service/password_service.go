@@ -0,0 +1,358 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+	"sykell-backend/pkg/mail"
+)
+
+var (
+	// ErrPasswordPolicy is wrapped by the errors of passwords that do not
+	// meet the password policy, which name the rules they break.
+	ErrPasswordPolicy = errors.New("password does not meet the password policy")
+	// ErrWrongPassword is returned when changing a password with an
+	// incorrect current one.
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrInvalidResetToken is returned for password reset tokens that are
+	// unknown, expired or already used.
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrNoEmail is returned when a reset link is to be mailed to a user
+	// without an email address.
+	ErrNoEmail = errors.New("user has no email address")
+	// ErrTooManyResetRequests is returned when a client asks for more
+	// password resets than PASSWORD_RESET_LIMIT_PER_IP allows.
+	ErrTooManyResetRequests = errors.New("too many password reset requests, try again later")
+)
+
+// maxPasswordBytes is the most bcrypt hashes; it ignores anything longer.
+const maxPasswordBytes = 72
+
+// PasswordPolicy is the rules new passwords have to meet.
+type PasswordPolicy struct {
+	MinLength        int  `json:"min_length"`
+	MaxBytes         int  `json:"max_bytes"`
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireDigit     bool `json:"require_digit"`
+	RequireSymbol    bool `json:"require_symbol"`
+}
+
+// CurrentPasswordPolicy returns the password policy configured through the
+// PASSWORD_* environment variables.
+func CurrentPasswordPolicy() PasswordPolicy {
+	minLength := getEnvInt("PASSWORD_MIN_LENGTH", 8)
+	if minLength < 1 || minLength > maxPasswordBytes {
+		logger.Sugar().Warnf("Invalid value %d for PASSWORD_MIN_LENGTH, using default 8", minLength)
+		minLength = 8
+	}
+
+	return PasswordPolicy{
+		MinLength:        minLength,
+		MaxBytes:         maxPasswordBytes,
+		RequireUppercase: getEnvBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		RequireLowercase: getEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		RequireDigit:     getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol:    getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+}
+
+// Check returns an error wrapping ErrPasswordPolicy that lists every rule
+// password breaks, or nil if it meets the policy.
+func (p PasswordPolicy) Check(password string) error {
+	var rules []string
+	if utf8.RuneCountInString(password) < p.MinLength {
+		rules = append(rules, fmt.Sprintf("be at least %d characters long", p.MinLength))
+	}
+	if len(password) > p.MaxBytes {
+		rules = append(rules, fmt.Sprintf("be at most %d bytes long", p.MaxBytes))
+	}
+	if p.RequireUppercase && !strings.ContainsFunc(password, unicode.IsUpper) {
+		rules = append(rules, "contain an uppercase letter")
+	}
+	if p.RequireLowercase && !strings.ContainsFunc(password, unicode.IsLower) {
+		rules = append(rules, "contain a lowercase letter")
+	}
+	if p.RequireDigit && !strings.ContainsFunc(password, unicode.IsDigit) {
+		rules = append(rules, "contain a digit")
+	}
+	if p.RequireSymbol && !strings.ContainsFunc(password, isSymbol) {
+		rules = append(rules, "contain a symbol")
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: it must %s", ErrPasswordPolicy, strings.Join(rules, ", "))
+}
+
+func isSymbol(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// hashPassword checks password against the policy and returns its bcrypt
+// hash.
+func hashPassword(password string) (string, error) {
+	if err := CurrentPasswordPolicy().Check(password); err != nil {
+		return "", err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to hash password: %v", err)
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// AdminResetPasswordRequest sets a user's password, or leaves Password empty
+// to mail the user a reset link instead.
+type AdminResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+func passwordResetRepo() *repository.PasswordResetRepository {
+	return repository.NewPasswordResetRepository()
+}
+
+func passwordResetTTL() time.Duration {
+	return getEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+var (
+	resetThrottlesOnce sync.Once
+	resetsPerEmail     *requestThrottle
+	resetsPerIP        *requestThrottle
+)
+
+// resetThrottles returns the limits on password reset requests per email
+// address and per client IP, within PASSWORD_RESET_LIMIT_WINDOW.
+func resetThrottles() (perEmail, perIP *requestThrottle) {
+	resetThrottlesOnce.Do(func() {
+		window := getEnvDuration("PASSWORD_RESET_LIMIT_WINDOW", time.Hour)
+		perEmail := getEnvInt("PASSWORD_RESET_LIMIT_PER_EMAIL", 3)
+		if perEmail < 1 {
+			perEmail = 1
+		}
+		perIP := getEnvInt("PASSWORD_RESET_LIMIT_PER_IP", 10)
+		if perIP < 1 {
+			perIP = 1
+		}
+		resetsPerEmail = newRequestThrottle(perEmail, window)
+		resetsPerIP = newRequestThrottle(perIP, window)
+	})
+	return resetsPerEmail, resetsPerIP
+}
+
+var (
+	mailSenderOnce sync.Once
+	mailSenderImpl mail.Sender
+)
+
+// mailSender returns the sender for outgoing mail: SMTP if SMTP_HOST is
+// set, or else the log. Logged mail leaves out its body, which carries
+// live reset tokens, unless MAIL_LOG_BODIES is set for local development.
+func mailSender() mail.Sender {
+	mailSenderOnce.Do(func() {
+		host := getEnv("SMTP_HOST", "")
+		if host == "" {
+			logger.Sugar().Warn("SMTP_HOST is not set, mail is written to the log instead of being sent")
+			mailSenderImpl = mail.Log{Bodies: getEnvBool("MAIL_LOG_BODIES", false)}
+			return
+		}
+
+		mailSenderImpl = &mail.SMTP{
+			Host:     host,
+			Port:     getEnv("SMTP_PORT", "587"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "no-reply@localhost"),
+		}
+	})
+	return mailSenderImpl
+}
+
+// ChangePassword replaces a user's password after checking the current
+// one. It ends all of the user's sessions, including the one of the access
+// token jti, and starts a new one.
+func ChangePassword(userID int, jti string, expiresAt time.Time, req ChangePasswordRequest) (*Session, error) {
+	current, err := userRepo().GetPasswordHash(userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" || bcrypt.CompareHashAndPassword([]byte(current), []byte(req.CurrentPassword)) != nil {
+		return nil, ErrWrongPassword
+	}
+
+	if err := setPassword(userID, req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := tokenRepo().RevokeAccessToken(jti, userID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepo().GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return startSession(user)
+}
+
+// AdminResetPassword ends all sessions of a user and either sets the given
+// password or, if it is empty, clears the password and mails the user a
+// reset link.
+func AdminResetPassword(id int, req AdminResetPasswordRequest) error {
+	user, err := userRepo().GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if req.Password != "" {
+		return setPassword(id, req.Password)
+	}
+
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if err := userRepo().SetPassword(id, ""); err != nil {
+		return err
+	}
+	if err := tokenRepo().RevokeUser(id); err != nil {
+		return err
+	}
+	return sendPasswordReset(user)
+}
+
+// ForgotPassword mails a reset link to the user with the given email
+// address on behalf of the client at ip. Whether there is such a user is
+// not revealed: the mail is sent in the background and unknown addresses
+// are ignored. A client over its limit gets ErrTooManyResetRequests; an
+// address over its limit is ignored silently, since refusing it would
+// reveal that it was asked for before.
+func ForgotPassword(email, ip string) error {
+	perEmail, perIP := resetThrottles()
+	if !perIP.Allow(ip) {
+		return ErrTooManyResetRequests
+	}
+	if !perEmail.Allow(strings.ToLower(strings.TrimSpace(email))) {
+		logger.Sugar().Warnf("Too many password reset requests for one address, ignoring request from %s", ip)
+		return nil
+	}
+
+	go func() {
+		user, err := userRepo().GetByEmail(email)
+		if err != nil || user == nil {
+			return
+		}
+		if err := sendPasswordReset(user); err != nil {
+			logger.Sugar().Errorf("Failed to send password reset to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and ends all of the
+// user's sessions. The token works once.
+func ResetPassword(req ResetPasswordRequest) error {
+	// Check the policy first so that a rejected password keeps the token
+	if err := CurrentPasswordPolicy().Check(req.Password); err != nil {
+		return err
+	}
+
+	token, err := passwordResetRepo().Use(hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return ErrInvalidResetToken
+	}
+
+	return setPassword(token.UserID, req.Password)
+}
+
+// setPassword stores a new password for a user, ends their sessions and
+// invalidates reset links sent before.
+func setPassword(userID int, password string) error {
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := userRepo().SetPassword(userID, hashed); err != nil {
+		return err
+	}
+	if err := passwordResetRepo().DeleteForUser(userID); err != nil {
+		return err
+	}
+	return tokenRepo().RevokeUser(userID)
+}
+
+// sendPasswordReset mails user a token that resets their password, as a
+// link to PASSWORD_RESET_URL if that is set. Tokens sent before stop
+// working, so only the latest mail can be used.
+func sendPasswordReset(user *repository.User) error {
+	passwordResetRepo().DeleteExpired()
+	if err := passwordResetRepo().DeleteForUser(user.ID); err != nil {
+		return err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	ttl := passwordResetTTL()
+	if err := passwordResetRepo().Create(user.ID, hashToken(token), time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	instructions := "Use this token to choose a new password:\n\n" + token
+	if resetURL := getEnv("PASSWORD_RESET_URL", ""); resetURL != "" {
+		link, err := url.Parse(resetURL)
+		if err != nil {
+			return fmt.Errorf("invalid PASSWORD_RESET_URL: %w", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		instructions = "Follow this link to choose a new password:\n\n" + link.String()
+	}
+
+	return mailSender().Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. %s\n\n"+
+			"The reset expires in %s. If you did not request it, you can ignore this message.\n",
+			user.Name, instructions, ttl),
+	})
+}
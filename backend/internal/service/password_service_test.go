@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	lenient := PasswordPolicy{MinLength: 8, MaxBytes: maxPasswordBytes}
+	strict := PasswordPolicy{
+		MinLength:        10,
+		MaxBytes:         maxPasswordBytes,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		// wantRules are the broken rules the error lists, in order
+		wantRules []string
+	}{
+		{"long enough", lenient, "abcdefgh", nil},
+		{"too short", lenient, "abcdefg", []string{"be at least 8 characters long"}},
+		{"empty", lenient, "", []string{"be at least 8 characters long"}},
+		{"length counts characters", lenient, "ääääääää", nil},
+		{"at the byte limit", lenient, strings.Repeat("a", maxPasswordBytes), nil},
+		{"over the byte limit", lenient, strings.Repeat("a", maxPasswordBytes+1), []string{"be at most 72 bytes long"}},
+		{"multi-byte over the byte limit", lenient, strings.Repeat("ä", 37), []string{"be at most 72 bytes long"}},
+		{"meets every rule", strict, "Abcdefgh1!", nil},
+		{"unicode letters count", strict, "Äbcdefgh1€", nil},
+		{"missing uppercase", strict, "abcdefgh1!", []string{"contain an uppercase letter"}},
+		{"missing lowercase", strict, "ABCDEFGH1!", []string{"contain a lowercase letter"}},
+		{"missing digit", strict, "Abcdefghi!", []string{"contain a digit"}},
+		{"missing symbol", strict, "Abcdefghi1", []string{"contain a symbol"}},
+		{"breaks every rule", strict, "", []string{
+			"be at least 10 characters long",
+			"contain an uppercase letter",
+			"contain a lowercase letter",
+			"contain a digit",
+			"contain a symbol",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password)
+			if tt.wantRules == nil {
+				if err != nil {
+					t.Errorf("Check() = %v, want nil", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrPasswordPolicy) {
+				t.Fatalf("Check() = %v, want an error wrapping ErrPasswordPolicy", err)
+			}
+			if want := "it must " + strings.Join(tt.wantRules, ", "); !strings.HasSuffix(err.Error(), want) {
+				t.Errorf("Check() = %q, want it to end in %q", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+)
+
+var (
+	// ErrProjectNotFound is returned for projects that do not exist or that
+	// the user is not a member of.
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrProjectForbidden is returned when a member who is not an owner
+	// tries to manage a project.
+	ErrProjectForbidden = errors.New("only project owners can manage the project")
+	// ErrProjectMemberNotFound is returned for users who are not members of
+	// the project, or do not exist.
+	ErrProjectMemberNotFound = errors.New("project member not found")
+	// ErrLastProjectOwner is returned when removing or demoting the only
+	// owner of a project.
+	ErrLastProjectOwner = errors.New("a project needs at least one owner")
+)
+
+// defaultProjectName names the project created for users who add URLs
+// before they have joined any project.
+const defaultProjectName = "Personal"
+
+// ProjectService manages projects and their members, and decides which
+// projects a user's crawler requests may touch.
+type ProjectService struct {
+	repo *repository.ProjectRepository
+}
+
+var (
+	defaultProjectService     *ProjectService
+	defaultProjectServiceOnce sync.Once
+)
+
+// DefaultProjectService returns the process-wide ProjectService.
+func DefaultProjectService() *ProjectService {
+	defaultProjectServiceOnce.Do(func() {
+		defaultProjectService = NewProjectService()
+	})
+	return defaultProjectService
+}
+
+func NewProjectService() *ProjectService {
+	return &ProjectService{
+		repo: repository.NewProjectRepository(),
+	}
+}
+
+// CreateProject creates a project with userID as its owner.
+func (s *ProjectService) CreateProject(userID int, req models.CreateProjectRequest) (*models.Project, error) {
+	return s.repo.Create(strings.TrimSpace(req.Name), userID)
+}
+
+// GetProjects returns the projects userID is a member of.
+func (s *ProjectService) GetProjects(userID int) ([]models.Project, error) {
+	return s.repo.GetAllForMember(userID)
+}
+
+func (s *ProjectService) GetProject(userID, id int) (*models.Project, error) {
+	project, err := s.repo.GetForMember(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, ErrProjectNotFound
+	}
+	return project, nil
+}
+
+// DeleteProject deletes a project and everything it owns. Only owners may
+// delete a project.
+func (s *ProjectService) DeleteProject(userID, id int) error {
+	if err := s.requireOwner(userID, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// GetMembers returns the members of a project userID is a member of.
+func (s *ProjectService) GetMembers(userID, id int) ([]models.ProjectMember, error) {
+	if _, err := s.role(userID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMembers(id)
+}
+
+// SetMember adds a user to a project, or changes a member's role. Members
+// are added as plain members unless req asks for an owner. Only owners may
+// change the members of a project.
+func (s *ProjectService) SetMember(userID, id int, req models.AddProjectMemberRequest) ([]models.ProjectMember, error) {
+	if err := s.requireOwner(userID, id); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.UserExists(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrProjectMemberNotFound
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.ProjectRoleMember
+	}
+	if role != models.ProjectRoleOwner {
+		if err := s.keepAnOwner(id, req.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.SetMember(id, req.UserID, role); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMembers(id)
+}
+
+// RemoveMember takes memberID out of a project. Owners may remove anyone;
+// other members may only leave.
+func (s *ProjectService) RemoveMember(userID, id, memberID int) error {
+	role, err := s.role(userID, id)
+	if err != nil {
+		return err
+	}
+	if memberID != userID && role != models.ProjectRoleOwner {
+		return ErrProjectForbidden
+	}
+
+	memberRole, err := s.repo.GetMemberRole(id, memberID)
+	if err != nil {
+		return err
+	}
+	if memberRole == "" {
+		return ErrProjectMemberNotFound
+	}
+	if err := s.keepAnOwner(id, memberID); err != nil {
+		return err
+	}
+
+	return s.repo.RemoveMember(id, memberID)
+}
+
+// keepAnOwner fails if memberID is the only owner of a project, so that
+// a project cannot be left without anyone to manage it.
+func (s *ProjectService) keepAnOwner(id, memberID int) error {
+	role, err := s.repo.GetMemberRole(id, memberID)
+	if err != nil {
+		return err
+	}
+	if role != models.ProjectRoleOwner {
+		return nil
+	}
+
+	owners, err := s.repo.CountOwners(id)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastProjectOwner
+	}
+	return nil
+}
+
+// role returns userID's role in a project, or ErrProjectNotFound if userID
+// is not a member.
+func (s *ProjectService) role(userID, id int) (string, error) {
+	role, err := s.repo.GetMemberRole(id, userID)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", ErrProjectNotFound
+	}
+	return role, nil
+}
+
+func (s *ProjectService) requireOwner(userID, id int) error {
+	role, err := s.role(userID, id)
+	if err != nil {
+		return err
+	}
+	if role != models.ProjectRoleOwner {
+		return ErrProjectForbidden
+	}
+	return nil
+}
+
+// CheckScope fails with ErrProjectNotFound if scope names a project its
+// user is not a member of.
+func (s *ProjectService) CheckScope(scope models.ProjectScope) error {
+	if scope.ProjectID == 0 {
+		return nil
+	}
+	_, err := s.role(scope.UserID, scope.ProjectID)
+	return err
+}
+
+// ResolveProject returns the project new URLs, schedules, imports and
+// webhooks in scope are added to: the project scope names, or else the
+// user's oldest project. Users without a project get a personal one.
+func (s *ProjectService) ResolveProject(scope models.ProjectScope) (int, error) {
+	if scope.ProjectID > 0 {
+		if err := s.CheckScope(scope); err != nil {
+			return 0, err
+		}
+		return scope.ProjectID, nil
+	}
+
+	ids, err := s.repo.GetProjectIDs(scope.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+
+	project, err := s.repo.Create(defaultProjectName, scope.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return project.ID, nil
+}
+
+// ProjectIDs returns the IDs of the projects in scope.
+func (s *ProjectService) ProjectIDs(scope models.ProjectScope) ([]int, error) {
+	if scope.ProjectID > 0 {
+		if err := s.CheckScope(scope); err != nil {
+			return nil, err
+		}
+		return []int{scope.ProjectID}, nil
+	}
+	return s.repo.GetProjectIDs(scope.UserID)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// requestThrottle allows at most limit requests per key within a sliding
+// window. It is kept in memory, so with several backend instances each
+// enforces the limit on its own.
+type requestThrottle struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	requests  map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newRequestThrottle(limit int, window time.Duration) *requestThrottle {
+	return &requestThrottle{
+		limit:    limit,
+		window:   window,
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// Allow records a request for key and reports whether it is within the
+// limit. Refused requests are not recorded, so a client that keeps trying
+// is let through again once its earlier requests leave the window.
+func (t *requestThrottle) Allow(key string) bool {
+	return t.allow(key, time.Now())
+}
+
+func (t *requestThrottle) allow(key string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.window)
+
+	// Forget keys that have been quiet for a whole window
+	if now.Sub(t.lastSweep) > t.window {
+		for k, times := range t.requests {
+			if !times[len(times)-1].After(cutoff) {
+				delete(t.requests, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	times := t.requests[key]
+	recent := times[:0]
+	for _, at := range times {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) >= t.limit {
+		t.requests[key] = recent
+		return false
+	}
+	t.requests[key] = append(recent, now)
+	return true
+}
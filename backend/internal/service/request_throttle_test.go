@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestThrottle(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	type request struct {
+		key   string
+		after time.Duration
+		want  bool
+	}
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{"within the limit", []request{
+			{"a", 0, true},
+			{"a", time.Minute, true},
+		}},
+		{"over the limit", []request{
+			{"a", 0, true},
+			{"a", time.Second, true},
+			{"a", 2 * time.Second, false},
+		}},
+		{"keys are separate", []request{
+			{"a", 0, true},
+			{"a", 0, true},
+			{"b", 0, true},
+			{"a", 0, false},
+		}},
+		{"window slides", []request{
+			{"a", 0, true},
+			{"a", 30 * time.Minute, true},
+			{"a", 59 * time.Minute, false},
+			{"a", time.Hour + time.Second, true},
+			{"a", time.Hour + 2*time.Second, false},
+			{"a", 90*time.Minute + time.Second, true},
+		}},
+		{"refused requests are not recorded", []request{
+			{"a", 0, true},
+			{"a", 0, true},
+			{"a", 50 * time.Minute, false},
+			{"a", 55 * time.Minute, false},
+			{"a", time.Hour + time.Second, true},
+		}},
+		{"quiet keys are forgotten", []request{
+			{"a", 0, true},
+			{"a", 0, true},
+			{"b", 2 * time.Hour, true},
+			{"a", 2 * time.Hour, true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := newRequestThrottle(2, time.Hour)
+			for i, r := range tt.requests {
+				if got := throttle.allow(r.key, start.Add(r.after)); got != r.want {
+					t.Fatalf("request %d for %q after %s allowed = %v, want %v", i, r.key, r.after, got, r.want)
+				}
+			}
+		})
+	}
+}
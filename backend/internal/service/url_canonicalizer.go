@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const defaultTrackingParams = "utm_*,gclid,dclid,fbclid,msclkid,yclid,igshid,mc_cid,mc_eid,_ga,_gl,ref_src"
+
+// trackingParams returns the query parameters stripped during canonicalization.
+// Entries ending in "*" match any parameter with that prefix.
+func trackingParams() []string {
+	var params []string
+	for _, p := range strings.Split(getEnv("CRAWLER_TRACKING_PARAMS", defaultTrackingParams), ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+func isTrackingParam(key string, params []string) bool {
+	key = strings.ToLower(key)
+	for _, p := range params {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalizeURL returns the form used to detect duplicate submissions:
+// the scheme and host are lowercased, internationalized hosts are converted
+// to punycode, default ports and fragments are removed, tracking parameters
+// are stripped and the remaining query parameters are sorted by name.
+func canonicalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("URL must use http or https scheme")
+	}
+
+	hostname := u.Hostname()
+	if hostname == "" {
+		return "", fmt.Errorf("URL must include a host")
+	}
+
+	if net.ParseIP(hostname) == nil {
+		hostname, err = idna.Lookup.ToASCII(strings.TrimSuffix(hostname, "."))
+		if err != nil {
+			return "", fmt.Errorf("invalid host: %v", err)
+		}
+	}
+	hostname = strings.ToLower(hostname)
+	if strings.Contains(hostname, ":") {
+		hostname = "[" + hostname + "]"
+	}
+
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = hostname
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if u.RawQuery != "" {
+		params := trackingParams()
+		query := u.Query()
+		for key := range query {
+			if isTrackingParam(key, params) {
+				query.Del(key)
+			}
+		}
+		// Encode sorts by key
+		u.RawQuery = query.Encode()
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    string
+		wantErr bool
+	}{
+		{"lowercases scheme and host", "HTTP://Example.COM", "http://example.com/", false},
+		{"keeps path case", "https://example.com/Path/To", "https://example.com/Path/To", false},
+		{"trims whitespace", "  https://example.com/a  ", "https://example.com/a", false},
+		{"drops default https port", "https://example.com:443/a", "https://example.com/a", false},
+		{"drops default http port", "http://example.com:80/", "http://example.com/", false},
+		{"keeps other ports", "http://example.com:8080/a", "http://example.com:8080/a", false},
+		{"keeps https port 80", "https://example.com:80/", "https://example.com:80/", false},
+		{"drops fragment", "https://example.com/a#section", "https://example.com/a", false},
+		{"drops empty query", "https://example.com/?", "https://example.com/", false},
+		{"sorts query", "https://example.com/?b=2&a=1&c=3", "https://example.com/?a=1&b=2&c=3", false},
+		{"strips tracking params", "https://example.com/?utm_source=x&id=1&gclid=y&UTM_Medium=z", "https://example.com/?id=1", false},
+		{"strips query of tracking params only", "https://example.com/p?fbclid=abc", "https://example.com/p", false},
+		{"converts IDN host to punycode", "https://Bücher.de/", "https://xn--bcher-kva.de/", false},
+		{"drops trailing dot", "https://example.com./", "https://example.com/", false},
+		{"keeps IPv4 host", "http://192.168.0.1:80/x", "http://192.168.0.1/x", false},
+		{"keeps IPv6 brackets", "http://[::1]:80/", "http://[::1]/", false},
+		{"rejects other schemes", "ftp://example.com/", "", true},
+		{"rejects mailto", "mailto:user@example.com", "", true},
+		{"rejects missing host", "https:///path", "", true},
+		{"rejects relative URL", "/path", "", true},
+		{"rejects unparseable URL", "https://example.com/%zz", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalizeURL(tt.rawURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("canonicalizeURL(%q) error = %v, want error %v", tt.rawURL, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("canonicalizeURL(%q) = %q, want %q", tt.rawURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeURLTrackingParamsFromEnv(t *testing.T) {
+	t.Setenv("CRAWLER_TRACKING_PARAMS", " Ref , sess_* ,")
+
+	got, err := canonicalizeURL("https://example.com/?ref=a&sess_id=b&utm_source=c&session=d")
+	if err != nil {
+		t.Fatalf("canonicalizeURL() error = %v", err)
+	}
+	if want := "https://example.com/?session=d&utm_source=c"; got != want {
+		t.Errorf("canonicalizeURL() = %q, want %q", got, want)
+	}
+}
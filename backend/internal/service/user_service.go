@@ -5,25 +5,51 @@ import (
 	"os"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 
+	"sykell-backend/internal/models"
 	"sykell-backend/internal/repository"
 	"sykell-backend/pkg/logger"
 )
 
-var userRepo = repository.NewUserRepository()
+var (
+	// ErrUserNotFound is returned when assigning a role to an unknown user.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrLastAdmin is returned when demoting or deleting the only admin.
+	ErrLastAdmin = errors.New("at least one admin is required")
+)
+
+// userRepo returns the user repository. Package variables are initialized
+// before main opens the database connection, so it is created on each call.
+func userRepo() *repository.UserRepository {
+	return repository.NewUserRepository()
+}
+
+// defaultUserRole is the role of newly registered users. Registering never
+// makes anyone an admin unless DEFAULT_USER_ROLE says so; the first admin is
+// the one named by BOOTSTRAP_ADMIN_EMAIL.
+func defaultUserRole() string {
+	role := getEnv("DEFAULT_USER_ROLE", models.RoleViewer)
+	for _, known := range models.Roles {
+		if role == known {
+			return role
+		}
+	}
+	logger.Sugar().Warnf("Invalid value %q for DEFAULT_USER_ROLE, using default %s", role, models.RoleViewer)
+	return models.RoleViewer
+}
 
 type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func FetchUsers() ([]User, error) {
-	repoUsers, err := userRepo.GetAll()
+	repoUsers, err := userRepo().GetAll()
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +61,7 @@ func FetchUsers() ([]User, error) {
 			ID:        repoUser.ID,
 			Name:      repoUser.Name,
 			Email:     repoUser.Email,
+			Role:      repoUser.Role,
 			CreatedAt: repoUser.CreatedAt,
 			UpdatedAt: repoUser.UpdatedAt,
 		}
@@ -44,9 +71,9 @@ func FetchUsers() ([]User, error) {
 }
 
 type CreateUserRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=255"`
+	Email    string `json:"email" validate:"omitempty,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 func CreateUser(req CreateUserRequest) (User, error) {
@@ -54,18 +81,12 @@ func CreateUser(req CreateUserRequest) (User, error) {
 		return User{}, errors.New("name is required")
 	}
 
-	// Hash password if provided
-	var hashedPassword string
-	if req.Password != "" {
-		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			logger.Sugar().Errorf("Failed to hash password: %v", err)
-			return User{}, err
-		}
-		hashedPassword = string(hashed)
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		return User{}, err
 	}
 
-	repoUser, err := userRepo.Create(req.Name, req.Email, hashedPassword)
+	repoUser, err := userRepo().Create(req.Name, req.Email, hashedPassword, defaultUserRole())
 	if err != nil {
 		return User{}, err
 	}
@@ -74,54 +95,44 @@ func CreateUser(req CreateUserRequest) (User, error) {
 		ID:        repoUser.ID,
 		Name:      repoUser.Name,
 		Email:     repoUser.Email,
+		Role:      repoUser.Role,
 		CreatedAt: repoUser.CreatedAt,
 		UpdatedAt: repoUser.UpdatedAt,
 	}, nil
 }
 
 type Credentials struct {
-	Name     string `json:"name"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
-func Authenticate(creds Credentials) (string, error) {
+// Authenticate checks a user's credentials and starts a session for them.
+func Authenticate(creds Credentials) (*Session, error) {
 	if creds.Name == "" {
-		return "", errors.New("name is required")
+		return nil, errors.New("name is required")
 	}
 
 	// Get user from database
-	user, err := userRepo.GetByName(creds.Name)
+	user, err := userRepo().GetByName(creds.Name)
 	if err != nil {
 		logger.Sugar().Errorf("Failed to get user: %v", err)
-		return "", errors.New("authentication failed")
+		return nil, errors.New("authentication failed")
 	}
 
 	if user == nil {
-		return "", errors.New("user not found")
+		return nil, errors.New("user not found")
 	}
 
-	// Verify password if provided
-	if creds.Password != "" && user.Password != "" {
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password))
-		if err != nil {
-			return "", errors.New("invalid credentials")
-		}
+	// Users without a password cannot log in until it is reset
+	if user.Password == "" {
+		return nil, errors.New("invalid credentials")
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"name":    user.Name,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
-	})
-
-	secret := getJWTSecret()
-	signed, err := token.SignedString(secret)
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password))
 	if err != nil {
-		logger.Sugar().Errorf("JWT signing error: %v", err)
-		return "", err
+		return nil, errors.New("invalid credentials")
 	}
 
-	return signed, nil
+	return startSession(user)
 }
 
 func getJWTSecret() []byte {
@@ -133,12 +144,12 @@ func getJWTSecret() []byte {
 }
 
 type UpdateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name  string `json:"name" validate:"max=255"`
+	Email string `json:"email" validate:"omitempty,email"`
 }
 
 func GetUserByID(id int) (*User, error) {
-	repoUser, err := userRepo.GetByID(id)
+	repoUser, err := userRepo().GetByID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -151,6 +162,7 @@ func GetUserByID(id int) (*User, error) {
 		ID:        repoUser.ID,
 		Name:      repoUser.Name,
 		Email:     repoUser.Email,
+		Role:      repoUser.Role,
 		CreatedAt: repoUser.CreatedAt,
 		UpdatedAt: repoUser.UpdatedAt,
 	}, nil
@@ -161,7 +173,7 @@ func UpdateUser(id int, req UpdateUserRequest) (*User, error) {
 		return nil, errors.New("name is required")
 	}
 
-	repoUser, err := userRepo.Update(id, req.Name, req.Email)
+	repoUser, err := userRepo().Update(id, req.Name, req.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -174,11 +186,85 @@ func UpdateUser(id int, req UpdateUserRequest) (*User, error) {
 		ID:        repoUser.ID,
 		Name:      repoUser.Name,
 		Email:     repoUser.Email,
+		Role:      repoUser.Role,
 		CreatedAt: repoUser.CreatedAt,
 		UpdatedAt: repoUser.UpdatedAt,
 	}, nil
 }
 
+// DeleteUser deletes a user and ends their sessions, unless they are the
+// only admin.
 func DeleteUser(id int) error {
-	return userRepo.Delete(id)
+	if err := keepAnAdmin(id); err != nil {
+		return err
+	}
+	// Deleting the user removes their refresh tokens, so their access
+	// tokens have to be revoked first
+	if err := tokenRepo().RevokeUser(id); err != nil {
+		return err
+	}
+	deleted, err := userRepo().Delete(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// AssignRole changes a user's role. A user who loses privileges is logged
+// out everywhere, so that tokens issued with the old role stop working; a
+// promoted user gets the new role with their next refresh.
+func AssignRole(id int, role string) (*User, error) {
+	previous, ok, err := userRepo().SetRole(id, role)
+	if err != nil {
+		return nil, err
+	}
+	if previous == "" {
+		return nil, ErrUserNotFound
+	}
+	if !ok {
+		return nil, ErrLastAdmin
+	}
+
+	if roleRank(role) > roleRank(previous) {
+		if err := tokenRepo().RevokeUser(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return GetUserByID(id)
+}
+
+// roleRank returns the position of role in models.Roles, where lower ranks
+// are more privileged.
+func roleRank(role string) int {
+	for i, known := range models.Roles {
+		if role == known {
+			return i
+		}
+	}
+	return len(models.Roles)
+}
+
+// keepAnAdmin fails if id is the only admin, so that someone can always
+// assign roles.
+func keepAnAdmin(id int) error {
+	user, err := userRepo().GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Role != models.RoleAdmin {
+		return nil
+	}
+
+	admins, err := userRepo().CountByRole(models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
 }
\ No newline at end of file
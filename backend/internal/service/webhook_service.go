@@ -0,0 +1,384 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"sykell-backend/internal/models"
+	"sykell-backend/internal/repository"
+	"sykell-backend/pkg/logger"
+)
+
+// ErrWebhookNotFound is returned for operations on an unknown subscription.
+var ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+// webhookEventTypes are the crawl events that can be subscribed to.
+var webhookEventTypes = []string{models.EventCrawlCompleted, models.EventCrawlFailed}
+
+// webhookBatchSize is how many due deliveries are claimed per delivery run,
+// and webhookConcurrency how many of them are sent at once.
+const (
+	webhookBatchSize   = 50
+	webhookConcurrency = 10
+)
+
+// ErrWebhookHostNotAllowed is returned for subscription URLs whose host is
+// loopback, private or otherwise not on the public internet.
+var ErrWebhookHostNotAllowed = errors.New("webhook URL must point to a public host")
+
+// WebhookService manages webhook subscriptions and delivers crawl events to
+// them. Events are first written to the webhook_deliveries outbox, which
+// CrawlerJobProcessor drains periodically, so deliveries survive restarts
+// and failed ones are retried with exponential backoff.
+type WebhookService struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+	retry  retryPolicy
+
+	// Subscriptions may point to private addresses, for local development
+	allowPrivateHosts bool
+}
+
+var (
+	defaultWebhookService     *WebhookService
+	defaultWebhookServiceOnce sync.Once
+)
+
+// DefaultWebhookService returns the process-wide WebhookService.
+func DefaultWebhookService() *WebhookService {
+	defaultWebhookServiceOnce.Do(func() {
+		defaultWebhookService = NewWebhookService()
+	})
+	return defaultWebhookService
+}
+
+func NewWebhookService() *WebhookService {
+	allowPrivateHosts := getEnvBool("WEBHOOK_ALLOW_PRIVATE_HOSTS", false)
+
+	// Every connection, including those of redirects, is checked once the
+	// host is resolved, so a public name that resolves to a private address
+	// is caught too. A proxy would connect on the service's behalf, so none
+	// is used while the check applies.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateHosts {
+		dialer := &net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: checkWebhookDial,
+		}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+
+	return &WebhookService{
+		repo: repository.NewWebhookRepository(),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+		},
+		retry: retryPolicy{
+			MaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			BaseDelay:   getEnvDuration("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second),
+			MaxDelay:    getEnvDuration("WEBHOOK_RETRY_MAX_DELAY", time.Hour),
+		},
+		allowPrivateHosts: allowPrivateHosts,
+	}
+}
+
+// CreateSubscription validates req and stores a new active subscription to
+// the crawl events of projectID. Without event types it subscribes to every
+// crawl event; without a secret a random one is generated. The returned
+// subscription includes the secret.
+func (s *WebhookService) CreateSubscription(projectID int, req models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	target := strings.TrimSpace(req.URL)
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if !s.allowPrivateHosts {
+		if err := checkWebhookHost(parsed.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	eventTypes, err := normalizeEventTypes(req.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := strings.TrimSpace(req.Secret)
+	if secret == "" {
+		secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.CreateSubscription(&models.WebhookSubscription{
+		ProjectID:  projectID,
+		URL:        target,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+	})
+}
+
+// GetSubscriptions returns the subscriptions in scope, without their
+// secrets.
+func (s *WebhookService) GetSubscriptions(scope models.ProjectScope) ([]models.WebhookSubscription, error) {
+	subscriptions, err := s.repo.GetSubscriptions(scope)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription removes a subscription together with its delivery log.
+func (s *WebhookService) DeleteSubscription(scope models.ProjectScope, id int) error {
+	if _, err := s.getSubscription(scope, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteSubscription(id)
+}
+
+// GetDeliveries returns a page of a subscription's delivery log.
+func (s *WebhookService) GetDeliveries(scope models.ProjectScope, id, page, limit int) ([]models.WebhookDelivery, int, error) {
+	if _, err := s.getSubscription(scope, id); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetDeliveries(id, limit, (page-1)*limit)
+}
+
+func (s *WebhookService) getSubscription(scope models.ProjectScope, id int) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscription(scope, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return subscription, nil
+}
+
+// EnqueueCrawlEvent writes a delivery of the event to the outbox of every
+// subscription to eventType in the URL's project. Failures are logged; they
+// never fail the crawl.
+func (s *WebhookService) EnqueueCrawlEvent(eventType string, crawlURL *models.CrawlURL) {
+	payload, err := json.Marshal(models.WebhookPayload{
+		Event:     eventType,
+		Timestamp: time.Now().UTC(),
+		CrawlURL:  *crawlURL,
+	})
+	if err != nil {
+		logger.Sugar().Errorf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	if _, err := s.repo.EnqueueDeliveries(crawlURL.ProjectID, eventType, string(payload)); err != nil {
+		logger.Sugar().Errorf("Failed to enqueue %s webhooks for URL %d: %v", eventType, crawlURL.ID, err)
+	}
+}
+
+// DeliverDue sends the deliveries whose next attempt is due,
+// webhookConcurrency at a time, and returns once all of them have been
+// attempted. The batch is claimed for as long as sending it can take plus a
+// margin, so that with several backend instances a delivery is sent by only
+// one of them.
+func (s *WebhookService) DeliverDue() {
+	rounds := (webhookBatchSize + webhookConcurrency - 1) / webhookConcurrency
+	lease := time.Duration(rounds)*s.client.Timeout + time.Minute
+
+	deliveries, subscriptions, err := s.repo.ClaimDueDeliveries(webhookBatchSize, lease)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to claim webhook deliveries: %v", err)
+		return
+	}
+
+	semaphore := make(chan struct{}, webhookConcurrency)
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		subscription := subscriptions[delivery.SubscriptionID]
+		if subscription == nil {
+			continue
+		}
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			s.deliver(subscription, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt at sending a delivery and records the outcome.
+// Any 2xx response counts as delivered.
+func (s *WebhookService) deliver(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := s.send(subscription, delivery)
+	delivery.LastStatusCode = statusCode
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts < s.retry.MaxAttempts:
+		next := now.Add(s.retry.delay(delivery.Attempts, 0))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+		logger.Sugar().Warnf("Webhook delivery %d to %s failed, retrying at %s: %v",
+			delivery.ID, subscription.URL, next.Format(time.RFC3339), err)
+	default:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+		logger.Sugar().Errorf("Webhook delivery %d to %s failed after %d attempts: %v",
+			delivery.ID, subscription.URL, delivery.Attempts, err)
+	}
+
+	s.repo.FinishDeliveryAttempt(delivery)
+}
+
+// send POSTs the payload to the subscriber, signed with its secret. It
+// returns the response status code, if a response was received.
+func (s *WebhookService) send(subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sykell-Webhooks/1.0")
+	req.Header.Set("X-Sykell-Event", delivery.EventType)
+	req.Header.Set("X-Sykell-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Sykell-Timestamp", timestamp)
+	req.Header.Set("X-Sykell-Signature", "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body". Including the
+// timestamp lets receivers reject replayed deliveries.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkWebhookHost resolves a subscription's host and returns
+// ErrWebhookHostNotAllowed if any of its addresses is not public.
+func checkWebhookHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return ErrWebhookHostNotAllowed
+		}
+		return nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host %q: %v", host, err)
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return ErrWebhookHostNotAllowed
+		}
+	}
+	return nil
+}
+
+// checkWebhookDial refuses connections to addresses that are not public.
+// It runs after name resolution, right before each connection is made.
+func checkWebhookDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookHostNotAllowed, host)
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a unicast address on the public
+// internet, rather than loopback, private, link-local (which includes cloud
+// metadata endpoints), shared, unspecified or multicast.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	// Carrier-grade NAT space is private to the provider's network
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// normalizeEventTypes checks that every event type can be subscribed to
+// and removes duplicates. An empty list means every event type.
+func normalizeEventTypes(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return webhookEventTypes, nil
+	}
+
+	seen := make(map[string]bool, len(eventTypes))
+	var normalized []string
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !containsString(webhookEventTypes, eventType) {
+			return nil, fmt.Errorf("unsupported event type %q, must be one of: %s", eventType, strings.Join(webhookEventTypes, ", "))
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			normalized = append(normalized, eventType)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"crawl.completed"}`)
+
+	// Expected values computed with
+	// printf '%s' "$timestamp.$body" | openssl dgst -sha256 -hmac "$secret"
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		want      string
+	}{
+		{"payload", "secret", "1700000000", body, "ff13e73d7b0b4a7d0c3e2447a9cc982aabb7c2468b87bf40c7382b6b56002160"},
+		{"other timestamp", "secret", "1700000001", body, "24fdc9b2fd91b86b07bb86b73d11622bb321340c4073a712eb8e026a2d404c8b"},
+		{"other secret", "other", "1700000000", body, "1a6516ad5df56ffd34a806f9dbbd8ae858c941ffd1aad679d3476c42c856c501"},
+		{"empty body", "secret", "1700000000", nil, "4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signWebhook(tt.secret, tt.timestamp, tt.body); got != tt.want {
+				t.Errorf("signWebhook() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"ff02::1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.255", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	validate     *validator.Validate
+	validateOnce sync.Once
+)
+
+// validatorInstance returns the shared validator. Fields are reported by
+// their JSON names so that errors point at the request body clients sent.
+func validatorInstance() *validator.Validate {
+	validateOnce.Do(func() {
+		validate = validator.New(validator.WithRequiredStructEnabled())
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	})
+	return validate
+}
+
+// FieldErrors maps the JSON path of each invalid field, such as "url" or
+// "urls[2]", to a message describing what is wrong with it.
+type FieldErrors map[string]string
+
+// Struct checks the validate tags of a request struct. It returns nil if
+// the struct is valid.
+func Struct(req interface{}) FieldErrors {
+	err := validatorInstance().Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return FieldErrors{"": err.Error()}
+	}
+
+	fields := FieldErrors{}
+	for _, fieldErr := range validationErrors {
+		path := fieldPath(req, fieldErr.Namespace())
+		if _, exists := fields[path]; !exists {
+			fields[path] = message(fieldErr)
+		}
+	}
+	return fields
+}
+
+// fieldPath strips the struct name and any embedded structs from a
+// validator namespace such as "CrawlRequest.CrawlOptions.priority", which
+// a client sent as the top-level "priority".
+func fieldPath(req interface{}, namespace string) string {
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	segments := strings.Split(namespace, ".")[1:]
+	var path []string
+	for _, segment := range segments {
+		if field, ok := t.FieldByName(segment); ok && field.Anonymous {
+			t = field.Type
+			continue
+		}
+		path = append(path, segment)
+	}
+	return strings.Join(path, ".")
+}
+
+func message(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+
+	switch fieldErr.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "url":
+		return "must be a valid URL"
+	case "http_url":
+		return "must be an absolute http or https URL"
+	case "timezone":
+		return "must be an IANA time zone such as Europe/Berlin"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(param), ", "))
+	case "excludes":
+		return fmt.Sprintf("must not contain %q", param)
+	case "min", "gte":
+		if isCollection(fieldErr.Kind()) && param == "1" {
+			return "must not be empty"
+		}
+		if isCollection(fieldErr.Kind()) {
+			return fmt.Sprintf("must contain at least %s items", param)
+		}
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", param)
+		}
+		return fmt.Sprintf("must be at least %s", param)
+	case "max", "lte":
+		if isCollection(fieldErr.Kind()) {
+			return fmt.Sprintf("must contain at most %s items", param)
+		}
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", param)
+		}
+		return fmt.Sprintf("must be at most %s", param)
+	}
+
+	return fmt.Sprintf("failed the %q check", fieldErr.Tag())
+}
+
+func isCollection(kind reflect.Kind) bool {
+	return kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+}
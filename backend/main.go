@@ -21,7 +21,14 @@ func main() {
 	}
 	defer database.Close()
 
-	// Start crawler job processor
+	// URLs added before duplicate detection need canonicalizing, which the
+	// database package cannot do on its own
+	if err := service.DefaultCrawlerService().BackfillNormalizedURLs(); err != nil {
+		logger.Sugar().Fatalf("Failed to canonicalize existing URLs: %v", err)
+	}
+
+	// Start crawler job processor. Stop runs once the server has shut down
+	// and waits for running crawls before the database is closed.
 	jobProcessor := service.NewCrawlerJobProcessor()
 	jobProcessor.Start()
 	defer jobProcessor.Stop()
@@ -29,14 +36,16 @@ func main() {
 	// Setup router
 	app := router.Setup()
 
-	// Graceful shutdown
+	// Graceful shutdown: stop accepting requests first, then let Listen
+	// return so the deferred Stop drains the crawls
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-c
 		logger.Sugar().Info("Gracefully shutting down...")
-		jobProcessor.Stop()
+		// End event streams, which would otherwise hold the server open
+		service.DefaultCrawlEventBus().Close()
 		app.Shutdown()
 	}()
 
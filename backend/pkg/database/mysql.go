@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 
 	"sykell-backend/pkg/logger"
 
@@ -63,6 +64,7 @@ func createTables() error {
 		name VARCHAR(255) NOT NULL,
 		email VARCHAR(255) UNIQUE,
 		password VARCHAR(255),
+		role ENUM('admin', 'editor', 'viewer') NOT NULL DEFAULT 'editor',
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 	);`
@@ -73,12 +75,57 @@ func createTables() error {
 		return err
 	}
 
+	// Projects own crawl URLs and everything built on them; users see the
+	// projects they are members of
+	projectsQuery := `
+	CREATE TABLE IF NOT EXISTS projects (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		created_by INT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);`
+
+	_, err = DB.Exec(projectsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create projects table: %v", err)
+		return err
+	}
+
+	projectMembersQuery := `
+	CREATE TABLE IF NOT EXISTS project_members (
+		project_id INT NOT NULL,
+		user_id INT NOT NULL,
+		role ENUM('owner', 'member') NOT NULL DEFAULT 'member',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (project_id, user_id),
+		INDEX idx_user_id (user_id),
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(projectMembersQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create project_members table: %v", err)
+		return err
+	}
+
 	// Crawl URLs table
-	crawlUrlsQuery := `
+	crawlUrlsQuery := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS crawl_urls (
 		id INT AUTO_INCREMENT PRIMARY KEY,
-		url VARCHAR(2048) NOT NULL UNIQUE,
-		status ENUM('queued', 'running', 'completed', 'error') DEFAULT 'queued',
+		url VARCHAR(2048) NOT NULL,
+		normalized_url VARCHAR(2048),
+		normalized_url_hash CHAR(64),
+		status %s DEFAULT 'queued',
+		priority ENUM('interactive', 'bulk', 'scheduled') NOT NULL DEFAULT 'interactive',
+		owner_id INT NULL,
+		project_id INT NOT NULL,
+		link_scope VARCHAR(20) NOT NULL DEFAULT '',
+		same_site_domains TEXT,
+		link_check_mode VARCHAR(20) NOT NULL DEFAULT '',
+		link_check_limit INT NOT NULL DEFAULT 0,
+		tags VARCHAR(1024) NOT NULL DEFAULT '',
 		title VARCHAR(512),
 		html_version VARCHAR(50),
 		h1_count INT DEFAULT 0,
@@ -90,15 +137,34 @@ func createTables() error {
 		internal_links_count INT DEFAULT 0,
 		external_links_count INT DEFAULT 0,
 		inaccessible_links_count INT DEFAULT 0,
+		checked_links_count INT DEFAULT 0,
+		cached_links_count INT DEFAULT 0,
+		unchecked_links_count INT DEFAULT 0,
+		mailto_links_count INT DEFAULT 0,
+		tel_links_count INT DEFAULT 0,
+		javascript_links_count INT DEFAULT 0,
+		data_links_count INT DEFAULT 0,
+		other_scheme_links_count INT DEFAULT 0,
 		has_login_form BOOLEAN DEFAULT FALSE,
 		error_message TEXT,
 		last_crawled_at TIMESTAMP NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NULL,
+		claimed_by VARCHAR(255),
+		lease_expires_at TIMESTAMP NULL,
+		heartbeat_at TIMESTAMP NULL,
+		progress_phase VARCHAR(20),
+		progress_done INT NOT NULL DEFAULT 0,
+		progress_total INT NOT NULL DEFAULT 0,
+		progress_updated_at TIMESTAMP NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 		INDEX idx_status (status),
 		INDEX idx_url (url),
-		INDEX idx_created_at (created_at)
-	);`
+		INDEX idx_created_at (created_at),
+		INDEX idx_queue (status, priority, created_at),
+		UNIQUE INDEX idx_project_normalized_url_hash (project_id, normalized_url_hash)
+	);`, crawlStatusType)
 
 	_, err = DB.Exec(crawlUrlsQuery)
 	if err != nil {
@@ -126,10 +192,673 @@ func createTables() error {
 		return err
 	}
 
+	// Link issues table
+	linkIssuesQuery := `
+	CREATE TABLE IF NOT EXISTS link_issues (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		crawl_url_id INT NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		issue_type VARCHAR(50) NOT NULL,
+		message TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+		INDEX idx_crawl_url_id (crawl_url_id),
+		INDEX idx_issue_type (issue_type)
+	);`
+
+	_, err = DB.Exec(linkIssuesQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create link_issues table: %v", err)
+		return err
+	}
+
+	// Link checks table
+	linkChecksQuery := `
+	CREATE TABLE IF NOT EXISTS link_checks (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		crawl_url_id INT NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		status_code INT,
+		error_message TEXT,
+		from_cache BOOLEAN DEFAULT FALSE,
+		checked_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+		INDEX idx_crawl_url_id (crawl_url_id)
+	);`
+
+	_, err = DB.Exec(linkChecksQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create link_checks table: %v", err)
+		return err
+	}
+
+	// Link status cache table, shared by all crawls
+	linkStatusCacheQuery := `
+	CREATE TABLE IF NOT EXISTS link_status_cache (
+		url_hash CHAR(64) PRIMARY KEY,
+		url VARCHAR(2048) NOT NULL,
+		status_code INT,
+		error_message TEXT,
+		checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_expires_at (expires_at)
+	);`
+
+	_, err = DB.Exec(linkStatusCacheQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create link_status_cache table: %v", err)
+		return err
+	}
+
+	// Crawl attempts table
+	crawlAttemptsQuery := `
+	CREATE TABLE IF NOT EXISTS crawl_attempts (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		crawl_url_id INT NOT NULL,
+		attempt INT NOT NULL,
+		outcome VARCHAR(20) NOT NULL,
+		error_class VARCHAR(20),
+		status_code INT,
+		error_message TEXT,
+		started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP NULL,
+		next_attempt_at TIMESTAMP NULL,
+		FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+		INDEX idx_crawl_url_id (crawl_url_id),
+		INDEX idx_outcome (outcome)
+	);`
+
+	_, err = DB.Exec(crawlAttemptsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create crawl_attempts table: %v", err)
+		return err
+	}
+
+	// Crawl schedules tables
+	crawlSchedulesQuery := `
+	CREATE TABLE IF NOT EXISTS crawl_schedules (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		project_id INT NOT NULL,
+		name VARCHAR(255),
+		cron_expression VARCHAR(255) NOT NULL,
+		timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+		status ENUM('active', 'paused') DEFAULT 'active',
+		last_run_at TIMESTAMP NULL,
+		next_run_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_status_next_run_at (status, next_run_at),
+		INDEX idx_project_id (project_id)
+	);`
+
+	_, err = DB.Exec(crawlSchedulesQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create crawl_schedules table: %v", err)
+		return err
+	}
+
+	crawlScheduleURLsQuery := `
+	CREATE TABLE IF NOT EXISTS crawl_schedule_urls (
+		schedule_id INT NOT NULL,
+		crawl_url_id INT NOT NULL,
+		PRIMARY KEY (schedule_id, crawl_url_id),
+		FOREIGN KEY (schedule_id) REFERENCES crawl_schedules(id) ON DELETE CASCADE,
+		FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(crawlScheduleURLsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create crawl_schedule_urls table: %v", err)
+		return err
+	}
+
+	webhookSubscriptionsQuery := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		project_id INT NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		event_types VARCHAR(255) NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_project_id (project_id)
+	);`
+
+	_, err = DB.Exec(webhookSubscriptionsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create webhook_subscriptions table: %v", err)
+		return err
+	}
+
+	webhookDeliveriesQuery := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		subscription_id INT NOT NULL,
+		event_type VARCHAR(50) NOT NULL,
+		payload MEDIUMTEXT NOT NULL,
+		status ENUM('pending', 'delivered', 'failed') NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NULL,
+		last_status_code INT,
+		last_error TEXT,
+		delivered_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_status_next_attempt_at (status, next_attempt_at),
+		INDEX idx_subscription_created_at (subscription_id, created_at),
+		FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(webhookDeliveriesQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create webhook_deliveries table: %v", err)
+		return err
+	}
+
+	importJobsQuery := `
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		owner_id INT NULL,
+		project_id INT NOT NULL,
+		filename VARCHAR(255) NOT NULL DEFAULT '',
+		format ENUM('csv', 'text') NOT NULL,
+		status ENUM('pending', 'processing', 'completed', 'failed') NOT NULL DEFAULT 'pending',
+		priority ENUM('interactive', 'bulk', 'scheduled') NOT NULL DEFAULT 'bulk',
+		profile VARCHAR(50) NOT NULL DEFAULT '',
+		content MEDIUMTEXT,
+		total_rows INT NOT NULL DEFAULT 0,
+		accepted_rows INT NOT NULL DEFAULT 0,
+		duplicate_rows INT NOT NULL DEFAULT 0,
+		rejected_rows INT NOT NULL DEFAULT 0,
+		error_message TEXT,
+		claimed_by VARCHAR(255),
+		lease_expires_at TIMESTAMP NULL,
+		started_at TIMESTAMP NULL,
+		finished_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_status (status),
+		INDEX idx_project_id (project_id)
+	);`
+
+	_, err = DB.Exec(importJobsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create import_jobs table: %v", err)
+		return err
+	}
+
+	importJobRowsQuery := `
+	CREATE TABLE IF NOT EXISTS import_job_rows (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		import_job_id INT NOT NULL,
+		line_number INT NOT NULL,
+		url VARCHAR(2048) NOT NULL DEFAULT '',
+		result ENUM('accepted', 'duplicate', 'rejected') NOT NULL,
+		reason TEXT,
+		crawl_url_id INT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE INDEX idx_job_line (import_job_id, line_number),
+		INDEX idx_job_result (import_job_id, result),
+		FOREIGN KEY (import_job_id) REFERENCES import_jobs(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(importJobRowsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create import_job_rows table: %v", err)
+		return err
+	}
+
+	// Page text is kept apart from crawl_urls so that listings do not read
+	// it. The title is copied here because a FULLTEXT index cannot span
+	// tables.
+	crawlURLContentsQuery := `
+	CREATE TABLE IF NOT EXISTS crawl_url_contents (
+		crawl_url_id INT PRIMARY KEY,
+		page_title VARCHAR(512) NOT NULL DEFAULT '',
+		meta_description TEXT,
+		body_text MEDIUMTEXT,
+		indexed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		FULLTEXT INDEX ft_content (page_title, meta_description, body_text),
+		FULLTEXT INDEX ft_title (page_title),
+		FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(crawlURLContentsQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create crawl_url_contents table: %v", err)
+		return err
+	}
+
+	// Refresh tokens are stored as SHA-256 hashes. Each refresh rotates the
+	// token within its family, the login it descends from, and access_jti
+	// names the access token issued with it.
+	refreshTokensQuery := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		family_id CHAR(32) NOT NULL,
+		access_jti CHAR(32) NOT NULL,
+		access_expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		used_at TIMESTAMP NULL,
+		revoked_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_family_id (family_id),
+		INDEX idx_access_jti (access_jti),
+		INDEX idx_user_id (user_id),
+		INDEX idx_expires_at (expires_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(refreshTokensQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create refresh_tokens table: %v", err)
+		return err
+	}
+
+	// Denylist of revoked access tokens, kept until they expire anyway
+	revokedTokensQuery := `
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti CHAR(32) PRIMARY KEY,
+		user_id INT NOT NULL,
+		expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_expires_at (expires_at)
+	);`
+
+	_, err = DB.Exec(revokedTokensQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create revoked_tokens table: %v", err)
+		return err
+	}
+
+	// Password reset tokens are stored as SHA-256 hashes and work once
+	passwordResetTokensQuery := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		user_id INT NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		used_at TIMESTAMP NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id),
+		INDEX idx_expires_at (expires_at),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	_, err = DB.Exec(passwordResetTokensQuery)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create password_reset_tokens table: %v", err)
+		return err
+	}
+
+	if err := migrateColumns(); err != nil {
+		return err
+	}
+
+	if err := migrateStatusType(); err != nil {
+		return err
+	}
+
+	if err := migrateNormalizedURLs(); err != nil {
+		return err
+	}
+
+	if err := migrateProjects(); err != nil {
+		return err
+	}
+
+	if err := bootstrapAdmin(); err != nil {
+		return err
+	}
+
+	if err := migrateIndexes(); err != nil {
+		return err
+	}
+
 	logger.Sugar().Info("Database tables created successfully")
 	return nil
 }
 
+// crawlStatusType is the column type of crawl_urls.status. New statuses are
+// appended so that existing values keep their position.
+const crawlStatusType = "ENUM('queued', 'running', 'completed', 'error', 'failed', 'cancelled')"
+
+// migrateStatusType widens crawl_urls.status when new statuses are added.
+func migrateStatusType() error {
+	var columnType string
+	err := DB.QueryRow(`
+		SELECT COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'crawl_urls' AND COLUMN_NAME = 'status'
+	`).Scan(&columnType)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to inspect crawl_urls.status: %v", err)
+		return err
+	}
+
+	if strings.EqualFold(strings.ReplaceAll(columnType, " ", ""), strings.ReplaceAll(crawlStatusType, " ", "")) {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE crawl_urls MODIFY COLUMN status %s DEFAULT 'queued'", crawlStatusType)
+	if _, err := DB.Exec(query); err != nil {
+		logger.Sugar().Errorf("Failed to migrate crawl_urls.status: %v", err)
+		return err
+	}
+	logger.Sugar().Infof("Migrated crawl_urls.status to %s", crawlStatusType)
+	return nil
+}
+
+// columnMigration describes a column added after a table was first released.
+// CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so these are
+// applied separately to bring older databases up to date.
+type columnMigration struct {
+	Table      string
+	Column     string
+	Definition string
+}
+
+var columnMigrations = []columnMigration{
+	{"crawl_urls", "mailto_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "tel_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "javascript_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "data_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "other_scheme_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "link_scope", "VARCHAR(20) NOT NULL DEFAULT ''"},
+	{"crawl_urls", "same_site_domains", "TEXT"},
+	{"crawl_urls", "link_check_mode", "VARCHAR(20) NOT NULL DEFAULT ''"},
+	{"crawl_urls", "link_check_limit", "INT NOT NULL DEFAULT 0"},
+	{"crawl_urls", "checked_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "cached_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "normalized_url", "VARCHAR(2048)"},
+	{"crawl_urls", "normalized_url_hash", "CHAR(64)"},
+	{"crawl_urls", "claimed_by", "VARCHAR(255)"},
+	{"crawl_urls", "lease_expires_at", "TIMESTAMP NULL"},
+	{"crawl_urls", "heartbeat_at", "TIMESTAMP NULL"},
+	{"crawl_urls", "attempts", "INT NOT NULL DEFAULT 0"},
+	{"crawl_urls", "next_attempt_at", "TIMESTAMP NULL"},
+	{"crawl_urls", "unchecked_links_count", "INT DEFAULT 0"},
+	{"crawl_urls", "priority", "ENUM('interactive', 'bulk', 'scheduled') NOT NULL DEFAULT 'interactive'"},
+	{"crawl_urls", "owner_id", "INT NULL"},
+	{"crawl_urls", "progress_phase", "VARCHAR(20)"},
+	{"crawl_urls", "progress_done", "INT NOT NULL DEFAULT 0"},
+	{"crawl_urls", "progress_total", "INT NOT NULL DEFAULT 0"},
+	{"crawl_urls", "progress_updated_at", "TIMESTAMP NULL"},
+	{"crawl_urls", "tags", "VARCHAR(1024) NOT NULL DEFAULT ''"},
+	{"crawl_urls", "project_id", "INT NULL"},
+	{"crawl_schedules", "project_id", "INT NULL"},
+	{"webhook_subscriptions", "project_id", "INT NULL"},
+	{"import_jobs", "project_id", "INT NULL"},
+	{"users", "role", "ENUM('admin', 'editor', 'viewer') NOT NULL DEFAULT 'editor'"},
+}
+
+func migrateColumns() error {
+	for _, m := range columnMigrations {
+		var count int
+		err := DB.QueryRow(`
+			SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?
+		`, m.Table, m.Column).Scan(&count)
+		if err != nil {
+			logger.Sugar().Errorf("Failed to inspect column %s.%s: %v", m.Table, m.Column, err)
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.Table, m.Column, m.Definition)
+		if _, err := DB.Exec(query); err != nil {
+			logger.Sugar().Errorf("Failed to add column %s.%s: %v", m.Table, m.Column, err)
+			return err
+		}
+		logger.Sugar().Infof("Added column %s.%s", m.Table, m.Column)
+	}
+	return nil
+}
+
+// migrateNormalizedURLs moves duplicate detection from the raw url column to
+// normalized_url_hash, per project. Rows created before URLs were
+// canonicalized keep a NULL hash, which the unique index ignores, until
+// CrawlerService.BackfillNormalizedURLs canonicalizes them at startup.
+func migrateNormalizedURLs() error {
+	hasIndex, err := indexExists("crawl_urls", "idx_project_normalized_url_hash")
+	if err != nil {
+		return err
+	}
+	if !hasIndex {
+		if _, err := DB.Exec("ALTER TABLE crawl_urls ADD UNIQUE INDEX idx_project_normalized_url_hash (project_id, normalized_url_hash)"); err != nil {
+			logger.Sugar().Errorf("Failed to add normalized URL index: %v", err)
+			return err
+		}
+	}
+
+	// Before projects, a URL could only be added once across all users
+	hasIndex, err = indexExists("crawl_urls", "idx_normalized_url_hash")
+	if err != nil {
+		return err
+	}
+	if hasIndex {
+		if _, err := DB.Exec("ALTER TABLE crawl_urls DROP INDEX idx_normalized_url_hash"); err != nil {
+			logger.Sugar().Errorf("Failed to drop global normalized URL index: %v", err)
+			return err
+		}
+	}
+
+	// The inline UNIQUE on url created an index named after the column
+	hasIndex, err = indexExists("crawl_urls", "url")
+	if err != nil {
+		return err
+	}
+	if hasIndex {
+		if _, err := DB.Exec("ALTER TABLE crawl_urls DROP INDEX url"); err != nil {
+			logger.Sugar().Errorf("Failed to drop unique URL index: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// projectTables are the tables whose rows belong to a project.
+var projectTables = []string{"crawl_urls", "crawl_schedules", "webhook_subscriptions", "import_jobs"}
+
+// migrateProjects moves rows created before projects existed into a shared
+// "Default" project and then makes project_id NOT NULL. Every user could see
+// and change those rows, so every existing user becomes an owner of it and
+// nobody loses access. It only runs while a project_id column is still
+// nullable, which after the first successful run none is.
+func migrateProjects() error {
+	var nullable int
+	err := DB.QueryRow(fmt.Sprintf(`
+		SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND COLUMN_NAME = 'project_id' AND IS_NULLABLE = 'YES'
+			AND TABLE_NAME IN (%s)
+	`, strings.Repeat("?,", len(projectTables)-1)+"?"), stringArgs(projectTables)...).Scan(&nullable)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to inspect project_id columns: %v", err)
+		return err
+	}
+	if nullable == 0 {
+		return nil
+	}
+
+	var orphans int
+	for _, table := range projectTables {
+		var count int
+		if err := DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE project_id IS NULL", table)).Scan(&count); err != nil {
+			logger.Sugar().Errorf("Failed to count %s without a project: %v", table, err)
+			return err
+		}
+		orphans += count
+	}
+
+	if orphans > 0 {
+		if err := moveToDefaultProject(orphans); err != nil {
+			return err
+		}
+	}
+
+	// DDL commits implicitly, so this follows the move; if it is
+	// interrupted, the next start finds no orphans and only retries this
+	for _, table := range projectTables {
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN project_id INT NOT NULL", table)); err != nil {
+			logger.Sugar().Errorf("Failed to make %s.project_id NOT NULL: %v", table, err)
+			return err
+		}
+	}
+	logger.Sugar().Info("Made project_id NOT NULL")
+	return nil
+}
+
+// moveToDefaultProject creates the "Default" project, makes every user an
+// owner of it and moves the rows without a project into it.
+func moveToDefaultProject(orphans int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to begin project migration: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO projects (name) VALUES ('Default')")
+	if err != nil {
+		logger.Sugar().Errorf("Failed to create default project: %v", err)
+		return err
+	}
+	projectID, err := result.LastInsertId()
+	if err != nil {
+		logger.Sugar().Errorf("Failed to get default project ID: %v", err)
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO project_members (project_id, user_id, role) SELECT ?, id, 'owner' FROM users", projectID); err != nil {
+		logger.Sugar().Errorf("Failed to add users to default project: %v", err)
+		return err
+	}
+
+	// The unique index on (project_id, normalized_url_hash) ignored rows
+	// without a project, so they may hold duplicates that would collide in
+	// the new project. Their hashes are cleared for
+	// CrawlerService.BackfillNormalizedURLs to flag them at startup.
+	_, err = tx.Exec(`
+		UPDATE crawl_urls u
+		JOIN crawl_urls o ON o.project_id IS NULL AND o.normalized_url_hash = u.normalized_url_hash AND o.id < u.id
+		SET u.normalized_url_hash = NULL
+		WHERE u.project_id IS NULL
+	`)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to clear duplicate URLs without a project: %v", err)
+		return err
+	}
+
+	for _, table := range projectTables {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET project_id = ? WHERE project_id IS NULL", table), projectID); err != nil {
+			logger.Sugar().Errorf("Failed to move %s into default project: %v", table, err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Sugar().Errorf("Failed to commit project migration: %v", err)
+		return err
+	}
+	logger.Sugar().Infof("Moved %d existing rows into default project %d", orphans, projectID)
+	return nil
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+// bootstrapAdmin makes the user with the email address in
+// BOOTSTRAP_ADMIN_EMAIL an admin while there is no admin, as after a fresh
+// deployment or in databases created before roles existed. Nobody is ever
+// promoted implicitly, and once an admin exists the setting is ignored, so
+// an admin can later demote the bootstrapped user.
+func bootstrapAdmin() error {
+	var admins int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&admins); err != nil {
+		logger.Sugar().Errorf("Failed to count admins: %v", err)
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	email := strings.TrimSpace(getEnv("BOOTSTRAP_ADMIN_EMAIL", ""))
+	if email == "" {
+		logger.Sugar().Warn("There is no admin; set BOOTSTRAP_ADMIN_EMAIL to an existing user's email address and restart to make them one")
+		return nil
+	}
+
+	result, err := DB.Exec("UPDATE users SET role = 'admin' WHERE email = ?", email)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to promote the bootstrap admin: %v", err)
+		return err
+	}
+	if promoted, _ := result.RowsAffected(); promoted > 0 {
+		logger.Sugar().Infof("Made %s an admin", email)
+	} else {
+		logger.Sugar().Warnf("There is no admin and no user with BOOTSTRAP_ADMIN_EMAIL %s; register one and restart", email)
+	}
+	return nil
+}
+
+// indexMigration describes an index added after a table was first released.
+type indexMigration struct {
+	Table      string
+	Index      string
+	Definition string
+}
+
+var indexMigrations = []indexMigration{
+	{"crawl_urls", "idx_queue", "(status, priority, created_at)"},
+	{"crawl_schedules", "idx_project_id", "(project_id)"},
+	{"webhook_subscriptions", "idx_project_id", "(project_id)"},
+	{"import_jobs", "idx_project_id", "(project_id)"},
+}
+
+func migrateIndexes() error {
+	for _, m := range indexMigrations {
+		hasIndex, err := indexExists(m.Table, m.Index)
+		if err != nil {
+			return err
+		}
+		if hasIndex {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD INDEX %s %s", m.Table, m.Index, m.Definition)
+		if _, err := DB.Exec(query); err != nil {
+			logger.Sugar().Errorf("Failed to add index %s.%s: %v", m.Table, m.Index, err)
+			return err
+		}
+		logger.Sugar().Infof("Added index %s.%s", m.Table, m.Index)
+	}
+	return nil
+}
+
+func indexExists(table, index string) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?
+	`, table, index).Scan(&count)
+	if err != nil {
+		logger.Sugar().Errorf("Failed to inspect index %s.%s: %v", table, index, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
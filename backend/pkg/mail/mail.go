@@ -0,0 +1,86 @@
+// Package mail sends plain-text email. Senders are interchangeable: SMTP
+// delivers through a mail server, and Log stands in for one during local
+// development by writing messages to the log instead.
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"sykell-backend/pkg/logger"
+)
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTP sends messages through an SMTP server, upgrading the connection
+// with STARTTLS when the server offers it. Without a username it sends
+// without authenticating, as local test servers expect.
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTP) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := net.JoinHostPort(s.Host, s.Port)
+	if err := smtp.SendMail(addr, auth, s.From, []string{msg.To}, format(s.From, msg)); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// Log writes messages to the log instead of sending them. Bodies may hold
+// secrets such as reset tokens, so they are only logged if Bodies is set.
+type Log struct {
+	Bodies bool
+}
+
+func (l Log) Send(msg Message) error {
+	if !l.Bodies {
+		logger.Sugar().Infof("Mail to %s: %s (body not logged)", msg.To, msg.Subject)
+		return nil
+	}
+	logger.Sugar().Infof("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// format renders msg with the headers of a plain-text email.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	header := func(name, value string) {
+		// Line breaks in a value would start new headers
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", msg.Subject)
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
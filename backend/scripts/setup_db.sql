@@ -8,15 +8,46 @@ CREATE TABLE IF NOT EXISTS users (
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) UNIQUE,
     password VARCHAR(255),
+    role ENUM('admin', 'editor', 'viewer') NOT NULL DEFAULT 'editor',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 
+-- Create project tables
+CREATE TABLE IF NOT EXISTS projects (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role ENUM('owner', 'member') NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, user_id),
+    INDEX idx_user_id (user_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
 -- Create crawl_urls table
 CREATE TABLE IF NOT EXISTS crawl_urls (
     id INT AUTO_INCREMENT PRIMARY KEY,
-    url VARCHAR(2048) NOT NULL UNIQUE,
-    status ENUM('queued', 'running', 'completed', 'error') DEFAULT 'queued',
+    url VARCHAR(2048) NOT NULL,
+    normalized_url VARCHAR(2048),
+    normalized_url_hash CHAR(64),
+    status ENUM('queued', 'running', 'completed', 'error', 'failed', 'cancelled') DEFAULT 'queued',
+    priority ENUM('interactive', 'bulk', 'scheduled') NOT NULL DEFAULT 'interactive',
+    owner_id INT NULL,
+    project_id INT NOT NULL,
+    link_scope VARCHAR(20) NOT NULL DEFAULT '',
+    same_site_domains TEXT,
+    link_check_mode VARCHAR(20) NOT NULL DEFAULT '',
+    link_check_limit INT NOT NULL DEFAULT 0,
+    tags VARCHAR(1024) NOT NULL DEFAULT '',
     title VARCHAR(512),
     html_version VARCHAR(50),
     h1_count INT DEFAULT 0,
@@ -28,14 +59,33 @@ CREATE TABLE IF NOT EXISTS crawl_urls (
     internal_links_count INT DEFAULT 0,
     external_links_count INT DEFAULT 0,
     inaccessible_links_count INT DEFAULT 0,
+    checked_links_count INT DEFAULT 0,
+    cached_links_count INT DEFAULT 0,
+    unchecked_links_count INT DEFAULT 0,
+    mailto_links_count INT DEFAULT 0,
+    tel_links_count INT DEFAULT 0,
+    javascript_links_count INT DEFAULT 0,
+    data_links_count INT DEFAULT 0,
+    other_scheme_links_count INT DEFAULT 0,
     has_login_form BOOLEAN DEFAULT FALSE,
     error_message TEXT,
     last_crawled_at TIMESTAMP NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NULL,
+    claimed_by VARCHAR(255),
+    lease_expires_at TIMESTAMP NULL,
+    heartbeat_at TIMESTAMP NULL,
+    progress_phase VARCHAR(20),
+    progress_done INT NOT NULL DEFAULT 0,
+    progress_total INT NOT NULL DEFAULT 0,
+    progress_updated_at TIMESTAMP NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX idx_status (status),
     INDEX idx_url (url),
-    INDEX idx_created_at (created_at)
+    INDEX idx_created_at (created_at),
+    INDEX idx_queue (status, priority, created_at),
+    UNIQUE INDEX idx_project_normalized_url_hash (project_id, normalized_url_hash)
 );
 
 -- Create broken_links table
@@ -51,7 +101,211 @@ CREATE TABLE IF NOT EXISTS broken_links (
     INDEX idx_status_code (status_code)
 );
 
--- Insert sample data (optional)
-INSERT INTO users (name, email, password) VALUES 
-('John Doe', 'john@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi'), -- password: password
-('Jane Smith', 'jane@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi'); -- password: password
+-- Create link_issues table
+CREATE TABLE IF NOT EXISTS link_issues (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    crawl_url_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    issue_type VARCHAR(50) NOT NULL,
+    message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+    INDEX idx_crawl_url_id (crawl_url_id),
+    INDEX idx_issue_type (issue_type)
+);
+
+-- Create link_checks table
+CREATE TABLE IF NOT EXISTS link_checks (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    crawl_url_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    status_code INT,
+    error_message TEXT,
+    from_cache BOOLEAN DEFAULT FALSE,
+    checked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+    INDEX idx_crawl_url_id (crawl_url_id)
+);
+
+-- Create link_status_cache table
+CREATE TABLE IF NOT EXISTS link_status_cache (
+    url_hash CHAR(64) PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    status_code INT,
+    error_message TEXT,
+    checked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_expires_at (expires_at)
+);
+
+-- Create crawl_attempts table
+CREATE TABLE IF NOT EXISTS crawl_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    crawl_url_id INT NOT NULL,
+    attempt INT NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    error_class VARCHAR(20),
+    status_code INT,
+    error_message TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP NULL,
+    next_attempt_at TIMESTAMP NULL,
+    FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE,
+    INDEX idx_crawl_url_id (crawl_url_id),
+    INDEX idx_outcome (outcome)
+);
+
+-- Create crawl_schedules tables
+CREATE TABLE IF NOT EXISTS crawl_schedules (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    project_id INT NOT NULL,
+    name VARCHAR(255),
+    cron_expression VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    status ENUM('active', 'paused') DEFAULT 'active',
+    last_run_at TIMESTAMP NULL,
+    next_run_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_status_next_run_at (status, next_run_at),
+    INDEX idx_project_id (project_id)
+);
+
+CREATE TABLE IF NOT EXISTS crawl_schedule_urls (
+    schedule_id INT NOT NULL,
+    crawl_url_id INT NOT NULL,
+    PRIMARY KEY (schedule_id, crawl_url_id),
+    FOREIGN KEY (schedule_id) REFERENCES crawl_schedules(id) ON DELETE CASCADE,
+    FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE
+);
+
+-- Create webhook tables
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    project_id INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    event_types VARCHAR(255) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_project_id (project_id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    subscription_id INT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    status ENUM('pending', 'delivered', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NULL,
+    last_status_code INT,
+    last_error TEXT,
+    delivered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_status_next_attempt_at (status, next_attempt_at),
+    INDEX idx_subscription_created_at (subscription_id, created_at),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
+);
+
+-- Create import tables
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_id INT NULL,
+    project_id INT NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    format ENUM('csv', 'text') NOT NULL,
+    status ENUM('pending', 'processing', 'completed', 'failed') NOT NULL DEFAULT 'pending',
+    priority ENUM('interactive', 'bulk', 'scheduled') NOT NULL DEFAULT 'bulk',
+    profile VARCHAR(50) NOT NULL DEFAULT '',
+    content MEDIUMTEXT,
+    total_rows INT NOT NULL DEFAULT 0,
+    accepted_rows INT NOT NULL DEFAULT 0,
+    duplicate_rows INT NOT NULL DEFAULT 0,
+    rejected_rows INT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    claimed_by VARCHAR(255),
+    lease_expires_at TIMESTAMP NULL,
+    started_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_status (status),
+    INDEX idx_project_id (project_id)
+);
+
+CREATE TABLE IF NOT EXISTS import_job_rows (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    import_job_id INT NOT NULL,
+    line_number INT NOT NULL,
+    url VARCHAR(2048) NOT NULL DEFAULT '',
+    result ENUM('accepted', 'duplicate', 'rejected') NOT NULL,
+    reason TEXT,
+    crawl_url_id INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_job_line (import_job_id, line_number),
+    INDEX idx_job_result (import_job_id, result),
+    FOREIGN KEY (import_job_id) REFERENCES import_jobs(id) ON DELETE CASCADE
+);
+
+-- Create page content table
+CREATE TABLE IF NOT EXISTS crawl_url_contents (
+    crawl_url_id INT PRIMARY KEY,
+    page_title VARCHAR(512) NOT NULL DEFAULT '',
+    meta_description TEXT,
+    body_text MEDIUMTEXT,
+    indexed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FULLTEXT INDEX ft_content (page_title, meta_description, body_text),
+    FULLTEXT INDEX ft_title (page_title),
+    FOREIGN KEY (crawl_url_id) REFERENCES crawl_urls(id) ON DELETE CASCADE
+);
+
+-- Create refresh token table
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    family_id CHAR(32) NOT NULL,
+    access_jti CHAR(32) NOT NULL,
+    access_expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_family_id (family_id),
+    INDEX idx_access_jti (access_jti),
+    INDEX idx_user_id (user_id),
+    INDEX idx_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create revoked access token table
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti CHAR(32) PRIMARY KEY,
+    user_id INT NOT NULL,
+    expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_expires_at (expires_at)
+);
+
+-- Create password reset token table
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id),
+    INDEX idx_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Insert sample data (optional). The sample users share a known password,
+-- so they only get the viewer role; admins come from BOOTSTRAP_ADMIN_EMAIL.
+INSERT INTO users (name, email, password, role) VALUES 
+('John Doe', 'john@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'viewer'), -- password: password
+('Jane Smith', 'jane@example.com', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'viewer'); -- password: password
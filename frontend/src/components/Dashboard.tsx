@@ -136,14 +136,23 @@ const Dashboard = () => {
                                 Add URL
                             </button>
                             <button
-                                onClick={() => {
-                                    authAPI.logout()
+                                onClick={async () => {
+                                    await authAPI.logout()
                                     navigate('/login')
                                 }}
                                 className="inline-flex items-center px-4 py-2 border border-gray-300 rounded-md shadow-sm text-sm font-medium text-gray-700 bg-white hover:bg-gray-50 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
                             >
                                 Logout
                             </button>
+                            <button
+                                onClick={async () => {
+                                    await authAPI.logoutEverywhere()
+                                    navigate('/login')
+                                }}
+                                className="inline-flex items-center px-4 py-2 border border-gray-300 rounded-md shadow-sm text-sm font-medium text-gray-700 bg-white hover:bg-gray-50 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500"
+                            >
+                                Logout everywhere
+                            </button>
                         </div>
                     </div>
                 </div>
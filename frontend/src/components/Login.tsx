@@ -1,4 +1,4 @@
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
 import { useNavigate } from 'react-router-dom'
 import { authAPI } from '../services/api'
 
@@ -13,6 +13,21 @@ const Login = () => {
     const [loading, setLoading] = useState(false)
     const [error, setError] = useState('')
 
+    // Resume a previous session instead of asking for the password again
+    useEffect(() => {
+        if (!authAPI.hasRefreshToken()) {
+            return
+        }
+        setLoading(true)
+        authAPI.refresh()
+            .then(refreshed => {
+                if (refreshed) {
+                    navigate('/')
+                }
+            })
+            .finally(() => setLoading(false))
+    }, [navigate])
+
     const handleSubmit = async (e: React.FormEvent) => {
         e.preventDefault()
         setLoading(true)
@@ -7,6 +7,8 @@ export interface LoginRequest {
 
 export interface LoginResponse {
     token: string
+    refresh_token: string
+    expires_in: number
     user: {
         id: number
         name: string
@@ -35,11 +37,56 @@ export interface CrawlURLsParams {
     search?: string
 }
 
-// Store JWT token
+// Store JWT access token and the refresh token that renews it
 let authToken: string | null = localStorage.getItem('authToken')
+let refreshToken: string | null = localStorage.getItem('refreshToken')
+
+const storeSession = (data: { token: string; refresh_token: string }) => {
+    authToken = data.token
+    refreshToken = data.refresh_token
+    localStorage.setItem('authToken', data.token)
+    localStorage.setItem('refreshToken', data.refresh_token)
+}
+
+const clearSession = () => {
+    authToken = null
+    refreshToken = null
+    localStorage.removeItem('authToken')
+    localStorage.removeItem('refreshToken')
+}
+
+// Refresh tokens work once, so concurrent requests share one refresh
+let refreshing: Promise<boolean> | null = null
+
+const refreshSession = (): Promise<boolean> => {
+    if (!refreshing) {
+        refreshing = (async () => {
+            if (!refreshToken) {
+                return false
+            }
+
+            const response = await fetch(`${API_BASE_URL}/refresh`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken }),
+            }).catch(() => null)
+
+            if (!response || !response.ok) {
+                clearSession()
+                return false
+            }
+
+            storeSession(await response.json())
+            return true
+        })().finally(() => {
+            refreshing = null
+        })
+    }
+    return refreshing
+}
 
 // Helper function to make authenticated requests
-const makeRequest = async (url: string, options: RequestInit = {}) => {
+const makeRequest = async (url: string, options: RequestInit = {}, retried = false): Promise<any> => {
     const headers: Record<string, string> = {
         'Content-Type': 'application/json',
         ...(options.headers as Record<string, string>),
@@ -55,9 +102,11 @@ const makeRequest = async (url: string, options: RequestInit = {}) => {
     })
 
     if (response.status === 401) {
-        // Token expired or invalid
-        authToken = null
-        localStorage.removeItem('authToken')
+        // Access token expired or revoked: renew it once and retry
+        if (!retried && await refreshSession()) {
+            return makeRequest(url, options, true)
+        }
+        clearSession()
         throw new Error('Authentication required')
     }
 
@@ -84,11 +133,15 @@ export const authAPI = {
         }
 
         const data = await response.json()
-        authToken = data.token
-        localStorage.setItem('authToken', data.token)
+        storeSession(data)
         return data
     },
 
+    // Resume a stored session with its refresh token
+    refresh: refreshSession,
+
+    hasRefreshToken: () => !!refreshToken,
+
     register: async (userData: CreateUserRequest): Promise<LoginResponse> => {
         const response = await fetch(`${API_BASE_URL}/users`, {
             method: 'POST',
@@ -104,9 +157,15 @@ export const authAPI = {
         return response.json()
     },
 
-    logout: () => {
-        authToken = null
-        localStorage.removeItem('authToken')
+    logout: async () => {
+        await makeRequest('/logout', { method: 'POST' }).catch(() => undefined)
+        clearSession()
+    },
+
+    // End the sessions on all devices
+    logoutEverywhere: async () => {
+        await makeRequest('/logout/all', { method: 'POST' }).catch(() => undefined)
+        clearSession()
     },
 
     isAuthenticated: () => !!authToken,